@@ -8,10 +8,15 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/rauche/cronnor/internal/auth"
 	"github.com/rauche/cronnor/internal/config"
+	"github.com/rauche/cronnor/internal/events"
 	"github.com/rauche/cronnor/internal/http"
 	"github.com/rauche/cronnor/internal/jobs"
+	"github.com/rauche/cronnor/internal/metrics"
+	"github.com/rauche/cronnor/internal/notify"
 	"github.com/rauche/cronnor/internal/storage"
+	"github.com/rauche/cronnor/internal/tracing"
 )
 
 func main() {
@@ -35,15 +40,68 @@ func main() {
 	}
 	log.Println("✅ Database migrations completed")
 
-	// Initialize scheduler
-	scheduler := jobs.NewScheduler(repo)
+	// Create the first admin account
+	if err := bootstrapAdmin(cfg, repo); err != nil {
+		log.Fatalf("Failed to create admin user: %v", err)
+	}
+
+	// Metrics for Prometheus, served on /metrics
+	m, err := metrics.New(repo, cfg.MetricsJobLabel)
+	if err != nil {
+		log.Fatalf("Invalid METRICS_JOB_LABEL: %v", err)
+	}
+	if cfg.MetricsToken == "" && cfg.MetricsJobLabel != metrics.JobLabelNone {
+		log.Printf("Warning: /metrics is public and labels series by job %s; set METRICS_TOKEN to restrict it", cfg.MetricsJobLabel)
+	}
+
+	// Trace job executions when an OTLP exporter is configured
+	tp, shutdownTracing, err := tracing.Setup(context.Background(), cfg.TracesExporter, cfg.TracesProtocol)
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+	if cfg.TracesExporter != tracing.ExporterNone {
+		log.Printf("✅ Tracing enabled (%s over %s)", cfg.TracesExporter, cfg.TracesProtocol)
+	}
+
+	// Initialize scheduler; it publishes job changes and executions on the
+	// event bus for live updates
+	bus := events.NewBus()
+	scheduler := jobs.NewScheduler(repo, bus, m, tp)
+
+	// Send alerts to notification channels as the jobs' rules ask. The
+	// notifier is handed every outcome directly, before any job runs.
+	notifier := notify.New(repo, notify.Config{
+		SMTP: notify.SMTPConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.SMTPFrom,
+		},
+	})
+	scheduler.OnOutcome(notifier.HandleOutcome)
+	notifier.Start()
+	log.Println("✅ Notifier started")
+
 	if err := scheduler.Start(); err != nil {
 		log.Fatalf("Failed to start scheduler: %v", err)
 	}
 	log.Println("✅ Job scheduler started")
 
+	// Purge expired jobs from the trash
+	if cfg.TrashRetention > 0 {
+		if err := scheduler.SchedulePurge(cfg.TrashRetention); err != nil {
+			log.Fatalf("Failed to schedule trash purge: %v", err)
+		}
+	}
+
+	// Mark heartbeat jobs that miss a ping
+	if err := scheduler.MonitorHeartbeats(15 * time.Second); err != nil {
+		log.Fatalf("Failed to schedule heartbeat checks: %v", err)
+	}
+
 	// Initialize HTTP server
-	server, err := http.NewServer(repo, scheduler)
+	server, err := http.NewServer(cfg, repo, scheduler, bus, m)
 	if err != nil {
 		log.Fatalf("Failed to create HTTP server: %v", err)
 	}
@@ -67,11 +125,44 @@ func main() {
 
 	// Stop scheduler
 	scheduler.Stop()
+	notifier.Stop()
 
 	// Give time for cleanup
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
+	if err := shutdownTracing(ctx); err != nil {
+		log.Printf("Warning: failed to flush traces: %v", err)
+	}
 	<-ctx.Done()
 
 	log.Println("👋 Server stopped")
 }
+
+// bootstrapAdmin creates the admin account from ADMIN_USERNAME and
+// ADMIN_PASSWORD when the database has no users yet
+func bootstrapAdmin(cfg *config.Config, repo *storage.Repository) error {
+	count, err := repo.CountUsers()
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	if cfg.AdminUsername == "" || cfg.AdminPassword == "" {
+		log.Println("⚠️  No users exist; set ADMIN_USERNAME and ADMIN_PASSWORD to create an admin account")
+		return nil
+	}
+
+	hash, err := auth.HashPassword(cfg.AdminPassword)
+	if err != nil {
+		return err
+	}
+
+	if _, err := repo.CreateUser(cfg.AdminUsername, hash, string(auth.RoleAdmin)); err != nil {
+		return err
+	}
+
+	log.Printf("✅ Created admin user %q", cfg.AdminUsername)
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/rauche/cronnor/internal/auth"
+	"github.com/rauche/cronnor/internal/config"
+	"github.com/rauche/cronnor/internal/storage"
+)
+
+func TestBootstrapAdmin(t *testing.T) {
+	repo, err := storage.New(filepath.Join(t.TempDir(), "cronnor.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer repo.Close()
+	if err := repo.RunMigrations("../../migrations"); err != nil {
+		t.Fatalf("failed to run migrations: %v", err)
+	}
+
+	// Without credentials no account is created
+	if err := bootstrapAdmin(&config.Config{}, repo); err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := repo.CountUsers(); count != 0 {
+		t.Fatalf("expected no users without ADMIN_USERNAME and ADMIN_PASSWORD, got %d", count)
+	}
+
+	cfg := &config.Config{AdminUsername: "admin", AdminPassword: "password123"}
+	if err := bootstrapAdmin(cfg, repo); err != nil {
+		t.Fatal(err)
+	}
+	admin, err := repo.GetUserByUsername("admin")
+	if err != nil {
+		t.Fatalf("expected the admin to be created: %v", err)
+	}
+	if admin.Role != string(auth.RoleAdmin) || !auth.CheckPassword(admin.PasswordHash, "password123") {
+		t.Errorf("expected an admin with the configured password, got %+v", admin)
+	}
+
+	// Later starts leave existing accounts alone, even with new credentials
+	cfg = &config.Config{AdminUsername: "root", AdminPassword: "changed-password"}
+	if err := bootstrapAdmin(cfg, repo); err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := repo.CountUsers(); count != 1 {
+		t.Errorf("expected the admin to be created only once, got %d users", count)
+	}
+	admin, _ = repo.GetUserByUsername("admin")
+	if !auth.CheckPassword(admin.PasswordHash, "password123") {
+		t.Error("expected the admin's password to be left unchanged")
+	}
+}
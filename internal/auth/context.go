@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/rauche/cronnor/internal/models"
+)
+
+type contextKey int
+
+const (
+	tokenKey contextKey = iota
+	userKey
+)
+
+// WithToken returns a context carrying the authenticated API token
+func WithToken(ctx context.Context, token *models.APIToken) context.Context {
+	return context.WithValue(ctx, tokenKey, token)
+}
+
+// TokenFromContext returns the authenticated API token, if any
+func TokenFromContext(ctx context.Context) *models.APIToken {
+	token, _ := ctx.Value(tokenKey).(*models.APIToken)
+	return token
+}
+
+// WithUser returns a context carrying the logged-in user
+func WithUser(ctx context.Context, user *models.User) context.Context {
+	return context.WithValue(ctx, userKey, user)
+}
+
+// UserFromContext returns the logged-in user, if any
+func UserFromContext(ctx context.Context) *models.User {
+	user, _ := ctx.Value(userKey).(*models.User)
+	return user
+}
@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// OIDCConfig configures single sign-on through an OpenID Connect provider
+type OIDCConfig struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// GroupsClaim is the ID token claim holding the user's groups
+	GroupsClaim string
+	// GroupRoles maps IdP group names to Cronnor roles
+	GroupRoles map[string]Role
+	// DefaultRole is given to users in no mapped group; empty denies them
+	DefaultRole Role
+}
+
+// OIDCIdentity is the identity asserted by the provider's ID token
+type OIDCIdentity struct {
+	Subject  string
+	Username string
+	Groups   []string
+}
+
+// ErrNoRole is returned when none of a user's groups maps to a role
+var ErrNoRole = errors.New("no Cronnor role is mapped to the user's groups")
+
+// OIDCProvider performs the authorization-code flow with PKCE
+type OIDCProvider struct {
+	cfg      OIDCConfig
+	oauth    oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+// NewOIDCProvider discovers the provider's endpoints from its issuer URL
+func NewOIDCProvider(ctx context.Context, cfg OIDCConfig) (*OIDCProvider, error) {
+	provider, err := oidc.NewProvider(ctx, cfg.IssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover OIDC provider: %w", err)
+	}
+
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = "groups"
+	}
+
+	return &OIDCProvider{
+		cfg: cfg,
+		oauth: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       []string{oidc.ScopeOpenID, "profile", "email", "groups"},
+		},
+		verifier: provider.Verifier(&oidc.Config{ClientID: cfg.ClientID}),
+	}, nil
+}
+
+// NewOIDCFlow returns the random state, nonce and PKCE code verifier for
+// one login attempt
+func NewOIDCFlow() (state, nonce, verifier string, err error) {
+	if state, err = randomHex(16); err != nil {
+		return "", "", "", err
+	}
+	if nonce, err = randomHex(16); err != nil {
+		return "", "", "", err
+	}
+	return state, nonce, oauth2.GenerateVerifier(), nil
+}
+
+// AuthCodeURL returns the provider URL to send the browser to
+func (p *OIDCProvider) AuthCodeURL(state, nonce, verifier string) string {
+	return p.oauth.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier))
+}
+
+// Exchange redeems an authorization code and verifies the returned ID token
+func (p *OIDCProvider) Exchange(ctx context.Context, code, nonce, verifier string) (*OIDCIdentity, error) {
+	token, err := p.oauth.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("token response has no id_token")
+	}
+
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify ID token: %w", err)
+	}
+	if idToken.Nonce != nonce {
+		return nil, errors.New("ID token nonce mismatch")
+	}
+
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("failed to parse ID token claims: %w", err)
+	}
+
+	identity := &OIDCIdentity{Subject: idToken.Subject, Username: idToken.Subject}
+	for _, key := range []string{"preferred_username", "email"} {
+		if v, ok := claims[key].(string); ok && v != "" {
+			identity.Username = v
+			break
+		}
+	}
+
+	switch groups := claims[p.cfg.GroupsClaim].(type) {
+	case []interface{}:
+		for _, g := range groups {
+			if s, ok := g.(string); ok {
+				identity.Groups = append(identity.Groups, s)
+			}
+		}
+	case string:
+		identity.Groups = strings.Fields(groups)
+	}
+
+	return identity, nil
+}
+
+// MapRole returns the most privileged role granted by the user's groups
+func (p *OIDCProvider) MapRole(groups []string) (Role, error) {
+	best := -1
+	for _, group := range groups {
+		role, ok := p.cfg.GroupRoles[group]
+		if !ok {
+			continue
+		}
+		for i, r := range Roles {
+			if r == role && i > best {
+				best = i
+			}
+		}
+	}
+
+	if best >= 0 {
+		return Roles[best], nil
+	}
+	if p.cfg.DefaultRole != "" {
+		return p.cfg.DefaultRole, nil
+	}
+	return "", ErrNoRole
+}
+
+// ParseGroupRoles parses a "group=role,group=role" mapping
+func ParseGroupRoles(s string) (map[string]Role, error) {
+	mapping := make(map[string]Role)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		group, role, ok := strings.Cut(pair, "=")
+		if !ok || !ValidRole(strings.TrimSpace(role)) {
+			return nil, fmt.Errorf("invalid group role mapping %q", pair)
+		}
+		mapping[strings.TrimSpace(group)] = Role(strings.TrimSpace(role))
+	}
+	return mapping, nil
+}
@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MinPasswordLength is the shortest password accepted for an account
+const MinPasswordLength = 8
+
+// HashPassword returns the bcrypt hash of a password
+func HashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength {
+		return "", fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	return string(hash), nil
+}
+
+// CheckPassword reports whether password matches the stored hash
+func CheckPassword(hash, password string) bool {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	return err == nil
+}
+
+// dummyHash is compared against when a user does not exist
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("cronnor-dummy-password"), bcrypt.DefaultCost)
+
+// CheckMissingUser spends the same time as CheckPassword, so failed logins
+// don't reveal whether a username exists
+func CheckMissingUser(password string) {
+	bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+}
@@ -0,0 +1,85 @@
+package auth
+
+// Role is a web UI user's role
+type Role string
+
+// User roles, from least to most privileged
+const (
+	RoleViewer   Role = "viewer"
+	RoleOperator Role = "operator"
+	RoleEditor   Role = "editor"
+	RoleAdmin    Role = "admin"
+)
+
+// Roles lists every role, in display order
+var Roles = []Role{RoleViewer, RoleOperator, RoleEditor, RoleAdmin}
+
+// Permission is an action a role may be allowed to perform
+type Permission string
+
+// Permissions checked by HTTP handlers and templates
+const (
+	PermViewJobs       Permission = "jobs.view"
+	PermRunJobs        Permission = "jobs.run"
+	PermToggleJobs     Permission = "jobs.toggle"
+	PermEditJobs       Permission = "jobs.edit"
+	PermManageTokens   Permission = "tokens.manage"
+	PermManageUsers    Permission = "users.manage"
+	PermViewAudit      Permission = "audit.view"
+	PermManageChannels Permission = "channels.manage"
+)
+
+// rolePermissions lists what each role may do
+var rolePermissions = map[Role][]Permission{
+	RoleViewer:   {PermViewJobs, PermManageTokens},
+	RoleOperator: {PermViewJobs, PermManageTokens, PermRunJobs, PermToggleJobs},
+	RoleEditor:   {PermViewJobs, PermManageTokens, PermRunJobs, PermToggleJobs, PermEditJobs},
+	RoleAdmin:    {PermViewJobs, PermManageTokens, PermRunJobs, PermToggleJobs, PermEditJobs, PermManageUsers, PermViewAudit, PermManageChannels},
+}
+
+// permissionScopes maps a permission to the API token scope that grants it
+var permissionScopes = map[Permission]string{
+	PermViewJobs:       ScopeJobsRead,
+	PermRunJobs:        ScopeJobsRun,
+	PermToggleJobs:     ScopeJobsWrite,
+	PermEditJobs:       ScopeJobsWrite,
+	PermManageTokens:   ScopeAdmin,
+	PermManageUsers:    ScopeAdmin,
+	PermViewAudit:      ScopeAdmin,
+	PermManageChannels: ScopeAdmin,
+}
+
+// ValidRole reports whether role is a known role
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[Role(role)]
+	return ok
+}
+
+// Can reports whether the role has a permission
+func (r Role) Can(perm Permission) bool {
+	for _, p := range rolePermissions[r] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// ScopeFor returns the API token scope required for a permission
+func ScopeFor(perm Permission) string {
+	return permissionScopes[perm]
+}
+
+// RoleScopes returns the token scopes a role can make use of
+func RoleScopes(role Role) []string {
+	var scopes []string
+	for _, scope := range Scopes {
+		for _, perm := range rolePermissions[role] {
+			if permissionScopes[perm] == scope && (scope != ScopeAdmin || role == RoleAdmin) {
+				scopes = append(scopes, scope)
+				break
+			}
+		}
+	}
+	return scopes
+}
@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// API token scopes
+const (
+	ScopeJobsRead  = "jobs:read"
+	ScopeJobsWrite = "jobs:write"
+	ScopeJobsRun   = "jobs:run"
+	ScopeAdmin     = "admin"
+)
+
+// Scopes lists every scope a token can be granted, in display order
+var Scopes = []string{ScopeJobsRead, ScopeJobsWrite, ScopeJobsRun, ScopeAdmin}
+
+// TokenPrefix marks Cronnor API tokens so they are easy to recognise in secret scanners
+const TokenPrefix = "cnr_"
+
+// GenerateToken creates a new random API token. It returns the plaintext
+// token, which must only be shown once, its hash and a short display prefix.
+func GenerateToken() (plaintext, hash, prefix string, err error) {
+	secret, err := randomHex(32)
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	plaintext = TokenPrefix + secret
+	return plaintext, HashToken(plaintext), plaintext[:len(TokenPrefix)+8], nil
+}
+
+// GenerateSessionID creates a new random session ID and its hash
+func GenerateSessionID() (id, hash string, err error) {
+	id, err = randomHex(32)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate session ID: %w", err)
+	}
+
+	return id, HashToken(id), nil
+}
+
+// GenerateLoginID creates a random ID that ties a login form to the browser
+// that loaded it, before there is a session
+func GenerateLoginID() (string, error) {
+	id, err := randomHex(32)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate login ID: %w", err)
+	}
+	return id, nil
+}
+
+// WebhookTokenPrefix marks job trigger tokens
+const WebhookTokenPrefix = "whk_"
+
+// GenerateWebhookToken creates a new random job trigger token and its hash
+func GenerateWebhookToken() (token, hash string, err error) {
+	secret, err := randomHex(24)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate webhook token: %w", err)
+	}
+
+	token = WebhookTokenPrefix + secret
+	return token, HashToken(token), nil
+}
+
+// GeneratePingToken creates a new random heartbeat ping token and its hash
+func GeneratePingToken() (token, hash string, err error) {
+	token, err = randomHex(16)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate ping token: %w", err)
+	}
+	return token, HashToken(token), nil
+}
+
+// GenerateWebhookSecret creates a new random key for signing trigger calls
+func GenerateWebhookSecret() (string, error) {
+	secret, err := randomHex(32)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return secret, nil
+}
+
+// SignatureHeader carries the HMAC of a trigger call's body
+const SignatureHeader = "X-Cronnor-Signature"
+
+// Sign returns the signature header value for body: "sha256=" followed by
+// the hex HMAC-SHA256 of body keyed with secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// CheckSignature reports whether signature is body's signature under secret
+func CheckSignature(secret string, body []byte, signature string) bool {
+	if !strings.HasPrefix(signature, "sha256=") {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+// randomHex returns n random bytes, hex encoded
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// HashToken returns the hash stored for a plaintext token or session ID
+func HashToken(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
+
+// ValidScope reports whether scope is a known scope
+func ValidScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope reports whether granted includes scope; admin grants every scope
+func HasScope(granted []string, scope string) bool {
+	for _, s := range granted {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
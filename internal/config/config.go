@@ -2,6 +2,8 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"time"
 )
 
 // Config holds application configuration
@@ -9,6 +11,41 @@ type Config struct {
 	Port          string
 	DBPath        string
 	MigrationPath string
+	SessionTTL    time.Duration
+	CookieSecure  bool
+	AdminUsername string
+	AdminPassword string
+
+	// Deleted jobs are purged after this long in the trash; zero keeps them
+	TrashRetention time.Duration
+
+	// OpenID Connect single sign-on, enabled when OIDCIssuer is set
+	OIDCIssuer       string
+	OIDCClientID     string
+	OIDCClientSecret string
+	OIDCRedirectURL  string
+	OIDCGroupsClaim  string
+	OIDCRoleMapping  string
+	OIDCDefaultRole  string
+
+	// SMTP server used by email notification channels, enabled when SMTPHost
+	// is set
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+
+	// Prometheus metrics: the per-job label (name, team or none) and, if set,
+	// the bearer token scrapers must send
+	MetricsJobLabel string
+	MetricsToken    string
+
+	// OpenTelemetry tracing: the exporter (otlp or none) and the OTLP
+	// protocol. The exporter reads its endpoint and headers from the standard
+	// OTEL_EXPORTER_OTLP_* variables itself.
+	TracesExporter string
+	TracesProtocol string
 }
 
 // Load loads configuration from environment variables
@@ -16,10 +53,51 @@ func Load() *Config {
 	return &Config{
 		Port:          getEnv("PORT", "8080"),
 		DBPath:        getEnv("DB_PATH", "./data/cronnor.db"),
-		MigrationPath: getEnv("MIGRATION_PATH", "./migrations/001_initial_schema.sql"),
+		MigrationPath: getEnv("MIGRATION_PATH", "./migrations"),
+		SessionTTL:    getEnvDuration("SESSION_TTL", 7*24*time.Hour),
+		CookieSecure:  getEnvBool("COOKIE_SECURE", false),
+		AdminUsername: getEnv("ADMIN_USERNAME", ""),
+		AdminPassword: getEnv("ADMIN_PASSWORD", ""),
+
+		TrashRetention: time.Duration(getEnvInt("TRASH_RETENTION_DAYS", 30)) * 24 * time.Hour,
+
+		OIDCIssuer:       getEnv("OIDC_ISSUER", ""),
+		OIDCClientID:     getEnv("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret: getEnv("OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:  getEnv("OIDC_REDIRECT_URL", ""),
+		OIDCGroupsClaim:  getEnv("OIDC_GROUPS_CLAIM", "groups"),
+		OIDCRoleMapping:  getEnv("OIDC_ROLE_MAPPING", ""),
+		OIDCDefaultRole:  getEnv("OIDC_DEFAULT_ROLE", ""),
+
+		SMTPHost:     getEnv("SMTP_HOST", ""),
+		SMTPPort:     getEnvInt("SMTP_PORT", 587),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:     getEnv("SMTP_FROM", "cronnor@localhost"),
+
+		MetricsJobLabel: getEnv("METRICS_JOB_LABEL", "none"),
+		MetricsToken:    getEnv("METRICS_TOKEN", ""),
+
+		TracesExporter: tracesExporter(),
+		TracesProtocol: getEnv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL", getEnv("OTEL_EXPORTER_OTLP_PROTOCOL", "http/protobuf")),
 	}
 }
 
+// tracesExporter picks the trace exporter: none when the SDK is disabled,
+// then OTEL_TRACES_EXPORTER, and otherwise otlp only if an OTLP endpoint is
+// set, so tracing is off by default
+func tracesExporter() string {
+	switch {
+	case getEnvBool("OTEL_SDK_DISABLED", false):
+		return "none"
+	case getEnv("OTEL_TRACES_EXPORTER", "") != "":
+		return getEnv("OTEL_TRACES_EXPORTER", "")
+	case getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", "") != "" || getEnv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "") != "":
+		return "otlp"
+	}
+	return "none"
+}
+
 // getEnv gets an environment variable with a default value
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -27,3 +105,27 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvInt gets a non-negative integer environment variable with a default value
+func getEnvInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil && value >= 0 {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvBool gets a boolean environment variable with a default value
+func getEnvBool(key string, defaultValue bool) bool {
+	if value, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvDuration gets a duration environment variable (e.g. "12h") with a default value
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, err := time.ParseDuration(os.Getenv(key)); err == nil && value > 0 {
+		return value
+	}
+	return defaultValue
+}
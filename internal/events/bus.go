@@ -0,0 +1,78 @@
+package events
+
+import "sync"
+
+// Event types published on the bus
+const (
+	JobChanged        = "job.changed"
+	ExecutionStarted  = "execution.started"
+	ExecutionFinished = "execution.finished"
+)
+
+// subscriberBuffer is how far a subscriber may fall behind before events
+// are dropped for it
+const subscriberBuffer = 64
+
+// Event describes something that happened to a job
+type Event struct {
+	Type   string `json:"type"`
+	JobID  int64  `json:"job_id"`
+	LogID  int64  `json:"log_id,omitempty"` // log entry of a finished execution
+	Status string `json:"status,omitempty"` // outcome of a finished execution
+}
+
+// Bus fans events out to in-process subscribers. Publishing never blocks;
+// a nil *Bus discards events.
+type Bus struct {
+	mu   sync.Mutex
+	subs map[chan Event]struct{}
+}
+
+// NewBus creates an event bus without subscribers
+func NewBus() *Bus {
+	return &Bus{subs: make(map[chan Event]struct{})}
+}
+
+// Publish sends an event to every subscriber, skipping those whose buffer
+// is full
+func (b *Bus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel receiving every event published from now on
+// and a function that ends the subscription and closes the channel
+func (b *Bus) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Subscribers returns the number of active subscriptions
+func (b *Bus) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
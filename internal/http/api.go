@@ -0,0 +1,746 @@
+package http
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rauche/cronnor/internal/auth"
+	"github.com/rauche/cronnor/internal/jobs"
+	"github.com/rauche/cronnor/internal/models"
+	"github.com/rauche/cronnor/internal/storage"
+)
+
+// apiJob is the JSON representation of a job
+type apiJob struct {
+	ID          int64      `json:"id"`
+	Name        string     `json:"name"`
+	CronExpr    string     `json:"cron_expr"`
+	URL         string     `json:"url"`
+	Method      string     `json:"method"`
+	Payload     *string    `json:"payload"`
+	IsActive    bool       `json:"is_active"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastRunAt   *time.Time `json:"last_run_at"`
+	LastStatus  *string    `json:"last_status"`
+	Revision    int64      `json:"revision"`
+	Team        string     `json:"team"`
+	Tags        []string   `json:"tags"`
+	Webhook     bool       `json:"webhook"`
+	WebhookHMAC bool       `json:"webhook_hmac"`
+	Kind        string     `json:"kind"`
+	Grace       *int64     `json:"grace_seconds"`
+	PingURL     *string    `json:"ping_url"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	PurgeAt     *time.Time `json:"purge_at,omitempty"`
+}
+
+// apiJobLog is the JSON representation of an execution log entry
+type apiJobLog struct {
+	ID           int64      `json:"id"`
+	JobID        int64      `json:"job_id"`
+	Status       string     `json:"status"`
+	Trigger      string     `json:"trigger"`
+	Actor        *string    `json:"actor"`
+	ScheduledAt  *time.Time `json:"scheduled_at"`
+	StartedAt    *time.Time `json:"started_at"`
+	LagMs        *int64     `json:"lag_ms"`
+	TraceID      *string    `json:"trace_id"`
+	HTTPCode     *int64     `json:"http_code"`
+	DurationMs   *int64     `json:"duration_ms"`
+	ResponseBody *string    `json:"response_body"`
+	ErrorMessage *string    `json:"error_message"`
+	JobRevision  *int64     `json:"job_revision"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// apiJobRevision is the JSON representation of a job revision
+type apiJobRevision struct {
+	Revision  int64                `json:"revision"`
+	Name      string               `json:"name"`
+	CronExpr  string               `json:"cron_expr"`
+	URL       string               `json:"url"`
+	Method    string               `json:"method"`
+	Payload   *string              `json:"payload"`
+	Team      string               `json:"team"`
+	Tags      []string             `json:"tags"`
+	Grace     int64                `json:"grace_seconds"`
+	CreatedAt time.Time            `json:"created_at"`
+	Changes   []models.FieldChange `json:"changes"`
+}
+
+// apiJobRequest is the request body for creating or updating a job
+type apiJobRequest struct {
+	Kind     string   `json:"kind"`
+	Name     string   `json:"name"`
+	CronExpr string   `json:"cron_expr"`
+	URL      string   `json:"url"`
+	Method   string   `json:"method"`
+	Payload  *string  `json:"payload"`
+	Team     string   `json:"team"`
+	Tags     []string `json:"tags"`
+	Grace    int64    `json:"grace_seconds"`
+}
+
+// apiBulkRequest is the request body for applying an action to several jobs
+type apiBulkRequest struct {
+	Action string   `json:"action"`
+	IDs    []int64  `json:"ids"`
+	Tags   []string `json:"tags"`
+	Host   string   `json:"host"`
+}
+
+// apiError is the error body returned by every API route
+type apiError struct {
+	Error  string            `json:"error"`
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+func newAPIJob(job models.Job) apiJob {
+	resp := apiJob{
+		ID:          job.ID,
+		Name:        job.Name,
+		CronExpr:    job.CronExpr,
+		URL:         job.URL,
+		Method:      job.Method,
+		Payload:     nullString(job.Payload),
+		IsActive:    job.IsActive,
+		CreatedAt:   job.CreatedAt,
+		LastRunAt:   nullTime(job.LastRunAt),
+		LastStatus:  nullString(job.LastStatus),
+		Revision:    job.Revision,
+		Team:        job.Team,
+		Tags:        job.Tags,
+		Webhook:     job.Webhook,
+		WebhookHMAC: job.WebhookHMAC,
+		Kind:        job.Kind,
+		DeletedAt:   nullTime(job.DeletedAt),
+	}
+	if resp.Tags == nil {
+		resp.Tags = []string{}
+	}
+	if job.IsHeartbeat() {
+		grace := int64(job.Grace / time.Second)
+		resp.Grace = &grace
+	}
+	return resp
+}
+
+func newAPIJobs(list []models.Job) []apiJob {
+	resp := make([]apiJob, 0, len(list))
+	for _, job := range list {
+		resp = append(resp, newAPIJob(job))
+	}
+	return resp
+}
+
+func newAPIJobLog(log models.JobLog) apiJobLog {
+	return apiJobLog{
+		ID:           log.ID,
+		JobID:        log.JobID,
+		Status:       log.Status,
+		Trigger:      log.Trigger,
+		Actor:        nullString(sql.NullString{String: log.Actor, Valid: log.Actor != ""}),
+		ScheduledAt:  nullTime(log.ScheduledAt),
+		StartedAt:    nullTime(log.StartedAt),
+		LagMs:        nullInt64(log.LagMs),
+		TraceID:      nullString(log.TraceID),
+		HTTPCode:     nullInt64(log.HTTPCode),
+		DurationMs:   nullInt64(log.DurationMs),
+		ResponseBody: nullString(log.ResponseBody),
+		ErrorMessage: nullString(log.ErrorMessage),
+		JobRevision:  nullInt64(log.JobRevision),
+		CreatedAt:    log.CreatedAt,
+	}
+}
+
+func nullString(v sql.NullString) *string {
+	if !v.Valid {
+		return nil
+	}
+	return &v.String
+}
+
+func nullInt64(v sql.NullInt64) *int64 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Int64
+}
+
+func nullTime(v sql.NullTime) *time.Time {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Time
+}
+
+// input returns the request as a job configuration
+func (req apiJobRequest) input() models.JobInput {
+	in := models.JobInput{
+		Kind:     req.Kind,
+		Name:     req.Name,
+		CronExpr: req.CronExpr,
+		URL:      req.URL,
+		Method:   req.Method,
+		Team:     req.Team,
+		Tags:     req.Tags,
+		Grace:    time.Duration(req.Grace) * time.Second,
+	}
+	if req.Payload != nil {
+		in.Payload = *req.Payload
+	}
+	return in
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeAPIError writes a JSON error response
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, apiError{Error: message})
+}
+
+// decodeJSON decodes the request body into v, rejecting unknown fields
+func decodeJSON(r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// apiJobID parses the job ID URL parameter, writing an error if it is invalid
+func apiJobID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid job ID")
+		return 0, false
+	}
+	return id, true
+}
+
+// writeJobError maps a repository error to an API error response
+func writeJobError(w http.ResponseWriter, err error, message string) {
+	if errors.Is(err, storage.ErrJobNotFound) {
+		writeAPIError(w, http.StatusNotFound, "job not found")
+		return
+	}
+	if errors.Is(err, jobs.ErrNotRunnable) {
+		writeAPIError(w, http.StatusConflict, "heartbeat jobs cannot be run")
+		return
+	}
+	if errors.Is(err, jobs.ErrNotHeartbeat) {
+		writeAPIError(w, http.StatusConflict, "only heartbeat jobs have a ping URL")
+		return
+	}
+	writeAPIError(w, http.StatusInternalServerError, message)
+}
+
+// setupAPIRoutes configures the versioned JSON API routes
+func (s *Server) setupAPIRoutes(r chi.Router) {
+	r.Use(s.apiTokenAuth)
+
+	read := r.With(requirePermission(auth.PermViewJobs))
+	read.Get("/jobs", s.handleAPIListJobs)
+	read.Get("/jobs/{id}", s.handleAPIGetJob)
+	read.Get("/jobs/{id}/logs", s.handleAPIJobLogs)
+	read.Get("/jobs/{id}/stats", s.handleAPIJobStats)
+	read.Get("/jobs/{id}/revisions", s.handleAPIJobRevisions)
+	read.Get("/jobs/{id}/alert-rules", s.handleAPIAlertRules)
+	read.Get("/jobs/{id}/alerts", s.handleAPIAlerts)
+	read.Get("/channels", s.handleAPIChannels)
+	read.Get("/trash", s.handleAPITrash)
+
+	edit := r.With(requirePermission(auth.PermEditJobs))
+	edit.Post("/jobs", s.handleAPICreateJob)
+	edit.Put("/jobs/{id}", s.handleAPIUpdateJob)
+	edit.Delete("/jobs/{id}", s.handleAPIDeleteJob)
+	edit.Post("/jobs/{id}/revisions/{revision}/restore", s.handleAPIRestoreRevision)
+	edit.Post("/jobs/{id}/webhook", s.handleAPICreateWebhook)
+	edit.Delete("/jobs/{id}/webhook", s.handleAPIDeleteWebhook)
+	edit.Post("/jobs/{id}/ping-url", s.handleAPICreatePingURL)
+	edit.Post("/jobs/{id}/alert-rules", s.handleAPICreateAlertRule)
+	edit.Delete("/jobs/{id}/alert-rules/{rule}", s.handleAPIDeleteAlertRule)
+	edit.Post("/trash/{id}/restore", s.handleAPIRestoreJob)
+	edit.Delete("/trash/{id}", s.handleAPIPurgeJob)
+
+	toggle := r.With(requirePermission(auth.PermToggleJobs))
+	toggle.Post("/jobs/{id}/toggle", s.handleAPIToggleJob)
+	toggle.Post("/jobs/bulk", s.handleAPIBulkJobs) // Each action checks its own permission
+	toggle.Post("/tags/{tag}/pause", s.handleAPIPauseTag)
+	toggle.Post("/tags/{tag}/resume", s.handleAPIResumeTag)
+
+	run := r.With(requirePermission(auth.PermRunJobs))
+	run.Post("/jobs/{id}/run", s.handleAPIRunJob)
+	run.Post("/tags/{tag}/run", s.handleAPIRunTag)
+
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, http.StatusNotFound, "not found")
+	})
+	r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+	})
+}
+
+// handleAPIListJobs returns all jobs, optionally filtered by tag and team
+func (s *Server) handleAPIListJobs(w http.ResponseWriter, r *http.Request) {
+	list, err := s.repo.GetAllJobs(jobFilterFromRequest(r))
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "failed to load jobs")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newAPIJobs(list))
+}
+
+// handleAPIGetJob returns a single job
+func (s *Server) handleAPIGetJob(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiJobID(w, r)
+	if !ok {
+		return
+	}
+
+	job, err := s.repo.GetJob(id)
+	if err != nil {
+		writeJobError(w, err, "failed to load job")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newAPIJob(*job))
+}
+
+// handleAPICreateJob creates a new job
+func (s *Server) handleAPICreateJob(w http.ResponseWriter, r *http.Request) {
+	var req apiJobRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return
+	}
+
+	in := req.input()
+	if fields := jobs.ValidateJob(&in); len(fields) > 0 {
+		writeJSON(w, http.StatusUnprocessableEntity, apiError{Error: "validation failed", Fields: fields})
+		return
+	}
+
+	// Heartbeat jobs get a ping URL, shown only in this response
+	params := in.CreateParams()
+	var pingToken string
+	if in.Kind == models.JobKindHeartbeat {
+		var err error
+		if pingToken, params.PingTokenHash, err = auth.GeneratePingToken(); err != nil {
+			writeAPIError(w, http.StatusInternalServerError, "failed to create job")
+			return
+		}
+	}
+
+	id, err := s.repo.CreateJob(params)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "failed to create job")
+		return
+	}
+
+	job, err := s.repo.GetJob(id)
+	if err != nil {
+		writeJobError(w, err, "failed to load job")
+		return
+	}
+	s.scheduler.AddJob(*job)
+	s.auditJob(r, models.AuditJobCreate, nil, job)
+
+	resp := newAPIJob(*job)
+	if pingToken != "" {
+		path := pingPath(pingToken)
+		resp.PingURL = &path
+	}
+	w.Header().Set("Location", "/api/v1/jobs/"+strconv.FormatInt(id, 10))
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+// handleAPIUpdateJob replaces a job's configuration
+func (s *Server) handleAPIUpdateJob(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiJobID(w, r)
+	if !ok {
+		return
+	}
+
+	var req apiJobRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return
+	}
+
+	before, err := s.repo.GetJob(id)
+	if err != nil {
+		writeJobError(w, err, "failed to load job")
+		return
+	}
+
+	// A job's kind is fixed when it is created
+	in := req.input()
+	if in.Kind != "" && in.Kind != before.Kind {
+		writeJSON(w, http.StatusUnprocessableEntity, apiError{Error: "validation failed", Fields: map[string]string{"kind": "cannot be changed"}})
+		return
+	}
+	in.Kind = before.Kind
+	if fields := jobs.ValidateJob(&in); len(fields) > 0 {
+		writeJSON(w, http.StatusUnprocessableEntity, apiError{Error: "validation failed", Fields: fields})
+		return
+	}
+
+	if err := s.repo.UpdateJob(in.UpdateParams(id)); err != nil {
+		writeJobError(w, err, "failed to update job")
+		return
+	}
+
+	s.scheduler.ReloadJob(id)
+	s.respondAuditedJob(w, r, models.AuditJobUpdate, before)
+}
+
+// handleAPIDeleteJob deletes a job
+func (s *Server) handleAPIDeleteJob(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiJobID(w, r)
+	if !ok {
+		return
+	}
+
+	job, err := s.repo.GetJob(id)
+	if err != nil {
+		writeJobError(w, err, "failed to load job")
+		return
+	}
+
+	if err := s.repo.DeleteJob(id); err != nil {
+		writeJobError(w, err, "failed to delete job")
+		return
+	}
+	s.scheduler.RemoveJob(id)
+	s.auditJob(r, models.AuditJobDelete, job, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAPIToggleJob toggles a job's active status and returns the job
+func (s *Server) handleAPIToggleJob(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiJobID(w, r)
+	if !ok {
+		return
+	}
+
+	before, err := s.repo.GetJob(id)
+	if err != nil {
+		writeJobError(w, err, "failed to load job")
+		return
+	}
+
+	if err := s.repo.ToggleJob(id); err != nil {
+		writeJobError(w, err, "failed to toggle job")
+		return
+	}
+
+	s.scheduler.ReloadJob(id)
+	s.respondAuditedJob(w, r, models.AuditJobToggle, before)
+}
+
+// handleAPIRunJob starts an immediate execution of a job
+func (s *Server) handleAPIRunJob(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiJobID(w, r)
+	if !ok {
+		return
+	}
+
+	job, err := s.repo.GetJob(id)
+	if err != nil {
+		writeJobError(w, err, "failed to load job")
+		return
+	}
+
+	if err := s.scheduler.ExecuteNow(id, manualTrigger(r)); err != nil {
+		writeJobError(w, err, "failed to execute job")
+		return
+	}
+	s.auditJob(r, models.AuditJobRun, job, job)
+
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "started"})
+}
+
+// handleAPIBulkJobs applies an action to several jobs in one transaction and
+// returns the affected jobs as they are now
+func (s *Server) handleAPIBulkJobs(w http.ResponseWriter, r *http.Request) {
+	var req apiBulkRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return
+	}
+
+	params := models.BulkJobParams{Action: req.Action, IDs: req.IDs, Tags: req.Tags, Host: req.Host}
+	if fields := validateBulk(&params); len(fields) > 0 {
+		writeJSON(w, http.StatusUnprocessableEntity, apiError{Error: "validation failed", Fields: fields})
+		return
+	}
+
+	if !checkPermission(w, r, bulkPermissions[params.Action]) {
+		return
+	}
+
+	jobs, err := s.bulkUpdateJobs(r, params)
+	if err != nil {
+		writeJobError(w, err, "failed to update jobs")
+		return
+	}
+
+	switch params.Action {
+	case models.BulkDelete:
+		w.WriteHeader(http.StatusNoContent)
+	case models.BulkRun:
+		writeJSON(w, http.StatusAccepted, newAPIJobs(jobs))
+	default:
+		writeJSON(w, http.StatusOK, newAPIJobs(jobs))
+	}
+}
+
+// handleAPIPauseTag disables every job carrying a tag and returns the jobs
+// that were paused
+func (s *Server) handleAPIPauseTag(w http.ResponseWriter, r *http.Request) {
+	changed, err := s.setTagActive(r, tagParam(r), false)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "failed to pause jobs")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newAPIJobs(changed))
+}
+
+// handleAPIResumeTag enables every job carrying a tag and returns the jobs
+// that were resumed
+func (s *Server) handleAPIResumeTag(w http.ResponseWriter, r *http.Request) {
+	changed, err := s.setTagActive(r, tagParam(r), true)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "failed to resume jobs")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newAPIJobs(changed))
+}
+
+// handleAPIRunTag starts an immediate execution of every job carrying a tag
+// and returns the jobs that were started
+func (s *Server) handleAPIRunTag(w http.ResponseWriter, r *http.Request) {
+	started, err := s.runTag(r, tagParam(r))
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "failed to execute jobs")
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, newAPIJobs(started))
+}
+
+// respondAuditedJob records a change to a job and returns the updated job
+func (s *Server) respondAuditedJob(w http.ResponseWriter, r *http.Request, action string, before *models.Job) {
+	after, err := s.repo.GetJob(before.ID)
+	if err != nil {
+		writeJobError(w, err, "failed to load job")
+		return
+	}
+	s.auditJob(r, action, before, after)
+
+	writeJSON(w, http.StatusOK, newAPIJob(*after))
+}
+
+// handleAPIJobLogs returns the most recent execution logs of a job
+func (s *Server) handleAPIJobLogs(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiJobID(w, r)
+	if !ok {
+		return
+	}
+
+	filter, fields := jobLogFilter(r, 50)
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 1000 {
+			fields["limit"] = "must be an integer between 1 and 1000"
+		}
+		filter.Limit = n
+	}
+	if len(fields) > 0 {
+		writeJSON(w, http.StatusUnprocessableEntity, apiError{Error: "validation failed", Fields: fields})
+		return
+	}
+
+	if _, err := s.repo.GetJob(id); err != nil {
+		writeJobError(w, err, "failed to load job")
+		return
+	}
+
+	logs, err := s.repo.FindJobLogs(id, filter)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "failed to load logs")
+		return
+	}
+
+	resp := make([]apiJobLog, 0, len(logs))
+	for _, log := range logs {
+		resp = append(resp, newAPIJobLog(log))
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleAPIJobStats returns execution statistics for a job
+func (s *Server) handleAPIJobStats(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiJobID(w, r)
+	if !ok {
+		return
+	}
+
+	window := r.URL.Query().Get("window")
+	if window == "" {
+		window = storage.StatsWindows[0]
+	}
+	if !storage.ValidStatsWindow(window) {
+		writeJSON(w, http.StatusUnprocessableEntity, apiError{
+			Error:  "validation failed",
+			Fields: map[string]string{"window": "must be one of " + strings.Join(storage.StatsWindows, ", ")},
+		})
+		return
+	}
+
+	if _, err := s.repo.GetJob(id); err != nil {
+		writeJobError(w, err, "failed to load job")
+		return
+	}
+
+	stats, err := s.repo.GetJobStats(id, window)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "failed to load stats")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// handleAPIJobRevisions returns every revision of a job, newest first
+func (s *Server) handleAPIJobRevisions(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiJobID(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := s.repo.GetJob(id); err != nil {
+		writeJobError(w, err, "failed to load job")
+		return
+	}
+
+	revisions, err := s.repo.GetJobRevisions(id)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "failed to load revisions")
+		return
+	}
+
+	resp := make([]apiJobRevision, 0, len(revisions))
+	for _, rev := range revisionHistory(revisions) {
+		item := apiJobRevision{
+			Revision:  rev.Revision,
+			Name:      rev.Name,
+			CronExpr:  rev.CronExpr,
+			URL:       rev.URL,
+			Method:    rev.Method,
+			Payload:   nullString(rev.Payload),
+			Team:      rev.Team,
+			Tags:      rev.Tags,
+			Grace:     int64(rev.Grace / time.Second),
+			CreatedAt: rev.CreatedAt,
+			Changes:   rev.Changes,
+		}
+		if item.Tags == nil {
+			item.Tags = []string{}
+		}
+		resp = append(resp, item)
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleAPIRestoreRevision sets a job back to an earlier revision
+func (s *Server) handleAPIRestoreRevision(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiJobID(w, r)
+	if !ok {
+		return
+	}
+	revision, err := strconv.ParseInt(chi.URLParam(r, "revision"), 10, 64)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid revision")
+		return
+	}
+
+	job, err := s.restoreRevision(r, id, revision)
+	if err != nil {
+		if errors.Is(err, storage.ErrRevisionNotFound) {
+			writeAPIError(w, http.StatusNotFound, "revision not found")
+			return
+		}
+		writeJobError(w, err, "failed to restore revision")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newAPIJob(*job))
+}
+
+// handleAPITrash returns the jobs in the trash, most recently deleted first
+func (s *Server) handleAPITrash(w http.ResponseWriter, r *http.Request) {
+	trashed, err := s.trashedJobs()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "failed to load trash")
+		return
+	}
+
+	resp := make([]apiJob, 0, len(trashed))
+	for _, t := range trashed {
+		job := newAPIJob(t.Job)
+		if !t.PurgeAt.IsZero() {
+			job.PurgeAt = &t.PurgeAt
+		}
+		resp = append(resp, job)
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleAPIRestoreJob takes a job out of the trash
+func (s *Server) handleAPIRestoreJob(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiJobID(w, r)
+	if !ok {
+		return
+	}
+
+	job, err := s.restoreJob(r, id)
+	if err != nil {
+		writeJobError(w, err, "failed to restore job")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newAPIJob(*job))
+}
+
+// handleAPIPurgeJob permanently deletes a job in the trash
+func (s *Server) handleAPIPurgeJob(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiJobID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.purgeJob(r, id); err != nil {
+		writeJobError(w, err, "failed to purge job")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
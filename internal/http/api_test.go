@@ -0,0 +1,118 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rauche/cronnor/internal/auth"
+)
+
+func TestAPIJobs(t *testing.T) {
+	s := newTestServer(t)
+	_, token := createTestUser(t, s, auth.RoleAdmin)
+
+	api := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Create
+	rec := api("POST", "/api/v1/jobs", `{"name":"sync","cron_expr":"0 */5 * * * *","url":"http://example.com/sync","method":"POST","payload":"{\"full\":true}","tags":["nightly"]}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+	var created apiJob
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	if loc := rec.Header().Get("Location"); loc != "/api/v1/jobs/1" {
+		t.Errorf("expected Location /api/v1/jobs/1, got %q", loc)
+	}
+	if created.Name != "sync" || created.Method != "POST" || created.Payload == nil || *created.Payload != `{"full":true}` || !created.IsActive {
+		t.Errorf("unexpected created job: %+v", created)
+	}
+
+	// Read it back, alone and in the list
+	var got apiJob
+	json.Unmarshal(api("GET", "/api/v1/jobs/1", "").Body.Bytes(), &got)
+	if got.Name != "sync" || got.CronExpr != "0 */5 * * * *" || len(got.Tags) != 1 || got.Tags[0] != "nightly" {
+		t.Errorf("unexpected job: %+v", got)
+	}
+	var list []apiJob
+	json.Unmarshal(api("GET", "/api/v1/jobs", "").Body.Bytes(), &list)
+	if len(list) != 1 || list[0].ID != 1 {
+		t.Errorf("expected the job in the list, got %+v", list)
+	}
+
+	// Update replaces the configuration
+	rec = api("PUT", "/api/v1/jobs/1", `{"name":"sync-all","cron_expr":"0 0 * * * *","url":"http://example.com/all"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	json.Unmarshal(api("GET", "/api/v1/jobs/1", "").Body.Bytes(), &got)
+	if got.Name != "sync-all" || got.URL != "http://example.com/all" || got.Method != "GET" || got.Payload != nil || len(got.Tags) != 0 {
+		t.Errorf("expected the update to replace the job, got %+v", got)
+	}
+
+	// Validation failures name their fields
+	rec = api("POST", "/api/v1/jobs", `{"name":"","cron_expr":"every day","url":"ftp://example.com"}`)
+	var apiErr apiError
+	json.Unmarshal(rec.Body.Bytes(), &apiErr)
+	if rec.Code != http.StatusUnprocessableEntity || apiErr.Fields["name"] == "" || apiErr.Fields["cron_expr"] == "" || apiErr.Fields["url"] == "" {
+		t.Errorf("invalid job: expected 422 with field errors, got %d: %s", rec.Code, rec.Body)
+	}
+
+	// Bodies must be known JSON within the size limit
+	for name, body := range map[string]string{
+		"unknown field": `{"name":"x","cron_expr":"0 0 * * * *","url":"http://example.com","schedule":"daily"}`,
+		"malformed":     `{"name":`,
+		"too large":     `{"name":"x","cron_expr":"0 0 * * * *","url":"http://example.com","payload":"` + strings.Repeat("a", 1<<20) + `"}`,
+	} {
+		if rec := api("POST", "/api/v1/jobs", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d: %s", name, rec.Code, rec.Body)
+		}
+	}
+	if rec := api("PUT", "/api/v1/jobs/1", `{"name":"sync","cron_expr":"0 0 * * * *","url":"http://example.com","enabled":true}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("update with an unknown field: expected 400, got %d", rec.Code)
+	}
+	json.Unmarshal(api("GET", "/api/v1/jobs", "").Body.Bytes(), &list)
+	if len(list) != 1 {
+		t.Errorf("expected rejected requests to create nothing, got %d jobs", len(list))
+	}
+
+	// Delete, after which the job is gone
+	if rec := api("DELETE", "/api/v1/jobs/1", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete: expected 204, got %d: %s", rec.Code, rec.Body)
+	}
+	for _, req := range [][2]string{
+		{"GET", "/api/v1/jobs/1"},
+		{"PUT", "/api/v1/jobs/1"},
+		{"DELETE", "/api/v1/jobs/1"},
+		{"POST", "/api/v1/jobs/1/toggle"},
+		{"POST", "/api/v1/jobs/1/run"},
+		{"GET", "/api/v1/jobs/99"},
+	} {
+		body := ""
+		if req[0] == "PUT" {
+			body = `{"name":"sync","cron_expr":"0 0 * * * *","url":"http://example.com"}`
+		}
+		rec := api(req[0], req[1], body)
+		json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		if rec.Code != http.StatusNotFound || apiErr.Error != "job not found" {
+			t.Errorf("%s %s: expected 404 job not found, got %d: %s", req[0], req[1], rec.Code, rec.Body)
+		}
+	}
+	if rec := api("GET", "/api/v1/jobs/abc", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid ID: expected 400, got %d", rec.Code)
+	}
+
+	// Heartbeat jobs are pinged, not run
+	api("POST", "/api/v1/jobs", `{"kind":"heartbeat","name":"backup","cron_expr":"0 0 * * * *"}`)
+	if rec := api("POST", "/api/v1/jobs/2/run", ""); rec.Code != http.StatusConflict {
+		t.Errorf("run heartbeat: expected 409, got %d: %s", rec.Code, rec.Body)
+	}
+}
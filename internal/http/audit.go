@@ -0,0 +1,133 @@
+package http
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rauche/cronnor/internal/auth"
+	"github.com/rauche/cronnor/internal/jobs"
+	"github.com/rauche/cronnor/internal/models"
+)
+
+// auditPageLimit caps the entries shown on the audit page
+const auditPageLimit = 200
+
+// auditExportLimit caps the entries in a JSON export
+const auditExportLimit = 10000
+
+// audit records a change made by the request's user in the audit log.
+// Failures are logged rather than failing the request, which already happened.
+func (s *Server) audit(r *http.Request, entry models.AuditEntry) {
+	entry.ActorID, entry.Actor = requestActor(r)
+	if entry.Actor == "" {
+		entry.Actor = "system"
+	}
+	entry.SourceIP = clientIP(r)
+
+	if err := s.repo.CreateAuditEntry(entry); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// requestActor returns the ID and display name of the user behind a request,
+// naming the API token if one was used. Both are empty for anonymous requests.
+func requestActor(r *http.Request) (int64, string) {
+	user := auth.UserFromContext(r.Context())
+	if user == nil {
+		return 0, ""
+	}
+
+	name := user.Username
+	if token := auth.TokenFromContext(r.Context()); token != nil {
+		name += " (token " + token.Prefix + ")"
+	}
+	return user.ID, name
+}
+
+// manualTrigger describes a run started by the request's user
+func manualTrigger(r *http.Request) jobs.Trigger {
+	trigger := jobs.Trigger{Type: models.TriggerManual}
+	trigger.ActorID, trigger.Actor = requestActor(r)
+	return trigger
+}
+
+// auditJob records a change to a job. before is nil for created jobs and
+// after is nil for deleted ones.
+func (s *Server) auditJob(r *http.Request, action string, before, after *models.Job) {
+	job := after
+	if job == nil {
+		job = before
+	}
+
+	s.audit(r, models.AuditEntry{
+		Action:     action,
+		TargetType: "job",
+		TargetID:   job.ID,
+		TargetName: job.Name,
+		Changes:    models.DiffJobs(before, after),
+	})
+}
+
+// clientIP returns the request's source IP address
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// auditFilter builds an audit log filter from query parameters
+func auditFilter(r *http.Request, limit int) models.AuditFilter {
+	q := r.URL.Query()
+	filter := models.AuditFilter{
+		Actor:      q.Get("actor"),
+		Action:     q.Get("action"),
+		TargetType: q.Get("target_type"),
+		Limit:      limit,
+	}
+	if id, err := strconv.ParseInt(q.Get("target_id"), 10, 64); err == nil {
+		filter.TargetID = id
+	}
+	if t, err := time.Parse("2006-01-02", q.Get("since")); err == nil {
+		filter.Since = t
+	}
+	if t, err := time.Parse("2006-01-02", q.Get("until")); err == nil {
+		// Include the whole "until" day
+		filter.Until = t.AddDate(0, 0, 1)
+	}
+	return filter
+}
+
+// handleAudit shows the audit log
+func (s *Server) handleAudit(w http.ResponseWriter, r *http.Request) {
+	entries, err := s.repo.GetAuditEntries(auditFilter(r, auditPageLimit))
+	if err != nil {
+		http.Error(w, "Failed to load audit log", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]interface{}{
+		"Entries": entries,
+		"Actions": models.AuditActions,
+		"Filter":  r.URL.Query(),
+		"Query":   r.URL.RawQuery,
+	}
+
+	s.render(w, r, "audit.html", data)
+}
+
+// handleAuditExport downloads the filtered audit log as JSON
+func (s *Server) handleAuditExport(w http.ResponseWriter, r *http.Request) {
+	entries, err := s.repo.GetAuditEntries(auditFilter(r, auditExportLimit))
+	if err != nil {
+		http.Error(w, "Failed to load audit log", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", `attachment; filename="cronnor-audit.json"`)
+	writeJSON(w, http.StatusOK, entries)
+}
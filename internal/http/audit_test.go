@@ -0,0 +1,84 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/rauche/cronnor/internal/auth"
+	"github.com/rauche/cronnor/internal/models"
+)
+
+func TestAuditRecordsJobChanges(t *testing.T) {
+	s := newTestServer(t)
+	cookie, token := createTestUser(t, s, auth.RoleAdmin)
+
+	body := `{"name":"backup","cron_expr":"0 0 * * * *","url":"http://example.com/a","method":"GET"}`
+	req := httptest.NewRequest("POST", "/api/v1/jobs", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d", rec.Code)
+	}
+	var job apiJob
+	json.NewDecoder(rec.Body).Decode(&job)
+
+	body = `{"name":"backup","cron_expr":"0 0 * * * *","url":"http://example.com/b","method":"GET"}`
+	req = httptest.NewRequest("PUT", "/api/v1/jobs/"+strconv.FormatInt(job.ID, 10), strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.RemoteAddr = "203.0.113.7:5000"
+	rec = httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update: expected 200, got %d", rec.Code)
+	}
+
+	entries, err := s.repo.GetAuditEntries(models.AuditFilter{Action: models.AuditJobUpdate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 update entry, got %d", len(entries))
+	}
+
+	e := entries[0]
+	if !strings.HasPrefix(e.Actor, "admin (token ") || e.SourceIP != "203.0.113.7" || e.TargetID != job.ID {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+	if len(e.Changes) != 1 || e.Changes[0].Field != "url" ||
+		e.Changes[0].Before != "http://example.com/a" || e.Changes[0].After != "http://example.com/b" {
+		t.Errorf("expected only the URL to change, got %+v", e.Changes)
+	}
+
+	// The export contains both entries and honours filters
+	req = httptest.NewRequest("GET", "/audit/export?target_type=job", nil)
+	req.AddCookie(cookie)
+	rec = httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	var exported []models.AuditEntry
+	if err := json.NewDecoder(rec.Body).Decode(&exported); err != nil {
+		t.Fatalf("export is not valid JSON: %v", err)
+	}
+	if len(exported) != 2 {
+		t.Errorf("expected 2 exported entries, got %d", len(exported))
+	}
+}
+
+func TestAuditLogIsAppendOnly(t *testing.T) {
+	s := newTestServer(t)
+	if err := s.repo.CreateAuditEntry(models.AuditEntry{Actor: "a", Action: models.AuditJobRun, TargetType: "job"}); err != nil {
+		t.Fatal(err)
+	}
+
+	db := s.repo.DB()
+	if _, err := db.Exec(`UPDATE audit_log SET actor = 'b'`); err == nil {
+		t.Error("expected updating the audit log to fail")
+	}
+	if _, err := db.Exec(`DELETE FROM audit_log`); err == nil {
+		t.Error("expected deleting from the audit log to fail")
+	}
+}
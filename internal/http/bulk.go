@@ -0,0 +1,176 @@
+package http
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rauche/cronnor/internal/auth"
+	"github.com/rauche/cronnor/internal/models"
+	"github.com/rauche/cronnor/internal/storage"
+)
+
+// maxBulkJobs bounds how many jobs one bulk action may change
+const maxBulkJobs = 500
+
+// bulkPermissions maps each bulk action to the permission it requires. The
+// bulk routes only require jobs.toggle, the least of these.
+var bulkPermissions = map[string]auth.Permission{
+	models.BulkEnable:   auth.PermToggleJobs,
+	models.BulkDisable:  auth.PermToggleJobs,
+	models.BulkDelete:   auth.PermEditJobs,
+	models.BulkRun:      auth.PermRunJobs,
+	models.BulkTag:      auth.PermEditJobs,
+	models.BulkRetarget: auth.PermEditJobs,
+}
+
+// bulkAuditActions maps each bulk action to the audit action recorded per job
+var bulkAuditActions = map[string]string{
+	models.BulkEnable:   models.AuditJobToggle,
+	models.BulkDisable:  models.AuditJobToggle,
+	models.BulkDelete:   models.AuditJobDelete,
+	models.BulkRun:      models.AuditJobRun,
+	models.BulkTag:      models.AuditJobUpdate,
+	models.BulkRetarget: models.AuditJobUpdate,
+}
+
+// validateBulk normalizes a bulk action and returns per-field messages
+func validateBulk(params *models.BulkJobParams) map[string]string {
+	fields := make(map[string]string)
+
+	if _, ok := bulkPermissions[params.Action]; !ok {
+		fields["action"] = "must be one of " + strings.Join(models.BulkActions, ", ")
+	}
+
+	if len(params.IDs) == 0 {
+		fields["ids"] = "must select at least one job"
+	} else if len(params.IDs) > maxBulkJobs {
+		fields["ids"] = "must select at most " + strconv.Itoa(maxBulkJobs) + " jobs"
+	}
+
+	switch params.Action {
+	case models.BulkTag:
+		tags, err := models.NormalizeTags(params.Tags)
+		if err != nil {
+			fields["tags"] = err.Error()
+		} else if len(tags) == 0 {
+			fields["tags"] = "is required"
+		}
+		params.Tags = tags
+	case models.BulkRetarget:
+		params.Host = strings.TrimSpace(params.Host)
+		if !validHost(params.Host) {
+			fields["host"] = "must be a host name, optionally with a port"
+		}
+	}
+
+	return fields
+}
+
+// validHost reports whether host is a bare host or host:port
+func validHost(host string) bool {
+	u, err := url.Parse("http://" + host)
+	return host != "" && err == nil && u.Host == host
+}
+
+// bulkUpdateJobs applies a bulk action, reloads the affected scheduler
+// entries and records each change. It returns the jobs as they are now;
+// deleted jobs are left out.
+func (s *Server) bulkUpdateJobs(r *http.Request, params models.BulkJobParams) ([]models.Job, error) {
+	before, err := s.repo.BulkUpdateJobs(params)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.Action == models.BulkRun {
+		for i := range before {
+			if err := s.scheduler.ExecuteNow(before[i].ID, manualTrigger(r)); err != nil {
+				log.Printf("Warning: failed to run job %d: %v", before[i].ID, err)
+				continue
+			}
+			s.auditJob(r, models.AuditJobRun, &before[i], &before[i])
+		}
+		return before, nil
+	}
+
+	ids := make([]int64, len(before))
+	for i, job := range before {
+		ids[i] = job.ID
+	}
+
+	if err := s.scheduler.ReloadJobs(ids); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	after, err := s.repo.GetJobsByID(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	afterByID := make(map[int64]*models.Job, len(after))
+	for i := range after {
+		afterByID[after[i].ID] = &after[i]
+	}
+	for i := range before {
+		s.auditJob(r, bulkAuditActions[params.Action], &before[i], afterByID[before[i].ID])
+	}
+
+	return after, nil
+}
+
+// fieldErrors formats per-field validation messages as one line
+func fieldErrors(fields map[string]string) string {
+	msgs := make([]string, 0, len(fields))
+	for field, msg := range fields {
+		msgs = append(msgs, field+" "+msg)
+	}
+	sort.Strings(msgs)
+	return strings.Join(msgs, "; ")
+}
+
+// handleBulkJobs applies an action to the jobs selected on the dashboard
+func (s *Server) handleBulkJobs(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	params := models.BulkJobParams{
+		Action: r.FormValue("action"),
+		Tags:   strings.Split(r.FormValue("tags"), ","),
+		Host:   r.FormValue("host"),
+	}
+	for _, v := range r.Form["ids"] {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid job ID", http.StatusBadRequest)
+			return
+		}
+		params.IDs = append(params.IDs, id)
+	}
+
+	if fields := validateBulk(&params); len(fields) > 0 {
+		http.Error(w, "Invalid bulk action: "+fieldErrors(fields), http.StatusBadRequest)
+		return
+	}
+
+	if !checkPermission(w, r, bulkPermissions[params.Action]) {
+		return
+	}
+
+	if _, err := s.bulkUpdateJobs(r, params); err != nil {
+		if errors.Is(err, storage.ErrJobNotFound) {
+			http.Error(w, "Job not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to update jobs", http.StatusInternalServerError)
+		return
+	}
+
+	// Return updated job list for HTMX
+	s.handleJobsList(w, r)
+}
@@ -0,0 +1,115 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/rauche/cronnor/internal/auth"
+	"github.com/rauche/cronnor/internal/models"
+)
+
+func TestBulkUpdateJobs(t *testing.T) {
+	s := newTestServer(t)
+	_, token := createTestUser(t, s, auth.RoleEditor)
+	operatorCookie, operatorToken := createTestUser(t, s, auth.RoleOperator)
+
+	api := func(token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/v1/jobs/bulk", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, name := range []string{"a", "b", "c"} {
+		id, err := s.repo.CreateJob(models.CreateJobParams{
+			Name:     name,
+			CronExpr: "0 0 * * * *",
+			URL:      "http://old-host:8080/" + name + "?x=1",
+			Method:   "GET",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		job, _ := s.repo.GetJob(id)
+		s.scheduler.AddJob(*job)
+	}
+
+	rec := api(token, `{"action":"disable","ids":[1,2]}`)
+	var jobs []apiJob
+	json.Unmarshal(rec.Body.Bytes(), &jobs)
+	if rec.Code != http.StatusOK || len(jobs) != 2 || jobs[0].IsActive || jobs[1].IsActive {
+		t.Fatalf("disable: expected 2 inactive jobs, got %d: %s", rec.Code, rec.Body)
+	}
+	if job, _ := s.repo.GetJob(1); job.NextRunAt.Valid {
+		t.Error("disable: the scheduler entry should be removed")
+	}
+	if job, _ := s.repo.GetJob(3); !job.IsActive {
+		t.Error("disable: unselected jobs should not change")
+	}
+
+	entries, err := s.repo.GetAuditEntries(models.AuditFilter{Action: models.AuditJobToggle})
+	if err != nil || len(entries) != 2 {
+		t.Errorf("expected 2 toggle audit entries, got %d (%v)", len(entries), err)
+	}
+
+	if rec := api(token, `{"action":"retarget","ids":[1,3],"host":"new-host:9090"}`); rec.Code != http.StatusOK {
+		t.Fatalf("retarget: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	job, _ := s.repo.GetJob(3)
+	if job.URL != "http://new-host:9090/c?x=1" {
+		t.Errorf("retarget: expected the host to change, got %s", job.URL)
+	}
+	if revisions, _ := s.repo.GetJobRevisions(3); len(revisions) != 2 {
+		t.Errorf("retarget: expected a new revision, got %d revisions", len(revisions))
+	}
+
+	// One unknown job rolls back the whole batch
+	if rec := api(token, `{"action":"tag","ids":[1,2,99],"tags":["moved"]}`); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown job: expected 404, got %d", rec.Code)
+	}
+	if job, _ := s.repo.GetJob(1); len(job.Tags) != 0 {
+		t.Errorf("unknown job: expected no tags to be added, got %v", job.Tags)
+	}
+
+	if rec := api(token, `{"action":"tag","ids":[1,2],"tags":["Moved"]}`); rec.Code != http.StatusOK {
+		t.Errorf("tag: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if jobs, _ := s.repo.GetAllJobs(models.JobFilter{Tag: "moved"}); len(jobs) != 2 {
+		t.Errorf("tag: expected 2 tagged jobs, got %d", len(jobs))
+	}
+
+	if rec := api(token, `{"action":"rename","ids":[]}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("invalid action: expected 422, got %d", rec.Code)
+	}
+
+	// Operators may toggle jobs but not delete them
+	if rec := api(operatorToken, `{"action":"delete","ids":[1]}`); rec.Code != http.StatusForbidden {
+		t.Errorf("operator delete: expected 403, got %d", rec.Code)
+	}
+
+	form := url.Values{"action": {"enable"}, "ids": {"1", "2"}}
+	req := httptest.NewRequest("POST", "/jobs/bulk", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set(csrfHeader, csrfTokenFor(operatorCookie.Value))
+	req.AddCookie(operatorCookie)
+	rec = httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("web enable: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if job, _ := s.repo.GetJob(2); !job.IsActive || !job.NextRunAt.Valid {
+		t.Error("web enable: expected the job to be active and scheduled")
+	}
+
+	if rec := api(token, `{"action":"delete","ids":[1,2]}`); rec.Code != http.StatusNoContent {
+		t.Errorf("delete: expected 204, got %d", rec.Code)
+	}
+	if jobs, _ := s.repo.GetAllJobs(models.JobFilter{}); len(jobs) != 1 {
+		t.Errorf("delete: expected 1 remaining job, got %d", len(jobs))
+	}
+}
@@ -0,0 +1,173 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/rauche/cronnor/internal/auth"
+	"github.com/rauche/cronnor/internal/models"
+)
+
+func TestCSRFProtection(t *testing.T) {
+	tests := []struct {
+		name   string
+		header bool   // send the token in the X-CSRF-Token header
+		field  bool   // send the token in the csrf_token form field
+		token  string // overrides the session's token when set
+		status int
+	}{
+		{"missing token", false, false, "", http.StatusForbidden},
+		{"wrong token", true, false, "forged", http.StatusForbidden},
+		{"header token", true, false, "", http.StatusSeeOther},
+		{"form token", false, true, "", http.StatusSeeOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			cookie, _ := createTestUser(t, s, auth.RoleAdmin)
+			token := tt.token
+			if token == "" {
+				token = csrfTokenFor(cookie.Value)
+			}
+
+			form := url.Values{}
+			if tt.field {
+				form.Set(csrfField, token)
+			}
+			req := httptest.NewRequest("POST", "/logout", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.header {
+				req.Header.Set(csrfHeader, token)
+			}
+			req.AddCookie(cookie)
+
+			rec := httptest.NewRecorder()
+			s.router.ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Errorf("expected %d, got %d", tt.status, rec.Code)
+			}
+		})
+	}
+}
+
+func TestCSRFExemptsTokenAuthenticatedAPI(t *testing.T) {
+	s := newTestServer(t)
+	_, token := createTestUser(t, s, auth.RoleAdmin)
+
+	jobID, err := s.repo.CreateJob(models.CreateJobParams{
+		Name: "probe", CronExpr: "0 0 * * * *", URL: "http://127.0.0.1:1", Method: "GET",
+	})
+	if err != nil {
+		t.Fatalf("failed to create job: %v", err)
+	}
+
+	req := httptest.NewRequest("POST", "/api/v1/jobs/"+strconv.FormatInt(jobID, 10)+"/toggle", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200 for token-authenticated call without CSRF token, got %d", rec.Code)
+	}
+}
+
+func TestCSRFTokenRenderedInPages(t *testing.T) {
+	s := newTestServer(t)
+	cookie, _ := createTestUser(t, s, auth.RoleAdmin)
+
+	for _, path := range []string{"/jobs", "/jobs/new", "/tokens", "/users"} {
+		req := httptest.NewRequest("GET", path, nil)
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+
+		if !strings.Contains(rec.Body.String(), csrfTokenFor(cookie.Value)) {
+			t.Errorf("%s does not include the CSRF token", path)
+		}
+	}
+}
+
+// loadLoginForm loads the login page and returns the pre-session cookie its
+// CSRF token is bound to
+func loadLoginForm(t *testing.T, s *Server) *http.Cookie {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest("GET", "/login", nil))
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == loginCookieName {
+			if !strings.Contains(rec.Body.String(), csrfTokenFor(c.Value)) {
+				t.Fatal("the login form does not include its CSRF token")
+			}
+			return c
+		}
+	}
+	t.Fatal("the login form set no login cookie")
+	return nil
+}
+
+func TestLoginCSRF(t *testing.T) {
+	s := newTestServer(t)
+	hash, err := auth.HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.repo.CreateUser("alice", hash, string(auth.RoleEditor)); err != nil {
+		t.Fatal(err)
+	}
+
+	cookie := loadLoginForm(t, s)
+	if !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode || cookie.MaxAge <= 0 {
+		t.Errorf("unexpected login cookie flags: %+v", cookie)
+	}
+	other := loadLoginForm(t, s)
+
+	for _, tt := range []struct {
+		name   string
+		cookie *http.Cookie
+		token  string
+		want   int
+	}{
+		{"no cookie or token", nil, "", http.StatusForbidden},
+		{"no token", cookie, "", http.StatusForbidden},
+		{"no cookie", nil, csrfTokenFor(cookie.Value), http.StatusForbidden},
+		{"another browser's token", cookie, csrfTokenFor(other.Value), http.StatusForbidden},
+		{"matching token", cookie, csrfTokenFor(cookie.Value), http.StatusSeeOther},
+	} {
+		form := url.Values{"username": {"alice"}, "password": {"correct horse"}, csrfField: {tt.token}}
+		req := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if tt.cookie != nil {
+			req.AddCookie(tt.cookie)
+		}
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+
+		if rec.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.want, rec.Code)
+		}
+		if tt.want == http.StatusForbidden {
+			for _, c := range rec.Result().Cookies() {
+				if c.Name == sessionCookieName {
+					t.Errorf("%s: expected no session to be started", tt.name)
+				}
+			}
+		}
+	}
+
+	// A failed login re-renders the form bound to the same cookie
+	form := url.Values{"username": {"alice"}, "password": {"wrong"}, csrfField: {csrfTokenFor(other.Value)}}
+	req := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(other)
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), csrfTokenFor(other.Value)) {
+		t.Errorf("failed login: expected 401 with the same CSRF token, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,181 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rauche/cronnor/internal/models"
+	"github.com/rauche/cronnor/internal/notify"
+	"github.com/rauche/cronnor/internal/storage"
+)
+
+// renderDigests renders the digests page with an optional form error
+func (s *Server) renderDigests(w http.ResponseWriter, r *http.Request, formError string) {
+	digests, err := s.repo.GetDigests()
+	if err != nil {
+		http.Error(w, "Failed to load digests", http.StatusInternalServerError)
+		return
+	}
+	channels, err := s.repo.GetChannels()
+	if err != nil {
+		http.Error(w, "Failed to load channels", http.StatusInternalServerError)
+		return
+	}
+	teams, err := s.repo.GetJobTeams()
+	if err != nil {
+		http.Error(w, "Failed to load teams", http.StatusInternalServerError)
+		return
+	}
+	tags, err := s.repo.GetJobTags()
+	if err != nil {
+		http.Error(w, "Failed to load tags", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]interface{}{
+		"Digests":  digests,
+		"Channels": channels,
+		"Teams":    teams,
+		"Tags":     tags,
+		"Windows":  notify.DigestWindows,
+		"Error":    formError,
+	}
+
+	s.render(w, r, "digests.html", data)
+}
+
+// handleDigests shows the digests page
+func (s *Server) handleDigests(w http.ResponseWriter, r *http.Request) {
+	s.renderDigests(w, r, "")
+}
+
+// handleCreateDigest creates a digest, first due at its schedule's next run
+func (s *Server) handleCreateDigest(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	in := models.DigestInput{
+		Name:     r.FormValue("name"),
+		Team:     r.FormValue("team"),
+		Tag:      r.FormValue("tag"),
+		CronExpr: r.FormValue("cron_expr"),
+		Window:   r.FormValue("window"),
+	}
+	in.ChannelID, _ = strconv.ParseInt(r.FormValue("channel_id"), 10, 64)
+
+	fields := notify.ValidateDigest(&in)
+	if _, err := s.repo.GetChannel(in.ChannelID); errors.Is(err, storage.ErrChannelNotFound) {
+		fields["channel_id"] = "does not exist"
+	} else if err != nil {
+		http.Error(w, "Failed to load channel", http.StatusInternalServerError)
+		return
+	}
+	if len(fields) > 0 {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		s.renderDigests(w, r, fieldErrorMessage(fields))
+		return
+	}
+
+	next, err := notify.NextDigestRun(in.CronExpr, time.Now())
+	if err != nil {
+		http.Error(w, "Invalid schedule", http.StatusBadRequest)
+		return
+	}
+
+	id, err := s.repo.CreateDigest(in, next)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		s.renderDigests(w, r, "Failed to create digest; the name may already be taken")
+		return
+	}
+
+	s.audit(r, models.AuditEntry{
+		Action:     models.AuditDigestCreate,
+		TargetType: "digest",
+		TargetID:   id,
+		TargetName: in.Name,
+		Changes: []models.FieldChange{
+			{Field: "team", After: in.Team},
+			{Field: "tag", After: in.Tag},
+			{Field: "cron_expr", After: in.CronExpr},
+			{Field: "window", After: in.Window},
+		},
+	})
+
+	http.Redirect(w, r, "/digests", http.StatusSeeOther)
+}
+
+// handleDeleteDigest deletes a digest
+func (s *Server) handleDeleteDigest(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid digest ID", http.StatusBadRequest)
+		return
+	}
+
+	digest, err := s.repo.GetDigest(id)
+	if err != nil {
+		http.Error(w, "Digest not found", http.StatusNotFound)
+		return
+	}
+
+	if err := s.repo.DeleteDigest(id); err != nil {
+		http.Error(w, "Failed to delete digest", http.StatusInternalServerError)
+		return
+	}
+
+	s.audit(r, models.AuditEntry{
+		Action:     models.AuditDigestDelete,
+		TargetType: "digest",
+		TargetID:   id,
+		TargetName: digest.Name,
+		Changes: []models.FieldChange{
+			{Field: "team", Before: digest.Team},
+			{Field: "tag", Before: digest.Tag},
+			{Field: "cron_expr", Before: digest.CronExpr},
+			{Field: "window", Before: digest.Window},
+		},
+	})
+
+	http.Redirect(w, r, "/digests", http.StatusSeeOther)
+}
+
+// handlePreviewDigest renders a digest as it would be sent now: the HTML
+// email, or with ?format=text the plain text version
+func (s *Server) handlePreviewDigest(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid digest ID", http.StatusBadRequest)
+		return
+	}
+
+	digest, err := s.repo.GetDigest(id)
+	if err != nil {
+		http.Error(w, "Digest not found", http.StatusNotFound)
+		return
+	}
+
+	report, err := notify.BuildDigest(s.repo, *digest, time.Now())
+	if err != nil {
+		http.Error(w, "Failed to build digest", http.StatusInternalServerError)
+		return
+	}
+	text, html, err := notify.RenderDigest(report)
+	if err != nil {
+		http.Error(w, "Failed to render digest", http.StatusInternalServerError)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(text))
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(html))
+}
@@ -0,0 +1,195 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rauche/cronnor/internal/auth"
+	"github.com/rauche/cronnor/internal/notify"
+)
+
+func TestDigests(t *testing.T) {
+	s := newTestServer(t)
+	cookie, token := createTestUser(t, s, auth.RoleAdmin)
+
+	host, port, mail := smtpStub(t)
+
+	slack := make(chan string, 10)
+	slackStub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ Text string }
+		json.NewDecoder(r.Body).Decode(&body)
+		slack <- body.Text
+	}))
+	defer slackStub.Close()
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer target.Close()
+
+	notifier := notify.New(s.repo, notify.Config{
+		SMTP:    notify.SMTPConfig{Host: host, Port: port, From: "cronnor@example.com"},
+		Backoff: time.Millisecond,
+	})
+
+	web := func(method, path string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set(csrfHeader, csrfTokenFor(cookie.Value))
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		return rec
+	}
+	api := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, job := range []string{
+		`{"name":"flaky","cron_expr":"0 0 * * * *","url":"` + target.URL + `/fail","team":"ops"}`,
+		`{"name":"steady","cron_expr":"0 0 * * * *","url":"` + target.URL + `/ok","team":"ops"}`,
+		`{"name":"idle","cron_expr":"0 0 1 1 * *","url":"` + target.URL + `/ok","team":"ops"}`,
+		`{"name":"elsewhere","cron_expr":"0 0 * * * *","url":"` + target.URL + `/ok","team":"data"}`,
+	} {
+		if rec := api("POST", "/api/v1/jobs", job); rec.Code != http.StatusCreated {
+			t.Fatalf("create job: expected 201, got %d: %s", rec.Code, rec.Body)
+		}
+	}
+	for _, run := range []struct{ id, n int64 }{{1, 1}, {1, 2}, {2, 1}} {
+		if rec := api("POST", "/api/v1/jobs/"+strconv.FormatInt(run.id, 10)+"/run", ""); rec.Code != http.StatusAccepted {
+			t.Fatalf("run: expected 202, got %d: %s", rec.Code, rec.Body)
+		}
+		for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+			if logs, _ := s.repo.GetJobLogs(run.id, 10); int64(len(logs)) == run.n {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("run %d of job %d did not finish", run.n, run.id)
+			}
+		}
+	}
+
+	for _, ch := range []url.Values{
+		{"name": {"ops-email"}, "type": {"email"}, "target": {"ops@example.com"}},
+		{"name": {"ops-slack"}, "type": {"slack"}, "target": {slackStub.URL}},
+	} {
+		if rec := web("POST", "/notifications", ch); rec.Code != http.StatusSeeOther {
+			t.Fatalf("create channel %s: expected 303, got %d: %s", ch.Get("name"), rec.Code, rec.Body)
+		}
+	}
+
+	rec := web("POST", "/digests", url.Values{"name": {"Ops weekly"}, "cron_expr": {"not cron"}, "window": {"1y"}, "channel_id": {"9"}})
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("invalid digest: expected 422, got %d", rec.Code)
+	}
+	for _, field := range []string{"Cron expr", "window must be one of", "channel id does not exist"} {
+		if !strings.Contains(rec.Body.String(), field) {
+			t.Errorf("expected the form error to mention %q", field)
+		}
+	}
+
+	for _, d := range []url.Values{
+		{"name": {"Ops weekly"}, "team": {"ops"}, "cron_expr": {"0 0 9 * * MON"}, "channel_id": {"1"}},
+		{"name": {"Ops daily"}, "team": {"ops"}, "cron_expr": {"0 0 9 * * *"}, "window": {"24h"}, "channel_id": {"2"}},
+	} {
+		if rec := web("POST", "/digests", d); rec.Code != http.StatusSeeOther {
+			t.Fatalf("create digest %s: expected 303, got %d: %s", d.Get("name"), rec.Code, rec.Body)
+		}
+	}
+
+	if rec := web("GET", "/digests", nil); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "team ops, last 7d") {
+		t.Errorf("digests page: expected the weekly digest, got %d", rec.Code)
+	}
+
+	// The preview shows what would be sent now
+	rec = web("GET", "/digests/1/preview", nil)
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("preview: expected HTML, got %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	html := rec.Body.String()
+	for _, want := range []string{"Ops weekly", "33.3%", "Failing", "flaky", "2 of 2 failed", "Slowest", "steady", "Not run", "idle"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected the HTML preview to contain %q", want)
+		}
+	}
+	if strings.Contains(html, "elsewhere") {
+		t.Error("expected the preview to leave out other teams' jobs")
+	}
+
+	rec = web("GET", "/digests/1/preview?format=text", nil)
+	text := rec.Body.String()
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") || !strings.Contains(text, "3 jobs, 3 runs, 33.3% successful") ||
+		!strings.Contains(text, "- flaky: 2 of 2 runs failed (HTTP 500)") || !strings.Contains(text, "- idle: last run never") {
+		t.Errorf("unexpected text preview:\n%s", text)
+	}
+
+	// Nothing is due until the schedules come round; stats always cover the
+	// window up to the present, whenever the digest was due
+	if err := notifier.SendDueDigests(time.Now()); err != nil {
+		t.Fatalf("send digests: %v", err)
+	}
+	notifier.Stop()
+	select {
+	case msg := <-mail:
+		t.Fatalf("expected no digest yet, got:\n%s", msg)
+	default:
+	}
+
+	if err := notifier.SendDueDigests(time.Now().Add(8 * 24 * time.Hour)); err != nil {
+		t.Fatalf("send digests: %v", err)
+	}
+	notifier.Stop()
+
+	select {
+	case msg := <-mail:
+		for _, want := range []string{"Subject: [Cronnor] Ops weekly: 33.3% success, 1 job failing", "multipart/alternative", "text/plain", "text/html"} {
+			if !strings.Contains(msg, want) {
+				t.Errorf("expected the digest email to contain %q:\n%s", want, msg)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no digest email was sent")
+	}
+	select {
+	case text := <-slack:
+		if !strings.HasPrefix(text, "Ops daily — team ops") {
+			t.Errorf("unexpected Slack digest %q", text)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no Slack digest was sent")
+	}
+
+	digests, err := s.repo.GetDigests()
+	if err != nil {
+		t.Fatalf("failed to get digests: %v", err)
+	}
+	for _, d := range digests {
+		if !d.LastSentAt.Valid || d.LastError.Valid {
+			t.Errorf("expected digest %q to be recorded as sent, got %+v", d.Name, d)
+		}
+		if !d.NextRunAt.Valid || !d.NextRunAt.Time.After(time.Now().Add(8*24*time.Hour)) {
+			t.Errorf("expected digest %q to be rescheduled, got %v", d.Name, d.NextRunAt)
+		}
+	}
+
+	if rec := web("POST", "/digests/1/delete", url.Values{}); rec.Code != http.StatusSeeOther {
+		t.Fatalf("delete digest: expected 303, got %d", rec.Code)
+	}
+	if rec := web("GET", "/digests/1/preview", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("deleted digest preview: expected 404, got %d", rec.Code)
+	}
+}
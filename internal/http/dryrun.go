@@ -0,0 +1,105 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rauche/cronnor/internal/jobs"
+)
+
+// testPhase is one row of a test request's timing breakdown
+type testPhase struct {
+	Name     string
+	Duration string
+}
+
+// testHeader is one response header of a test request
+type testHeader struct {
+	Name  string
+	Value string
+}
+
+// formatDuration formats d in milliseconds with one decimal
+func formatDuration(d time.Duration) string {
+	return fmt.Sprintf("%.1f ms", float64(d)/float64(time.Millisecond))
+}
+
+// bodyPreview returns a response body for display, indenting JSON
+func bodyPreview(body []byte) string {
+	var out bytes.Buffer
+	if json.Indent(&out, body, "", "  ") == nil {
+		return out.String()
+	}
+	return strings.ToValidUTF8(string(body), "�")
+}
+
+// testResultData builds the template data for a test request's response
+func testResultData(result *jobs.Result) map[string]interface{} {
+	outcome := "SUCCESS"
+	if result.StatusCode >= 400 {
+		outcome = "FAILED"
+	}
+
+	names := make([]string, 0, len(result.Header))
+	for name := range result.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	headers := make([]testHeader, 0, len(names))
+	for _, name := range names {
+		headers = append(headers, testHeader{Name: name, Value: strings.Join(result.Header[name], ", ")})
+	}
+
+	t := result.Timing
+	phases := []testPhase{
+		{"DNS lookup", formatDuration(t.DNS)},
+		{"TCP connect", formatDuration(t.Connect)},
+		{"TLS handshake", formatDuration(t.TLS)},
+		{"Waiting for response", formatDuration(t.FirstByte)},
+		{"Content download", formatDuration(t.Transfer)},
+		{"Total", formatDuration(t.Total)},
+	}
+
+	return map[string]interface{}{
+		"Result":  result,
+		"Outcome": outcome,
+		"Headers": headers,
+		"Phases":  phases,
+		"Body":    bodyPreview(result.Body),
+	}
+}
+
+// handleTestJob sends the request described by the job form once and shows
+// the response, without saving the job or recording the execution
+func (s *Server) handleTestJob(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	in, fields := jobInputFromForm(r)
+	if fields := validateJobForm(&in, fields); len(fields) > 0 {
+		s.render(w, r, "_test_result.html", map[string]interface{}{"Errors": fields})
+		return
+	}
+
+	job := in.Job()
+	if job.IsHeartbeat() {
+		s.render(w, r, "_test_result.html", map[string]interface{}{"Error": "Heartbeat jobs send no request to test"})
+		return
+	}
+
+	result, err := s.scheduler.DryRun(*job)
+	if err != nil {
+		s.render(w, r, "_test_result.html", map[string]interface{}{"Error": err.Error()})
+		return
+	}
+
+	s.render(w, r, "_test_result.html", testResultData(result))
+}
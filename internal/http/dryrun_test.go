@@ -0,0 +1,85 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/rauche/cronnor/internal/auth"
+	"github.com/rauche/cronnor/internal/models"
+)
+
+func TestSendTestRequest(t *testing.T) {
+	s := newTestServer(t)
+	cookie, _ := createTestUser(t, s, auth.RoleEditor)
+
+	var received string
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = r.Method + " " + r.Header.Get("Content-Type") + " " + string(body)
+		w.Header().Set("X-Request-Id", "abc123")
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"queued":true}`))
+	}))
+	defer target.Close()
+
+	id, err := s.repo.CreateJob(models.CreateJobParams{
+		Name: "sync", CronExpr: "0 0 * * * *", URL: target.URL, Method: "GET",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	send := func(form url.Values) string {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/jobs/test", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set(csrfHeader, csrfTokenFor(cookie.Value))
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rec.Code)
+		}
+		return rec.Body.String()
+	}
+
+	body := send(url.Values{
+		"name":      {"sync"},
+		"cron_expr": {"0 0 * * * *"},
+		"url":       {target.URL + "/hook"},
+		"method":    {"POST"},
+		"payload":   {`{"full":true}`},
+	})
+
+	if received != `POST application/json {"full":true}` {
+		t.Errorf("target received %q", received)
+	}
+	for _, want := range []string{"202 Accepted", "X-Request-Id", "abc123", "Waiting for response", `&#34;queued&#34;: true`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected the result to contain %s", want)
+		}
+	}
+
+	logs, err := s.repo.GetJobLogs(id, 10)
+	if err != nil || len(logs) != 0 {
+		t.Errorf("a test request should not be logged, got %d logs (%v)", len(logs), err)
+	}
+	if job, _ := s.repo.GetJob(id); job.LastStatus.Valid || job.LastRunAt.Valid {
+		t.Error("a test request should not change the job's status")
+	}
+
+	body = send(url.Values{"name": {"sync"}, "cron_expr": {"0 0 * * * *"}, "url": {"ftp://example.com"}})
+	if !strings.Contains(body, "must be an absolute http or https URL") {
+		t.Error("an invalid configuration should list the field errors")
+	}
+
+	target.Close()
+	body = send(url.Values{"name": {"sync"}, "cron_expr": {"0 0 * * * *"}, "url": {target.URL}})
+	if !strings.Contains(body, "failed to execute request") {
+		t.Error("an unreachable target should show the error")
+	}
+}
@@ -0,0 +1,87 @@
+package http
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/rauche/cronnor/internal/auth"
+	"github.com/rauche/cronnor/internal/models"
+)
+
+func TestDuplicateJob(t *testing.T) {
+	s := newTestServer(t)
+	cookie, _ := createTestUser(t, s, auth.RoleEditor)
+
+	_, err := s.repo.CreateJob(models.CreateJobParams{
+		Name:     "sync",
+		CronExpr: "0 30 * * * *",
+		URL:      "http://example.com/sync",
+		Method:   "PUT",
+		Payload:  sql.NullString{String: `{"full":true}`, Valid: true},
+		Team:     "platform",
+		Tags:     []string{"nightly", "billing"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/jobs/1/duplicate", nil)
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("duplicate form: expected 200, got %d", rec.Code)
+	}
+
+	body := rec.Body.String()
+	for _, want := range []string{
+		`value="sync (copy)"`,
+		`action="/jobs"`,
+		`value="0 30 * * * *"`,
+		`value="platform"`,
+		`value="billing, nightly"`,
+		`{&#34;full&#34;:true}`,
+		`name="disabled" value="true" checked`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("duplicate form: expected %s", want)
+		}
+	}
+
+	form := url.Values{
+		"name":      {"sync (copy)"},
+		"cron_expr": {"0 30 * * * *"},
+		"url":       {"http://example.com/sync2"},
+		"method":    {"PUT"},
+		"disabled":  {"true"},
+	}
+	req = httptest.NewRequest("POST", "/jobs", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set(csrfHeader, csrfTokenFor(cookie.Value))
+	req.AddCookie(cookie)
+	rec = httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("create: expected redirect, got %d", rec.Code)
+	}
+
+	job, err := s.repo.GetJob(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.IsActive || job.NextRunAt.Valid {
+		t.Error("a job created paused should not be scheduled")
+	}
+
+	req = httptest.NewRequest("GET", "/jobs/99/duplicate", nil)
+	req.AddCookie(cookie)
+	rec = httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown job: expected 404, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,100 @@
+package http
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rauche/cronnor/internal/auth"
+	"github.com/rauche/cronnor/internal/models"
+)
+
+func TestEventStream(t *testing.T) {
+	s := newTestServer(t)
+	cookie, token := createTestUser(t, s, auth.RoleEditor)
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+
+	for _, name := range []string{"watched", "other"} {
+		if _, err := s.repo.CreateJob(models.CreateJobParams{
+			Name: name, CronExpr: "0 0 * * * *", URL: target.URL, Method: "GET",
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	srv := httptest.NewServer(s.router)
+	defer srv.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL+"/events?job=1", nil)
+	req.AddCookie(cookie)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); resp.StatusCode != http.StatusOK || ct != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %d %s", resp.StatusCode, ct)
+	}
+
+	// Wait for the subscription before publishing anything
+	for i := 0; s.events.Subscribers() == 0; i++ {
+		if i == 100 {
+			t.Fatal("the stream never subscribed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	api := func(path string) {
+		req := httptest.NewRequest("POST", path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		if rec.Code >= 300 {
+			t.Fatalf("%s: got %d", path, rec.Code)
+		}
+	}
+	api("/api/v1/jobs/2/toggle")
+	api("/api/v1/jobs/1/toggle")
+	api("/api/v1/jobs/1/run")
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	var got []string
+	want := []string{
+		`data: {"type":"job.changed","job_id":1}`,
+		`data: {"type":"execution.started","job_id":1}`,
+		`data: {"type":"execution.finished","job_id":1,"log_id":1,"status":"SUCCESS"}`,
+	}
+	timeout := time.After(5 * time.Second)
+	for len(got) < len(want) {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatalf("stream closed early after %v", got)
+			}
+			if strings.HasPrefix(line, "data: ") {
+				got = append(got, line)
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for events, got %v", got)
+		}
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("event %d: expected %s, got %s", i, want[i], got[i])
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rauche/cronnor/internal/auth"
+	"github.com/rauche/cronnor/internal/models"
+)
+
+func TestExecutionTriggers(t *testing.T) {
+	s := newTestServer(t)
+	cookie, token := createTestUser(t, s, auth.RoleOperator)
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+
+	id, err := s.repo.CreateJob(models.CreateJobParams{
+		Name: "sync", CronExpr: "* * * * * *", URL: target.URL, Method: "GET",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitForLogs := func(n int) []models.JobLog {
+		t.Helper()
+		for i := 0; i < 300; i++ {
+			logs, _ := s.repo.GetJobLogs(id, 10)
+			if len(logs) >= n {
+				return logs
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("expected %d executions", n)
+		return nil
+	}
+
+	// Let the schedule fire once, then pause the job
+	if err := s.scheduler.Start(); err != nil {
+		t.Fatal(err)
+	}
+	waitForLogs(1)
+	s.scheduler.Stop()
+	s.repo.ToggleJob(id)
+
+	req := httptest.NewRequest("POST", "/jobs/1/run", nil)
+	req.Header.Set(csrfHeader, csrfTokenFor(cookie.Value))
+	req.AddCookie(cookie)
+	s.router.ServeHTTP(httptest.NewRecorder(), req)
+	waitForLogs(2)
+
+	api := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		return rec
+	}
+	api("POST", "/api/v1/jobs/1/run")
+	waitForLogs(3)
+
+	list := func(query string) []apiJobLog {
+		t.Helper()
+		rec := api("GET", "/api/v1/jobs/1/logs"+query)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", query, rec.Code, rec.Body)
+		}
+		var logs []apiJobLog
+		json.Unmarshal(rec.Body.Bytes(), &logs)
+		return logs
+	}
+
+	scheduled := list("?trigger=schedule")
+	if len(scheduled) == 0 || scheduled[0].ScheduledAt == nil || scheduled[0].LagMs == nil || scheduled[0].Actor != nil {
+		t.Fatalf("expected scheduled runs with a fire time and lag, got %+v", scheduled)
+	}
+	if lag := scheduled[0].StartedAt.Sub(*scheduled[0].ScheduledAt); lag < 0 || lag > time.Second {
+		t.Errorf("expected a run to start shortly after it was due, got %s late", lag)
+	}
+
+	manual := list("?trigger=manual")
+	if len(manual) != 2 || manual[0].ScheduledAt != nil {
+		t.Fatalf("expected 2 manual runs without a fire time, got %+v", manual)
+	}
+	if *manual[0].Actor != "operator (token "+token[:12]+")" || *manual[1].Actor != "operator" {
+		t.Errorf("expected the runs to name who started them, got %s and %s", *manual[0].Actor, *manual[1].Actor)
+	}
+	if got := list("?actor=token"); len(got) != 1 {
+		t.Errorf("actor filter: expected 1 run, got %d", len(got))
+	}
+	if got := list("?min_lag_ms=60000"); len(got) != 0 {
+		t.Errorf("lag filter: expected no runs a minute late, got %d", len(got))
+	}
+
+	if rec := api("GET", "/api/v1/jobs/1/logs?trigger=cron&min_lag_ms=soon"); rec.Code != http.StatusUnprocessableEntity ||
+		!strings.Contains(rec.Body.String(), "trigger") || !strings.Contains(rec.Body.String(), "min_lag_ms") {
+		t.Errorf("expected invalid filters to be rejected, got %d: %s", rec.Code, rec.Body)
+	}
+
+	req = httptest.NewRequest("GET", "/jobs/1?trigger=manual", nil)
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	body := rec.Body.String()
+	if !strings.Contains(body, "operator (token") || strings.Contains(body, "ms late") {
+		t.Error("expected the history to show only the manual runs and who started them")
+	}
+	if !strings.Contains(body, `<option value="manual" selected>`) {
+		t.Error("expected the trigger filter to stay selected")
+	}
+}
+
+func TestScheduledRunLag(t *testing.T) {
+	s := newTestServer(t)
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+
+	id, err := s.repo.CreateJob(models.CreateJobParams{
+		Name: "sync", CronExpr: "* * * * * *", URL: target.URL, Method: "GET",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.scheduler.Start(); err != nil {
+		t.Fatal(err)
+	}
+	var logs []models.JobLog
+	for i := 0; i < 500 && len(logs) < 3; i++ {
+		time.Sleep(10 * time.Millisecond)
+		logs, _ = s.repo.GetJobLogs(id, 10)
+	}
+	s.scheduler.Stop()
+	if len(logs) < 3 {
+		t.Fatalf("expected 3 scheduled runs, got %d", len(logs))
+	}
+
+	// Every run, the first included, is due at its own fire time
+	for i, entry := range logs {
+		if !entry.ScheduledAt.Valid || !entry.LagMs.Valid {
+			t.Fatalf("run %d: expected a fire time and lag, got %+v", entry.ID, entry)
+		}
+		scheduled := entry.ScheduledAt.Time
+		if scheduled.Nanosecond() != 0 {
+			t.Errorf("run %d: expected a fire time on the second, got %s", entry.ID, scheduled)
+		}
+		if lag := entry.LagMs.Int64; lag < 0 || lag > 500 {
+			t.Errorf("run %d: expected to start shortly after it was due, got %dms late", entry.ID, lag)
+		}
+		if lag := entry.StartedAt.Time.Sub(scheduled).Milliseconds(); lag != entry.LagMs.Int64 {
+			t.Errorf("run %d: lag %dms does not match its start %dms after its fire time", entry.ID, entry.LagMs.Int64, lag)
+		}
+		if i > 0 && !scheduled.Before(logs[i-1].ScheduledAt.Time) {
+			t.Errorf("run %d: expected a fire time before the next run's, got %s", entry.ID, scheduled)
+		}
+	}
+}
@@ -1,46 +1,113 @@
 package http
 
 import (
-	"database/sql"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/rauche/cronnor/internal/jobs"
 	"github.com/rauche/cronnor/internal/models"
+	"github.com/rauche/cronnor/internal/storage"
 )
 
-// handleDashboard shows the main dashboard
+// handleDashboard shows the main dashboard; the jobs themselves are loaded
+// by the list partial
 func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
-	jobs, err := s.repo.GetAllJobs()
+	tags, err := s.repo.GetJobTags()
 	if err != nil {
-		http.Error(w, "Failed to load jobs", http.StatusInternalServerError)
+		http.Error(w, "Failed to load tags", http.StatusInternalServerError)
 		return
 	}
 
-	data := map[string]interface{}{
-		"Jobs": jobs,
+	teams, err := s.repo.GetJobTeams()
+	if err != nil {
+		http.Error(w, "Failed to load teams", http.StatusInternalServerError)
+		return
 	}
 
-	if err := s.templates.Render(w, "dashboard.html", data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	opts := jobListOptionsFromRequest(r)
+
+	data := map[string]interface{}{
+		"Options": opts,
+		"Filter":  opts.Filter,
+		"Tags":    tags,
+		"Teams":   teams,
 	}
+
+	s.render(w, r, "dashboard.html", data)
 }
 
 // handleJobsList returns the jobs list partial (for HTMX)
 func (s *Server) handleJobsList(w http.ResponseWriter, r *http.Request) {
-	jobs, err := s.repo.GetAllJobs()
+	opts := jobListOptionsFromRequest(r)
+	page, err := s.repo.ListJobs(opts)
 	if err != nil {
 		http.Error(w, "Failed to load jobs", http.StatusInternalServerError)
 		return
 	}
 
 	data := map[string]interface{}{
-		"Jobs": jobs,
+		"Jobs":   page.Jobs,
+		"Filter": opts.Filter,
+		"Page":   page,
+	}
+	if page.Page > 1 {
+		data["PrevURL"] = jobListURL(opts, page.Page-1)
+	}
+	if page.Page < page.Pages() {
+		data["NextURL"] = jobListURL(opts, page.Page+1)
+	}
+
+	s.render(w, r, "_job_list.html", data)
+}
+
+// lagFilters are the schedule lag choices offered above the execution history
+var lagFilters = []struct {
+	Ms    string
+	Label string
+}{
+	{"1000", "≥ 1s late"},
+	{"10000", "≥ 10s late"},
+	{"60000", "≥ 1m late"},
+}
+
+// jobLogFilter reads an execution history filter from the query string,
+// returning a message for each invalid parameter
+func jobLogFilter(r *http.Request, limit int) (models.JobLogFilter, map[string]string) {
+	q := r.URL.Query()
+	filter := models.JobLogFilter{
+		Trigger: q.Get("trigger"),
+		Actor:   strings.TrimSpace(q.Get("actor")),
+		Limit:   limit,
 	}
+	fields := make(map[string]string)
 
-	if err := s.templates.Render(w, "_job_list.html", data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if filter.Trigger != "" && !validTrigger(filter.Trigger) {
+		fields["trigger"] = "must be one of " + strings.Join(models.Triggers, ", ")
+		filter.Trigger = ""
 	}
+	if v := q.Get("min_lag_ms"); v != "" {
+		ms, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || ms < 0 {
+			fields["min_lag_ms"] = "must be a non-negative integer"
+		} else {
+			filter.MinLag = time.Duration(ms) * time.Millisecond
+		}
+	}
+
+	return filter, fields
+}
+
+func validTrigger(trigger string) bool {
+	for _, t := range models.Triggers {
+		if trigger == t {
+			return true
+		}
+	}
+	return false
 }
 
 // handleJobDetail shows job details and execution history
@@ -57,20 +124,65 @@ func (s *Server) handleJobDetail(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	logs, err := s.repo.GetJobLogs(id, 50)
+	// Invalid filters are ignored, like on the audit page
+	filter, _ := jobLogFilter(r, 50)
+	logs, err := s.repo.FindJobLogs(id, filter)
 	if err != nil {
 		http.Error(w, "Failed to load logs", http.StatusInternalServerError)
 		return
 	}
 
-	data := map[string]interface{}{
-		"Job":  job,
-		"Logs": logs,
+	window := r.URL.Query().Get("window")
+	if !storage.ValidStatsWindow(window) {
+		window = storage.StatsWindows[0]
+	}
+
+	stats, err := s.repo.GetJobStats(id, window)
+	if err != nil {
+		http.Error(w, "Failed to load stats", http.StatusInternalServerError)
+		return
+	}
+
+	durations, err := s.repo.GetRecentDurations(id, 50)
+	if err != nil {
+		http.Error(w, "Failed to load stats", http.StatusInternalServerError)
+		return
+	}
+
+	revisions, err := s.repo.GetJobRevisions(id)
+	if err != nil {
+		http.Error(w, "Failed to load revisions", http.StatusInternalServerError)
+		return
 	}
 
-	if err := s.templates.Render(w, "job_detail.html", data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	data := map[string]interface{}{
+		"Job":       job,
+		"Logs":      logs,
+		"Stats":     stats,
+		"Windows":   storage.StatsWindows,
+		"Durations": durations,
+		"Revisions": revisionHistory(revisions),
+		"Filter":    r.URL.Query(),
+		"Filtered":  filter.Trigger != "" || filter.Actor != "" || filter.MinLag > 0,
+		"Triggers":  models.Triggers,
+		"Lags":      lagFilters,
+		"Self":      r.URL.RequestURI(),
+	}
+	alerts, err := s.alertData(job)
+	if err != nil {
+		http.Error(w, "Failed to load alert rules", http.StatusInternalServerError)
+		return
+	}
+	for k, v := range alerts {
+		data[k] = v
+	}
+	if job.IsHeartbeat() {
+		if due, ok := jobs.PingDue(*job); ok {
+			data["PingDue"] = due
+		}
 	}
+
+	s.render(w, r, "job_detail.html", data)
 }
 
 // handleJobForm shows the new job form
@@ -79,9 +191,7 @@ func (s *Server) handleJobForm(w http.ResponseWriter, r *http.Request) {
 		"Job": nil,
 	}
 
-	if err := s.templates.Render(w, "job_form.html", data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
+	s.render(w, r, "job_form.html", data)
 }
 
 // handleJobEditForm shows the edit job form
@@ -99,35 +209,109 @@ func (s *Server) handleJobEditForm(w http.ResponseWriter, r *http.Request) {
 	}
 
 	data := map[string]interface{}{
-		"Job": job,
+		"Job":     job,
+		"Editing": true,
 	}
 
-	if err := s.templates.Render(w, "job_form.html", data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
+	s.render(w, r, "job_form.html", data)
 }
 
-// handleCreateJob creates a new job
-func (s *Server) handleCreateJob(w http.ResponseWriter, r *http.Request) {
-	if err := r.ParseForm(); err != nil {
-		http.Error(w, "Invalid form data", http.StatusBadRequest)
+// handleJobDuplicateForm shows the new job form prefilled with every field of an existing job
+func (s *Server) handleJobDuplicateForm(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid job ID", http.StatusBadRequest)
 		return
 	}
 
-	payload := sql.NullString{}
-	if p := r.FormValue("payload"); p != "" {
-		payload.String = p
-		payload.Valid = true
+	job, err := s.repo.GetJob(id)
+	if err != nil {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	job.Name += " (copy)"
+	job.IsActive = false
+
+	data := map[string]interface{}{
+		"Job":       job,
+		"Duplicate": true,
+	}
+
+	s.render(w, r, "job_form.html", data)
+}
+
+// jobInputFromForm reads a submitted job form, with a message for each field
+// that could not be parsed
+func jobInputFromForm(r *http.Request) (models.JobInput, map[string]string) {
+	fields := make(map[string]string)
+
+	var grace int
+	if v := strings.TrimSpace(r.FormValue("grace_seconds")); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil || seconds < 0 {
+			fields["grace_seconds"] = "must be a non-negative integer"
+		} else {
+			grace = seconds
+		}
 	}
 
-	params := models.CreateJobParams{
+	in := models.JobInput{
+		Kind:     r.FormValue("kind"),
 		Name:     r.FormValue("name"),
 		CronExpr: r.FormValue("cron_expr"),
 		URL:      r.FormValue("url"),
 		Method:   r.FormValue("method"),
-		Payload:  payload,
+		Payload:  r.FormValue("payload"),
+		Team:     r.FormValue("team"),
+		Tags:     strings.Split(r.FormValue("tags"), ","),
+		Grace:    time.Duration(grace) * time.Second,
+	}
+	return in, fields
+}
+
+// validateJobForm validates a job read from a form, adding to the fields the
+// form could not parse. Only heartbeat jobs have a grace period, so the
+// field is ignored for other kinds.
+func validateJobForm(in *models.JobInput, fields map[string]string) map[string]string {
+	for field, msg := range jobs.ValidateJob(in) {
+		fields[field] = msg
+	}
+	if in.Kind != models.JobKindHeartbeat {
+		delete(fields, "grace_seconds")
+	}
+	return fields
+}
+
+// renderJobFormErrors redisplays a rejected job form with the submitted
+// values and a message next to each invalid field
+func (s *Server) renderJobFormErrors(w http.ResponseWriter, r *http.Request, job *models.Job, editing bool, fields map[string]string) {
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	s.render(w, r, "job_form.html", map[string]interface{}{
+		"Job":     job,
+		"Editing": editing,
+		"Errors":  fields,
+	})
+}
+
+// handleCreateJob creates a new job
+func (s *Server) handleCreateJob(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	in, fields := jobInputFromForm(r)
+	disabled := r.FormValue("disabled") == "true"
+	if fields := validateJobForm(&in, fields); len(fields) > 0 {
+		job := in.Job()
+		job.IsActive = !disabled
+		s.renderJobFormErrors(w, r, job, false, fields)
+		return
 	}
 
+	params := in.CreateParams()
+	params.Disabled = disabled
+
 	id, err := s.repo.CreateJob(params)
 	if err != nil {
 		http.Error(w, "Failed to create job", http.StatusInternalServerError)
@@ -138,8 +322,14 @@ func (s *Server) handleCreateJob(w http.ResponseWriter, r *http.Request) {
 	job, err := s.repo.GetJob(id)
 	if err == nil {
 		s.scheduler.AddJob(*job)
+		s.auditJob(r, models.AuditJobCreate, nil, job)
 	}
 
+	// Heartbeat jobs get their ping URL from their page
+	if params.Kind == models.JobKindHeartbeat {
+		http.Redirect(w, r, "/jobs/"+strconv.FormatInt(id, 10), http.StatusSeeOther)
+		return
+	}
 	http.Redirect(w, r, "/jobs", http.StatusSeeOther)
 }
 
@@ -156,22 +346,23 @@ func (s *Server) handleUpdateJob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	payload := sql.NullString{}
-	if p := r.FormValue("payload"); p != "" {
-		payload.String = p
-		payload.Valid = true
+	before, err := s.repo.GetJob(id)
+	if err != nil {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
 	}
 
-	params := models.UpdateJobParams{
-		ID:       id,
-		Name:     r.FormValue("name"),
-		CronExpr: r.FormValue("cron_expr"),
-		URL:      r.FormValue("url"),
-		Method:   r.FormValue("method"),
-		Payload:  payload,
+	// A job's kind is fixed when it is created
+	in, fields := jobInputFromForm(r)
+	in.Kind = before.Kind
+	if fields := validateJobForm(&in, fields); len(fields) > 0 {
+		job := in.Job()
+		job.ID = id
+		s.renderJobFormErrors(w, r, job, true, fields)
+		return
 	}
 
-	if err := s.repo.UpdateJob(params); err != nil {
+	if err := s.repo.UpdateJob(in.UpdateParams(id)); err != nil {
 		http.Error(w, "Failed to update job", http.StatusInternalServerError)
 		return
 	}
@@ -179,6 +370,10 @@ func (s *Server) handleUpdateJob(w http.ResponseWriter, r *http.Request) {
 	// Reload job in scheduler
 	s.scheduler.ReloadJob(id)
 
+	if after, err := s.repo.GetJob(id); err == nil {
+		s.auditJob(r, models.AuditJobUpdate, before, after)
+	}
+
 	http.Redirect(w, r, "/jobs/"+strconv.FormatInt(id, 10), http.StatusSeeOther)
 }
 
@@ -190,6 +385,12 @@ func (s *Server) handleToggleJob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	before, err := s.repo.GetJob(id)
+	if err != nil {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
 	if err := s.repo.ToggleJob(id); err != nil {
 		http.Error(w, "Failed to toggle job", http.StatusInternalServerError)
 		return
@@ -198,6 +399,10 @@ func (s *Server) handleToggleJob(w http.ResponseWriter, r *http.Request) {
 	// Reload job in scheduler
 	s.scheduler.ReloadJob(id)
 
+	if after, err := s.repo.GetJob(id); err == nil {
+		s.auditJob(r, models.AuditJobToggle, before, after)
+	}
+
 	// Return updated job list for HTMX
 	s.handleJobsList(w, r)
 }
@@ -210,10 +415,20 @@ func (s *Server) handleRunJob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.scheduler.ExecuteNow(id); err != nil {
+	job, err := s.repo.GetJob(id)
+	if err != nil {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	if err := s.scheduler.ExecuteNow(id, manualTrigger(r)); errors.Is(err, jobs.ErrNotRunnable) {
+		http.Error(w, "Heartbeat jobs cannot be run", http.StatusConflict)
+		return
+	} else if err != nil {
 		http.Error(w, "Failed to execute job", http.StatusInternalServerError)
 		return
 	}
+	s.auditJob(r, models.AuditJobRun, job, job)
 
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("Job execution started"))
@@ -227,6 +442,12 @@ func (s *Server) handleDeleteJob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	job, err := s.repo.GetJob(id)
+	if err != nil {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
 	// Remove from scheduler first
 	s.scheduler.RemoveJob(id)
 
@@ -234,7 +455,25 @@ func (s *Server) handleDeleteJob(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to delete job", http.StatusInternalServerError)
 		return
 	}
+	s.auditJob(r, models.AuditJobDelete, job, nil)
 
 	// Return updated job list for HTMX
 	s.handleJobsList(w, r)
 }
+
+// handleHealthz reports that the process is up
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
+
+// handleReadyz reports whether the server can reach its database
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if err := s.repo.Ping(); err != nil {
+		http.Error(w, "database unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
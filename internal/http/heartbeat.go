@@ -0,0 +1,150 @@
+package http
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rauche/cronnor/internal/auth"
+	"github.com/rauche/cronnor/internal/jobs"
+	"github.com/rauche/cronnor/internal/models"
+	"github.com/rauche/cronnor/internal/storage"
+)
+
+// maxPingBody bounds the body of a heartbeat ping; only the start of it is
+// kept in the job's history
+const maxPingBody = 1 << 20
+
+// pingPath returns the path a heartbeat job with token is pinged at
+func pingPath(token string) string {
+	return "/ping/" + token
+}
+
+// pingCredentials are shown once, when a heartbeat job's ping URL is created
+type pingCredentials struct {
+	URL   string `json:"url"`
+	Token string `json:"token"`
+}
+
+// createPingURL gives a heartbeat job a new ping URL, invalidating any
+// previous one, and records the change
+func (s *Server) createPingURL(r *http.Request, id int64) (*pingCredentials, error) {
+	before, err := s.repo.GetJob(id)
+	if err != nil {
+		return nil, err
+	}
+	if !before.IsHeartbeat() {
+		return nil, jobs.ErrNotHeartbeat
+	}
+
+	token, hash, err := auth.GeneratePingToken()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.SetJobPingToken(id, hash); err != nil {
+		return nil, err
+	}
+
+	after, err := s.repo.GetJob(id)
+	if err != nil {
+		after = before
+	}
+	s.auditJob(r, models.AuditJobPingURL, before, after)
+
+	return &pingCredentials{URL: absoluteURL(r, pingPath(token)), Token: token}, nil
+}
+
+// handleCreatePingURL creates a ping URL for a heartbeat job and shows it once
+func (s *Server) handleCreatePingURL(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid job ID", http.StatusBadRequest)
+		return
+	}
+
+	creds, err := s.createPingURL(r, id)
+	if errors.Is(err, storage.ErrJobNotFound) {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	} else if errors.Is(err, jobs.ErrNotHeartbeat) {
+		http.Error(w, "Only heartbeat jobs have a ping URL", http.StatusConflict)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to create ping URL", http.StatusInternalServerError)
+		return
+	}
+
+	job, err := s.repo.GetJob(id)
+	if err != nil {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	data := map[string]interface{}{
+		"Job":     job,
+		"PingURL": creds.URL,
+	}
+
+	s.render(w, r, "_ping_url.html", data)
+}
+
+// handleAPICreatePingURL creates a ping URL for a heartbeat job, replacing
+// any previous one, and returns it. The token is not shown again.
+func (s *Server) handleAPICreatePingURL(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiJobID(w, r)
+	if !ok {
+		return
+	}
+
+	creds, err := s.createPingURL(r, id)
+	if err != nil {
+		writeJobError(w, err, "failed to create ping URL")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, creds)
+}
+
+// handlePing records a ping for the heartbeat job that owns the token in the
+// URL. A bare ping reports success; start and fail can be given as a suffix.
+// Paused jobs refuse pings, as they refuse trigger calls.
+func (s *Server) handlePing(w http.ResponseWriter, r *http.Request) {
+	ping := chi.URLParam(r, "signal")
+	if ping == "" {
+		ping = jobs.PingSuccess
+	}
+	if !jobs.ValidPing(ping) {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPingBody))
+	if err != nil {
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	job, err := s.repo.GetJobByPingToken(auth.HashToken(chi.URLParam(r, "token")))
+	if errors.Is(err, storage.ErrJobNotFound) {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to load job", http.StatusInternalServerError)
+		return
+	}
+
+	if !job.IsActive {
+		http.Error(w, "Job is paused", http.StatusConflict)
+		return
+	}
+
+	if err := s.scheduler.Ping(*job, ping, body, clientIP(r)); err != nil {
+		http.Error(w, "Failed to record ping", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("OK"))
+}
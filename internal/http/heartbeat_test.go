@@ -0,0 +1,219 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rauche/cronnor/internal/auth"
+	"github.com/rauche/cronnor/internal/jobs"
+)
+
+func TestHeartbeatJob(t *testing.T) {
+	s := newTestServer(t)
+	cookie, token := createTestUser(t, s, auth.RoleEditor)
+
+	api := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := api("POST", "/api/v1/jobs", `{"kind":"heartbeat","name":"backup","cron_expr":"0 0 * * * *","url":"http://ignored","grace_seconds":60}`)
+	var created apiJob
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	if rec.Code != http.StatusCreated || created.Kind != "heartbeat" || created.URL != "" || created.PingURL == nil || created.Grace == nil || *created.Grace != 60 {
+		t.Fatalf("create: got %d: %s", rec.Code, rec.Body)
+	}
+	if job, _ := s.repo.GetJob(created.ID); job.NextRunAt.Valid {
+		t.Error("a heartbeat job should not be scheduled")
+	}
+
+	ping := func(method, path, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	pingURL := *created.PingURL
+
+	if code := ping("GET", pingURL+"/start", ""); code != http.StatusOK {
+		t.Fatalf("start ping: expected 200, got %d", code)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if code := ping("POST", pingURL, "42 files"); code != http.StatusOK {
+		t.Fatalf("success ping: expected 200, got %d", code)
+	}
+
+	logs, err := s.repo.GetJobLogs(created.ID, 10)
+	if err != nil || len(logs) != 2 {
+		t.Fatalf("expected 2 logs, got %d (%v)", len(logs), err)
+	}
+	if logs[1].Status != "STARTED" || logs[0].Status != "SUCCESS" || logs[0].Trigger != "ping" || logs[0].ResponseBody.String != "42 files" {
+		t.Errorf("unexpected logs: %+v", logs)
+	}
+	if !logs[0].DurationMs.Valid || logs[0].DurationMs.Int64 < 20 {
+		t.Errorf("expected the success ping to be timed from the start ping, got %+v", logs[0].DurationMs)
+	}
+
+	job, _ := s.repo.GetJob(created.ID)
+	due, ok := jobs.PingDue(*job)
+	if job.LastStatus.String != "SUCCESS" || !ok {
+		t.Fatalf("expected the job to be up, got %s", job.LastStatus.String)
+	}
+
+	for _, step := range []struct {
+		at   time.Time
+		want string
+	}{
+		{due.Add(-time.Second), "SUCCESS"},
+		{due.Add(30 * time.Second), "LATE"},
+		{due.Add(2 * time.Minute), "DOWN"},
+	} {
+		s.scheduler.CheckHeartbeats(step.at)
+		if job, _ := s.repo.GetJob(created.ID); job.LastStatus.String != step.want {
+			t.Errorf("at due%+v: expected %s, got %s", step.at.Sub(due), step.want, job.LastStatus.String)
+		}
+	}
+
+	if code := ping("GET", pingURL+"/fail", ""); code != http.StatusOK {
+		t.Fatalf("fail ping: expected 200, got %d", code)
+	}
+	// A failed job is still expected to ping again
+	job, _ = s.repo.GetJob(created.ID)
+	due, _ = jobs.PingDue(*job)
+	s.scheduler.CheckHeartbeats(due.Add(-time.Second))
+	if job, _ := s.repo.GetJob(created.ID); job.LastStatus.String != "FAILED" {
+		t.Errorf("before due: expected FAILED, got %s", job.LastStatus.String)
+	}
+	s.scheduler.CheckHeartbeats(due.Add(2 * time.Minute))
+	if job, _ := s.repo.GetJob(created.ID); job.LastStatus.String != "DOWN" {
+		t.Errorf("a failed job that missed its ping should be DOWN, got %s", job.LastStatus.String)
+	}
+
+	// Only the token's hash is kept, so the URL is not shown again
+	pingToken := strings.TrimPrefix(pingURL, "/ping/")
+	if job, err := s.repo.GetJobByPingToken(auth.HashToken(pingToken)); err != nil || job.ID != created.ID || !job.PingURL {
+		t.Errorf("expected the job to be found by the token's hash, got %v (%v)", job, err)
+	}
+	var got apiJob
+	json.Unmarshal(api("GET", "/api/v1/jobs/1", "").Body.Bytes(), &got)
+	if got.PingURL != nil {
+		t.Errorf("expected the ping URL to be shown only on creation, got %s", *got.PingURL)
+	}
+
+	// Paused jobs refuse pings
+	api("POST", "/api/v1/jobs/1/toggle", "")
+	if code := ping("GET", pingURL, ""); code != http.StatusConflict {
+		t.Errorf("paused: expected 409, got %d", code)
+	}
+	api("POST", "/api/v1/jobs/1/toggle", "")
+
+	// A new ping URL replaces the old one
+	rec = api("POST", "/api/v1/jobs/1/ping-url", "")
+	var creds pingCredentials
+	json.Unmarshal(rec.Body.Bytes(), &creds)
+	if rec.Code != http.StatusCreated || creds.URL != "http://example.com/ping/"+creds.Token {
+		t.Fatalf("new ping URL: got %d: %s", rec.Code, rec.Body)
+	}
+	if code := ping("GET", pingURL, ""); code != http.StatusNotFound {
+		t.Errorf("old token: expected 404, got %d", code)
+	}
+	if code := ping("GET", "/ping/"+creds.Token, ""); code != http.StatusOK {
+		t.Errorf("new token: expected 200, got %d", code)
+	}
+
+	// The job page shows a new one once
+	req := httptest.NewRequest("POST", "/jobs/1/ping-url", nil)
+	req.Header.Set(csrfHeader, csrfTokenFor(cookie.Value))
+	req.AddCookie(cookie)
+	rec = httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "http://example.com/ping/") {
+		t.Errorf("new ping URL from the job page: got %d", rec.Code)
+	}
+	if code := ping("GET", "/ping/"+creds.Token, ""); code != http.StatusNotFound {
+		t.Errorf("replaced token: expected 404, got %d", code)
+	}
+
+	if code := ping("GET", "/ping/unknown", ""); code != http.StatusNotFound {
+		t.Errorf("unknown token: expected 404, got %d", code)
+	}
+	if code := ping("GET", pingURL+"/bogus", ""); code != http.StatusNotFound {
+		t.Errorf("unknown signal: expected 404, got %d", code)
+	}
+
+	if rec := api("POST", "/api/v1/jobs/1/run", ""); rec.Code != http.StatusConflict {
+		t.Errorf("run: expected 409, got %d", rec.Code)
+	}
+	if rec := api("POST", "/api/v1/jobs/1/webhook", ""); rec.Code != http.StatusConflict {
+		t.Errorf("webhook: expected 409, got %d", rec.Code)
+	}
+	api("POST", "/api/v1/jobs", `{"name":"sync","cron_expr":"0 0 * * * *","url":"http://example.com"}`)
+	if rec := api("POST", "/api/v1/jobs/2/ping-url", ""); rec.Code != http.StatusConflict {
+		t.Errorf("ping URL for an HTTP job: expected 409, got %d", rec.Code)
+	}
+	rec = api("PUT", "/api/v1/jobs/1", `{"kind":"http","name":"backup","cron_expr":"0 0 * * * *","url":"http://example.com"}`)
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), "cannot be changed") {
+		t.Errorf("changing the kind: expected 422, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestHeartbeatMissedWithoutPings(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		pings []string
+	}{
+		{"never pinged", nil},
+		{"started but never finished", []string{"/start"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestServer(t)
+			_, token := createTestUser(t, s, auth.RoleEditor)
+
+			req := httptest.NewRequest("POST", "/api/v1/jobs", strings.NewReader(`{"kind":"heartbeat","name":"backup","cron_expr":"0 0 * * * *","grace_seconds":60}`))
+			req.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+			s.router.ServeHTTP(rec, req)
+			var created apiJob
+			if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil || rec.Code != http.StatusCreated {
+				t.Fatalf("create: got %d: %s", rec.Code, rec.Body)
+			}
+
+			for _, ping := range tc.pings {
+				rec := httptest.NewRecorder()
+				s.router.ServeHTTP(rec, httptest.NewRequest("GET", *created.PingURL+ping, nil))
+				if rec.Code != http.StatusOK {
+					t.Fatalf("%s ping: expected 200, got %d", ping, rec.Code)
+				}
+			}
+
+			// The first ping is expected on the schedule after creation
+			job, _ := s.repo.GetJob(created.ID)
+			due, ok := jobs.PingDue(*job)
+			if !ok || !due.After(job.CreatedAt) {
+				t.Fatalf("expected a ping after creation, got %v", due)
+			}
+
+			for _, step := range []struct {
+				at   time.Time
+				want string
+			}{
+				{due.Add(-time.Second), ""},
+				{due.Add(30 * time.Second), "LATE"},
+				{due.Add(2 * time.Minute), "DOWN"},
+				{due.Add(24 * time.Hour), "DOWN"},
+			} {
+				s.scheduler.CheckHeartbeats(step.at)
+				if job, _ := s.repo.GetJob(created.ID); job.LastStatus.String != step.want {
+					t.Errorf("at due%+v: expected %q, got %q", step.at.Sub(due), step.want, job.LastStatus.String)
+				}
+			}
+		})
+	}
+}
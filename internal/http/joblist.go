@@ -0,0 +1,89 @@
+package http
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/rauche/cronnor/internal/models"
+)
+
+// jobsPerPage is the number of jobs on a dashboard page
+const jobsPerPage = 24
+
+// jobFilterFromRequest reads the search, tag and team filters from the query
+// string or, for HTMX requests that include the filter form, the form body
+func jobFilterFromRequest(r *http.Request) models.JobFilter {
+	return models.JobFilter{
+		Tag:    strings.ToLower(strings.TrimSpace(r.FormValue("tag"))),
+		Team:   strings.TrimSpace(r.FormValue("team")),
+		Search: strings.TrimSpace(r.FormValue("q")),
+	}
+}
+
+// jobListOptionsFromRequest reads the dashboard's filters, sort order and page
+func jobListOptionsFromRequest(r *http.Request) models.JobListOptions {
+	opts := models.JobListOptions{
+		Filter:  jobFilterFromRequest(r),
+		Sort:    models.JobSortCreated,
+		PerPage: jobsPerPage,
+	}
+
+	for _, sort := range models.JobSorts {
+		if r.FormValue("sort") == sort {
+			opts.Sort = sort
+		}
+	}
+
+	switch r.FormValue("order") {
+	case "asc":
+		opts.Desc = false
+	case "desc":
+		opts.Desc = true
+	default:
+		opts.Desc = defaultSortDesc(opts.Sort)
+	}
+
+	opts.Page, _ = strconv.Atoi(r.FormValue("page"))
+	return opts
+}
+
+// defaultSortDesc reports whether a sort key lists the largest values first
+// unless the order is given, so the newest and most recently run jobs lead
+func defaultSortDesc(sort string) bool {
+	return sort == models.JobSortCreated || sort == models.JobSortLastRun
+}
+
+// jobListURL returns the dashboard URL showing page of the listing described
+// by opts, leaving out values that are the default
+func jobListURL(opts models.JobListOptions, page int) string {
+	q := url.Values{}
+	if opts.Filter.Search != "" {
+		q.Set("q", opts.Filter.Search)
+	}
+	if opts.Filter.Tag != "" {
+		q.Set("tag", opts.Filter.Tag)
+	}
+	if opts.Filter.Team != "" {
+		q.Set("team", opts.Filter.Team)
+	}
+	if opts.Sort != models.JobSortCreated {
+		q.Set("sort", opts.Sort)
+	}
+	if opts.Desc != defaultSortDesc(opts.Sort) {
+		if opts.Desc {
+			q.Set("order", "desc")
+		} else {
+			q.Set("order", "asc")
+		}
+	}
+	if page > 1 {
+		q.Set("page", strconv.Itoa(page))
+	}
+
+	if len(q) == 0 {
+		return "/jobs"
+	}
+	return "/jobs?" + q.Encode()
+}
@@ -0,0 +1,103 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rauche/cronnor/internal/auth"
+	"github.com/rauche/cronnor/internal/models"
+)
+
+func TestListJobsSearchSortAndPaginate(t *testing.T) {
+	s := newTestServer(t)
+	cookie, _ := createTestUser(t, s, auth.RoleViewer)
+
+	for i := 0; i < 30; i++ {
+		params := models.CreateJobParams{
+			Name:     fmt.Sprintf("job-%02d", i),
+			CronExpr: fmt.Sprintf("0 %d * * * *", i),
+			URL:      "http://example.com/" + fmt.Sprint(i),
+			Method:   "GET",
+		}
+		if i%10 == 0 {
+			params.Tags = []string{"alpha"}
+		}
+		id, err := s.repo.CreateJob(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		job, _ := s.repo.GetJob(id)
+		s.scheduler.AddJob(*job)
+	}
+
+	// Paused jobs have no next run and sort last
+	if err := s.repo.ToggleJob(1); err != nil {
+		t.Fatal(err)
+	}
+	s.scheduler.ReloadJob(1)
+
+	list := func(opts models.JobListOptions) *models.JobPage {
+		t.Helper()
+		page, err := s.repo.ListJobs(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return page
+	}
+
+	page := list(models.JobListOptions{Page: 2, PerPage: 24})
+	if page.Total != 30 || len(page.Jobs) != 6 || page.Pages() != 2 || page.First() != 25 {
+		t.Errorf("page 2: expected jobs 25-30 of 30, got %d-%d of %d", page.First(), page.Last(), page.Total)
+	}
+	if page := list(models.JobListOptions{Page: 9, PerPage: 24}); page.Page != 2 {
+		t.Errorf("a page past the end should clamp to the last page, got %d", page.Page)
+	}
+
+	for search, want := range map[string]int{"alpha": 3, "JOB-1": 10, "example.com/29": 1, "%": 0, "_": 0} {
+		if page := list(models.JobListOptions{Filter: models.JobFilter{Search: search}}); page.Total != want {
+			t.Errorf("search %q: expected %d jobs, got %d", search, want, page.Total)
+		}
+	}
+
+	if page := list(models.JobListOptions{Sort: models.JobSortName, Desc: true}); page.Jobs[0].Name != "job-29" {
+		t.Errorf("name descending: expected job-29 first, got %s", page.Jobs[0].Name)
+	}
+
+	page = list(models.JobListOptions{Sort: models.JobSortNextRun, PerPage: 30})
+	for i := 1; i < 29; i++ {
+		if page.Jobs[i].NextRunAt.Time.Before(page.Jobs[i-1].NextRunAt.Time) {
+			t.Fatalf("next run: %s is listed after %s but runs earlier", page.Jobs[i].Name, page.Jobs[i-1].Name)
+		}
+	}
+	if last := page.Jobs[29]; last.Name != "job-00" || last.NextRunAt.Valid {
+		t.Errorf("next run: expected paused job-00 last, got %s", last.Name)
+	}
+
+	req := httptest.NewRequest("GET", "/jobs/list?q=alpha&sort=name&order=desc", nil)
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Showing 1–3 of 3 jobs") {
+		t.Errorf("list partial: expected 3 matching jobs, got %d", rec.Code)
+	}
+	if strings.Index(rec.Body.String(), "job-20") > strings.Index(rec.Body.String(), "job-10") {
+		t.Error("list partial should honour the sort order")
+	}
+}
+
+func TestJobListURL(t *testing.T) {
+	opts := models.JobListOptions{
+		Filter: models.JobFilter{Search: "a b", Tag: "nightly"},
+		Sort:   models.JobSortName,
+		Desc:   true,
+	}
+	if got, want := jobListURL(opts, 2), "/jobs?order=desc&page=2&q=a+b&sort=name&tag=nightly"; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	if got := jobListURL(models.JobListOptions{Sort: models.JobSortCreated, Desc: true}, 1); got != "/jobs" {
+		t.Errorf("default listing: expected /jobs, got %s", got)
+	}
+}
@@ -0,0 +1,166 @@
+package http
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rauche/cronnor/internal/auth"
+	"github.com/rauche/cronnor/internal/metrics"
+	"github.com/rauche/cronnor/internal/models"
+)
+
+func TestMetrics(t *testing.T) {
+	s := newTestServer(t)
+	_, token := createTestUser(t, s, auth.RoleAdmin)
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer target.Close()
+
+	api := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		return rec
+	}
+	scrape := func(header string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/metrics", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	var backup apiJob
+	for _, job := range []string{
+		`{"name":"sync","cron_expr":"0 0 * * * *","url":"` + target.URL + `/ok"}`,
+		`{"name":"report","cron_expr":"0 0 * * * *","url":"` + target.URL + `/fail"}`,
+		`{"kind":"heartbeat","name":"backup","cron_expr":"0 0 * * * *"}`,
+		`{"name":"paused","cron_expr":"0 0 * * * *","url":"` + target.URL + `/ok"}`,
+	} {
+		rec := api("POST", "/api/v1/jobs", job)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("create job: expected 201, got %d: %s", rec.Code, rec.Body)
+		}
+		if strings.Contains(job, "heartbeat") {
+			json.Unmarshal(rec.Body.Bytes(), &backup)
+		}
+	}
+	if rec := api("POST", "/api/v1/jobs/4/toggle", ""); rec.Code != http.StatusOK {
+		t.Fatalf("pause: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	for _, id := range []string{"1", "2"} {
+		if rec := api("POST", "/api/v1/jobs/"+id+"/run", ""); rec.Code != http.StatusAccepted {
+			t.Fatalf("run: expected 202, got %d: %s", rec.Code, rec.Body)
+		}
+	}
+	for _, signal := range []string{"/start", ""} {
+		req := httptest.NewRequest("POST", *backup.PingURL+signal, nil)
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("ping: expected 200, got %d", rec.Code)
+		}
+	}
+
+	var body string
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		body = scrape("").Body.String()
+		if strings.Contains(body, `status="FAILED",trigger="manual"`) && strings.Contains(body, `status="SUCCESS",trigger="manual"`) {
+			break
+		}
+	}
+
+	for _, want := range []string{
+		`cronnor_executions_total{job_name="sync",status="SUCCESS",trigger="manual"} 1`,
+		`cronnor_executions_total{job_name="report",status="FAILED",trigger="manual"} 1`,
+		`cronnor_executions_total{job_name="backup",status="SUCCESS",trigger="ping"} 1`,
+		`cronnor_execution_duration_seconds_count{job_name="sync",status="SUCCESS"} 1`,
+		`cronnor_execution_duration_seconds_count{job_name="backup",status="SUCCESS"} 1`,
+		`cronnor_executions_in_flight 0`,
+		`cronnor_jobs_active 3`,
+		`cronnor_scheduler_entries 2`,
+		`cronnor_http_requests_total{code="202",method="POST",route="/api/v1/jobs/{id}/run"} 2`,
+		`cronnor_http_requests_total{code="200",method="POST",route="/ping/{token}"} 1`,
+		`cronnor_http_request_duration_seconds_count{method="POST",route="/api/v1/jobs"} 4`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected the metrics to contain %s", want)
+		}
+	}
+	// Start pings are not executions, and manual runs have no schedule lag
+	if strings.Contains(body, `status="STARTED"`) || strings.Contains(body, "cronnor_schedule_lag_seconds_count") {
+		t.Error("expected no start pings or schedule lag in the metrics")
+	}
+
+	// Pausing a job takes it off the schedule
+	if rec := api("POST", "/api/v1/jobs/1/toggle", ""); rec.Code != http.StatusOK {
+		t.Fatalf("pause: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	body = scrape("").Body.String()
+	if !strings.Contains(body, "cronnor_jobs_active 2") || !strings.Contains(body, "cronnor_scheduler_entries 1") {
+		t.Error("expected the paused job to be neither active nor scheduled")
+	}
+
+	s.cfg.MetricsToken = "scrape-secret"
+	if rec := scrape(""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("without the token: expected 401, got %d", rec.Code)
+	}
+	if rec := scrape("Bearer wrong"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("with a wrong token: expected 401, got %d", rec.Code)
+	}
+	if rec := scrape("Bearer scrape-secret"); rec.Code != http.StatusOK {
+		t.Errorf("with the token: expected 200, got %d", rec.Code)
+	}
+}
+
+func TestMetricsJobLabels(t *testing.T) {
+	s := newTestServer(t)
+
+	if _, err := metrics.New(s.repo, "id"); err == nil {
+		t.Error("expected an unknown job label mode to be rejected")
+	}
+
+	job := models.Job{Name: "nightly-export", Team: "data"}
+	entry := models.JobLog{
+		Status:     "SUCCESS",
+		Trigger:    models.TriggerSchedule,
+		DurationMs: sql.NullInt64{Int64: 1500, Valid: true},
+		LagMs:      sql.NullInt64{Int64: 20, Valid: true},
+	}
+
+	for mode, want := range map[string]string{
+		metrics.JobLabelName: `cronnor_schedule_lag_seconds_count{job_name="nightly-export"} 1`,
+		metrics.JobLabelTeam: `cronnor_schedule_lag_seconds_count{team="data"} 1`,
+		metrics.JobLabelNone: `cronnor_schedule_lag_seconds_count 1`,
+	} {
+		m, err := metrics.New(s.repo, mode)
+		if err != nil {
+			t.Fatalf("%s: %v", mode, err)
+		}
+		m.ObserveExecution(job, entry)
+
+		rec := httptest.NewRecorder()
+		m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+		body := rec.Body.String()
+		if !strings.Contains(body, want) {
+			t.Errorf("%s: expected the metrics to contain %s", mode, want)
+		}
+		if mode == metrics.JobLabelNone && strings.Contains(body, "nightly-export") {
+			t.Errorf("%s: expected no per-job labels", mode)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package http
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/rauche/cronnor/internal/auth"
+	"github.com/rauche/cronnor/internal/storage"
+)
+
+// apiTokenAuth authenticates API requests with an "Authorization: Bearer" token
+func (s *Server) apiTokenAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		plaintext, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok || plaintext == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="cronnor"`)
+			writeAPIError(w, http.StatusUnauthorized, "missing bearer token")
+			return
+		}
+
+		token, err := s.repo.GetAPITokenByHash(auth.HashToken(strings.TrimSpace(plaintext)))
+		if err != nil {
+			if !errors.Is(err, storage.ErrTokenNotFound) {
+				writeAPIError(w, http.StatusInternalServerError, "failed to verify token")
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="cronnor", error="invalid_token"`)
+			writeAPIError(w, http.StatusUnauthorized, "invalid token")
+			return
+		}
+
+		if token.Expired() {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="cronnor", error="invalid_token"`)
+			writeAPIError(w, http.StatusUnauthorized, "token expired")
+			return
+		}
+
+		owner, err := s.repo.GetUser(token.UserID)
+		if err != nil {
+			if !errors.Is(err, storage.ErrUserNotFound) {
+				writeAPIError(w, http.StatusInternalServerError, "failed to verify token")
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="cronnor", error="invalid_token"`)
+			writeAPIError(w, http.StatusUnauthorized, "token has no owner")
+			return
+		}
+
+		if err := s.repo.TouchAPIToken(token.ID); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+
+		ctx := auth.WithUser(auth.WithToken(r.Context(), token), owner)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requirePermission rejects requests whose user's role lacks a permission.
+// Token-authenticated requests also need the matching token scope.
+func requirePermission(perm auth.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !checkPermission(w, r, perm) {
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// checkPermission reports whether the request's user, and its API token if
+// any, may use perm, writing a 403 response if not
+func checkPermission(w http.ResponseWriter, r *http.Request, perm auth.Permission) bool {
+	user := auth.UserFromContext(r.Context())
+	allowed := user != nil && auth.Role(user.Role).Can(perm)
+
+	if token := auth.TokenFromContext(r.Context()); token != nil {
+		scope := auth.ScopeFor(perm)
+		if !auth.HasScope(token.Scopes, scope) {
+			writeAPIError(w, http.StatusForbidden, "token lacks required scope: "+scope)
+			return false
+		}
+		if !allowed {
+			writeAPIError(w, http.StatusForbidden, "token owner's role does not permit this action")
+			return false
+		}
+	} else if !allowed {
+		http.Error(w, "You don't have permission to do that", http.StatusForbidden)
+		return false
+	}
+
+	return true
+}
+
+// requestTimeout cancels requests after timeout, except those for the
+// long-lived streaming paths
+func requestTimeout(timeout time.Duration, streaming ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		limited := middleware.Timeout(timeout)(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, path := range streaming {
+				if r.URL.Path == path {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			limited.ServeHTTP(w, r)
+		})
+	}
+}
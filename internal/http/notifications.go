@@ -0,0 +1,488 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rauche/cronnor/internal/models"
+	"github.com/rauche/cronnor/internal/notify"
+	"github.com/rauche/cronnor/internal/storage"
+)
+
+// recentAlerts caps the alerts shown for a job
+const recentAlerts = 20
+
+// apiAlertRuleRequest is the request body for creating an alert rule
+type apiAlertRuleRequest struct {
+	ChannelID int64  `json:"channel_id"`
+	Event     string `json:"event"`
+	Threshold int64  `json:"threshold"`
+}
+
+// apiAlert is the JSON representation of an alert and its delivery attempts
+type apiAlert struct {
+	ID          int64                    `json:"id"`
+	RuleID      *int64                   `json:"rule_id"`
+	JobID       int64                    `json:"job_id"`
+	ChannelName string                   `json:"channel_name"`
+	Event       string                   `json:"event"`
+	Message     string                   `json:"message"`
+	Delivered   bool                     `json:"delivered"`
+	CreatedAt   time.Time                `json:"created_at"`
+	Attempts    []apiNotificationAttempt `json:"attempts"`
+}
+
+// apiNotificationAttempt is the JSON representation of a delivery attempt
+type apiNotificationAttempt struct {
+	Attempt   int       `json:"attempt"`
+	Status    string    `json:"status"`
+	Error     *string   `json:"error"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newAPIAlert(alert models.Alert) apiAlert {
+	resp := apiAlert{
+		ID:          alert.ID,
+		JobID:       alert.JobID,
+		ChannelName: alert.ChannelName,
+		Event:       alert.Event,
+		Message:     alert.Message,
+		Delivered:   alert.Delivered(),
+		CreatedAt:   alert.CreatedAt,
+		Attempts:    make([]apiNotificationAttempt, 0, len(alert.Attempts)),
+	}
+	// Alerts outlive the rule that raised them
+	if alert.RuleID != 0 {
+		resp.RuleID = &alert.RuleID
+	}
+	for _, a := range alert.Attempts {
+		resp.Attempts = append(resp.Attempts, apiNotificationAttempt{
+			Attempt:   a.Attempt,
+			Status:    a.Status,
+			Error:     nullString(a.Error),
+			CreatedAt: a.CreatedAt,
+		})
+	}
+	return resp
+}
+
+// renderChannels renders the notification channels page with an optional
+// form error
+func (s *Server) renderChannels(w http.ResponseWriter, r *http.Request, formError string) {
+	channels, err := s.repo.GetChannels()
+	if err != nil {
+		http.Error(w, "Failed to load channels", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]interface{}{
+		"Channels": channels,
+		"Types":    models.ChannelTypes,
+		"Error":    formError,
+	}
+
+	s.render(w, r, "notifications.html", data)
+}
+
+// handleChannels shows the notification channels page
+func (s *Server) handleChannels(w http.ResponseWriter, r *http.Request) {
+	s.renderChannels(w, r, "")
+}
+
+// handleCreateChannel creates a notification channel
+func (s *Server) handleCreateChannel(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	channelType := r.FormValue("type")
+	target := strings.TrimSpace(r.FormValue("target"))
+
+	if fields := notify.ValidateChannel(name, channelType, target); len(fields) > 0 {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		s.renderChannels(w, r, fieldErrorMessage(fields))
+		return
+	}
+
+	id, err := s.repo.CreateChannel(name, channelType, target)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		s.renderChannels(w, r, "Failed to create channel; the name may already be taken")
+		return
+	}
+
+	s.audit(r, models.AuditEntry{
+		Action:     models.AuditChannelCreate,
+		TargetType: "channel",
+		TargetID:   id,
+		TargetName: name,
+		Changes: []models.FieldChange{
+			{Field: "name", After: name},
+			{Field: "type", After: channelType},
+		},
+	})
+
+	http.Redirect(w, r, "/notifications", http.StatusSeeOther)
+}
+
+// handleDeleteChannel deletes a notification channel and the alert rules
+// using it
+func (s *Server) handleDeleteChannel(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid channel ID", http.StatusBadRequest)
+		return
+	}
+
+	channel, err := s.repo.GetChannel(id)
+	if err != nil {
+		http.Error(w, "Channel not found", http.StatusNotFound)
+		return
+	}
+
+	if err := s.repo.DeleteChannel(id); err != nil {
+		http.Error(w, "Failed to delete channel", http.StatusInternalServerError)
+		return
+	}
+
+	s.audit(r, models.AuditEntry{
+		Action:     models.AuditChannelDelete,
+		TargetType: "channel",
+		TargetID:   id,
+		TargetName: channel.Name,
+		Changes: []models.FieldChange{
+			{Field: "name", Before: channel.Name},
+			{Field: "type", Before: channel.Type},
+		},
+	})
+
+	http.Redirect(w, r, "/notifications", http.StatusSeeOther)
+}
+
+// fieldErrorMessage joins validation errors into one sentence for a form,
+// in a stable order
+func fieldErrorMessage(fields map[string]string) string {
+	var parts []string
+	for _, field := range []string{"name", "type", "target", "cron_expr", "window", "channel_id", "event", "threshold"} {
+		if msg, ok := fields[field]; ok {
+			parts = append(parts, strings.ReplaceAll(field, "_", " ")+" "+msg)
+		}
+	}
+	msg := strings.Join(parts, "; ")
+	return strings.ToUpper(msg[:1]) + msg[1:]
+}
+
+// createAlertRule validates and stores an alert rule for a job and records
+// the change. Invalid rules return the message for each invalid field.
+func (s *Server) createAlertRule(r *http.Request, job *models.Job, req apiAlertRuleRequest) (*models.AlertRule, map[string]string, error) {
+	req.Event = strings.TrimSpace(req.Event)
+	fields := notify.ValidateRule(req.Event, &req.Threshold)
+	if _, err := s.repo.GetChannel(req.ChannelID); errors.Is(err, storage.ErrChannelNotFound) {
+		fields["channel_id"] = "does not exist"
+	} else if err != nil {
+		return nil, nil, err
+	}
+	if len(fields) > 0 {
+		return nil, fields, nil
+	}
+
+	id, err := s.repo.CreateAlertRule(job.ID, req.ChannelID, req.Event, req.Threshold)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rule, err := s.repo.GetAlertRule(job.ID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.auditAlertRule(r, job, nil, rule)
+
+	return rule, nil, nil
+}
+
+// deleteAlertRule removes one of a job's alert rules and records the change
+func (s *Server) deleteAlertRule(r *http.Request, job *models.Job, id int64) error {
+	rule, err := s.repo.GetAlertRule(job.ID, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.DeleteAlertRule(job.ID, id); err != nil {
+		return err
+	}
+	s.auditAlertRule(r, job, rule, nil)
+
+	return nil
+}
+
+// auditAlertRule records an alert rule being added to or removed from a job.
+// before is nil for created rules and after is nil for deleted ones.
+func (s *Server) auditAlertRule(r *http.Request, job *models.Job, before, after *models.AlertRule) {
+	change := models.FieldChange{Field: "alert_rule"}
+	if before != nil {
+		change.Before = before.String()
+	}
+	if after != nil {
+		change.After = after.String()
+	}
+
+	s.audit(r, models.AuditEntry{
+		Action:     models.AuditJobAlert,
+		TargetType: "job",
+		TargetID:   job.ID,
+		TargetName: job.Name,
+		Changes:    []models.FieldChange{change},
+	})
+}
+
+// alertData returns the template data for a job's alert rules card
+func (s *Server) alertData(job *models.Job) (map[string]interface{}, error) {
+	rules, err := s.repo.GetAlertRules(job.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	alerts, err := s.repo.GetAlerts(job.ID, recentAlerts)
+	if err != nil {
+		return nil, err
+	}
+
+	channels, err := s.repo.GetChannels()
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"Job":        job,
+		"AlertRules": rules,
+		"Alerts":     alerts,
+		"Channels":   channels,
+		"Events":     models.AlertEvents,
+	}, nil
+}
+
+// renderAlerts renders a job's alert rules card with an optional form error
+func (s *Server) renderAlerts(w http.ResponseWriter, r *http.Request, job *models.Job, formError string) {
+	data, err := s.alertData(job)
+	if err != nil {
+		http.Error(w, "Failed to load alert rules", http.StatusInternalServerError)
+		return
+	}
+	data["AlertError"] = formError
+
+	s.render(w, r, "_alerts.html", data)
+}
+
+// handleCreateAlertRule adds an alert rule to a job
+func (s *Server) handleCreateAlertRule(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid job ID", http.StatusBadRequest)
+		return
+	}
+
+	job, err := s.repo.GetJob(id)
+	if err != nil {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	req := apiAlertRuleRequest{Event: r.FormValue("event")}
+	req.ChannelID, _ = strconv.ParseInt(r.FormValue("channel_id"), 10, 64)
+	req.Threshold, _ = strconv.ParseInt(r.FormValue("threshold"), 10, 64)
+
+	_, fields, err := s.createAlertRule(r, job, req)
+	if err != nil {
+		http.Error(w, "Failed to create alert rule", http.StatusInternalServerError)
+		return
+	}
+
+	// Like test results, form errors are shown in the card itself
+	formError := ""
+	if len(fields) > 0 {
+		formError = fieldErrorMessage(fields)
+	}
+	s.renderAlerts(w, r, job, formError)
+}
+
+// handleDeleteAlertRule removes an alert rule from a job
+func (s *Server) handleDeleteAlertRule(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid job ID", http.StatusBadRequest)
+		return
+	}
+	ruleID, err := strconv.ParseInt(chi.URLParam(r, "rule"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid alert rule ID", http.StatusBadRequest)
+		return
+	}
+
+	job, err := s.repo.GetJob(id)
+	if err != nil {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	err = s.deleteAlertRule(r, job, ruleID)
+	if errors.Is(err, storage.ErrAlertRuleNotFound) {
+		http.Error(w, "Alert rule not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to delete alert rule", http.StatusInternalServerError)
+		return
+	}
+
+	s.renderAlerts(w, r, job, "")
+}
+
+// handleAPIChannels returns every notification channel, without its target
+func (s *Server) handleAPIChannels(w http.ResponseWriter, r *http.Request) {
+	channels, err := s.repo.GetChannels()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "failed to load channels")
+		return
+	}
+	if channels == nil {
+		channels = []models.NotificationChannel{}
+	}
+
+	writeJSON(w, http.StatusOK, channels)
+}
+
+// handleAPIAlertRules returns a job's alert rules
+func (s *Server) handleAPIAlertRules(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiJobID(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := s.repo.GetJob(id); err != nil {
+		writeJobError(w, err, "failed to load job")
+		return
+	}
+
+	rules, err := s.repo.GetAlertRules(id)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "failed to load alert rules")
+		return
+	}
+	if rules == nil {
+		rules = []models.AlertRule{}
+	}
+
+	writeJSON(w, http.StatusOK, rules)
+}
+
+// handleAPICreateAlertRule adds an alert rule to a job
+func (s *Server) handleAPICreateAlertRule(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiJobID(w, r)
+	if !ok {
+		return
+	}
+
+	job, err := s.repo.GetJob(id)
+	if err != nil {
+		writeJobError(w, err, "failed to load job")
+		return
+	}
+
+	var req apiAlertRuleRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return
+	}
+
+	rule, fields, err := s.createAlertRule(r, job, req)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "failed to create alert rule")
+		return
+	}
+	if len(fields) > 0 {
+		writeJSON(w, http.StatusUnprocessableEntity, apiError{Error: "validation failed", Fields: fields})
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, rule)
+}
+
+// handleAPIDeleteAlertRule removes an alert rule from a job
+func (s *Server) handleAPIDeleteAlertRule(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiJobID(w, r)
+	if !ok {
+		return
+	}
+	ruleID, err := strconv.ParseInt(chi.URLParam(r, "rule"), 10, 64)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid alert rule ID")
+		return
+	}
+
+	job, err := s.repo.GetJob(id)
+	if err != nil {
+		writeJobError(w, err, "failed to load job")
+		return
+	}
+
+	err = s.deleteAlertRule(r, job, ruleID)
+	if errors.Is(err, storage.ErrAlertRuleNotFound) {
+		writeAPIError(w, http.StatusNotFound, "alert rule not found")
+		return
+	} else if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "failed to delete alert rule")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAPIAlerts returns a job's most recent alerts and their delivery
+// attempts, newest first
+func (s *Server) handleAPIAlerts(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiJobID(w, r)
+	if !ok {
+		return
+	}
+
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 1000 {
+			writeJSON(w, http.StatusUnprocessableEntity, apiError{
+				Error:  "validation failed",
+				Fields: map[string]string{"limit": "must be an integer between 1 and 1000"},
+			})
+			return
+		}
+		limit = n
+	}
+
+	if _, err := s.repo.GetJob(id); err != nil {
+		writeJobError(w, err, "failed to load job")
+		return
+	}
+
+	alerts, err := s.repo.GetAlerts(id, limit)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "failed to load alerts")
+		return
+	}
+
+	resp := make([]apiAlert, 0, len(alerts))
+	for _, alert := range alerts {
+		resp = append(resp, newAPIAlert(alert))
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
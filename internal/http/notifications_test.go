@@ -0,0 +1,326 @@
+package http
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rauche/cronnor/internal/auth"
+	"github.com/rauche/cronnor/internal/events"
+	"github.com/rauche/cronnor/internal/models"
+	"github.com/rauche/cronnor/internal/notify"
+)
+
+// smtpStub accepts mail on a local port and sends each message's data to
+// the returned channel
+func smtpStub(t *testing.T) (string, int, <-chan string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	messages := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+				reply("220 stub ESMTP")
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+					case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+						reply("250 stub")
+					case cmd == "DATA":
+						reply("354 go ahead")
+						var data strings.Builder
+						for {
+							line, err := r.ReadString('\n')
+							if err != nil {
+								return
+							}
+							if line == ".\r\n" {
+								break
+							}
+							data.WriteString(line)
+						}
+						messages <- data.String()
+						reply("250 queued")
+					case cmd == "QUIT":
+						reply("221 bye")
+						return
+					default:
+						reply("250 OK")
+					}
+				}
+			}()
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, messages
+}
+
+func TestAlertRules(t *testing.T) {
+	s := newTestServer(t)
+	cookie, token := createTestUser(t, s, auth.RoleAdmin)
+
+	host, port, mail := smtpStub(t)
+
+	slack := make(chan string, 10)
+	slackStub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ Text string }
+		json.NewDecoder(r.Body).Decode(&body)
+		slack <- body.Text
+	}))
+	defer slackStub.Close()
+
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer broken.Close()
+
+	var failing atomic.Bool
+	failing.Store(true)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer target.Close()
+
+	notifier := notify.New(s.repo, notify.Config{
+		SMTP:    notify.SMTPConfig{Host: host, Port: port, From: "cronnor@example.com"},
+		Backoff: time.Millisecond,
+	})
+	s.scheduler.OnOutcome(notifier.HandleOutcome)
+	notifier.Start()
+	defer notifier.Stop()
+
+	web := func(path string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set(csrfHeader, csrfTokenFor(cookie.Value))
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		return rec
+	}
+	api := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, ch := range []url.Values{
+		{"name": {"ops-email"}, "type": {"email"}, "target": {"ops@example.com, oncall@example.com"}},
+		{"name": {"ops-slack"}, "type": {"slack"}, "target": {slackStub.URL}},
+		{"name": {"broken"}, "type": {"webhook"}, "target": {broken.URL}},
+	} {
+		if rec := web("/notifications", ch); rec.Code != http.StatusSeeOther {
+			t.Fatalf("create channel %s: expected 303, got %d: %s", ch.Get("name"), rec.Code, rec.Body)
+		}
+	}
+	if rec := web("/notifications", url.Values{"name": {"bad"}, "type": {"email"}, "target": {"not an address"}}); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("invalid channel: expected 422, got %d", rec.Code)
+	}
+
+	rec := api("POST", "/api/v1/jobs", `{"name":"nightly","cron_expr":"0 0 * * * *","url":"`+target.URL+`"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create job: expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+
+	for _, rule := range []string{
+		`{"channel_id":1,"event":"failure"}`,
+		`{"channel_id":2,"event":"consecutive_failures","threshold":2}`,
+		`{"channel_id":2,"event":"recovery"}`,
+		`{"channel_id":3,"event":"slow","threshold":5}`,
+	} {
+		if rec := api("POST", "/api/v1/jobs/1/alert-rules", rule); rec.Code != http.StatusCreated {
+			t.Fatalf("create rule %s: expected 201, got %d: %s", rule, rec.Code, rec.Body)
+		}
+	}
+	rec = api("POST", "/api/v1/jobs/1/alert-rules", `{"channel_id":9,"event":"consecutive_failures","threshold":1}`)
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), "channel_id") || !strings.Contains(rec.Body.String(), "threshold") {
+		t.Errorf("invalid rule: expected 422, got %d: %s", rec.Code, rec.Body)
+	}
+
+	alerts := func(want int) []apiAlert {
+		t.Helper()
+		var got []apiAlert
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			rec := api("GET", "/api/v1/jobs/1/alerts", "")
+			got = nil
+			json.Unmarshal(rec.Body.Bytes(), &got)
+			if len(got) == want {
+				return got
+			}
+		}
+		t.Fatalf("expected %d alerts, got %d", want, len(got))
+		return nil
+	}
+	receive := func(ch <-chan string) string {
+		t.Helper()
+		select {
+		case msg := <-ch:
+			return msg
+		case <-time.After(5 * time.Second):
+			t.Fatal("no notification was sent")
+			return ""
+		}
+	}
+	run := func(n int) {
+		t.Helper()
+		if rec := api("POST", "/api/v1/jobs/1/run", ""); rec.Code != http.StatusAccepted {
+			t.Fatalf("run: expected 202, got %d: %s", rec.Code, rec.Body)
+		}
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			if logs, _ := s.repo.GetJobLogs(1, 10); len(logs) == n {
+				return
+			}
+		}
+		t.Fatalf("run %d did not finish", n)
+	}
+
+	// The first failure alerts by email and, being slow, to the broken webhook
+	run(1)
+	alerts(2)
+	msg := receive(mail)
+	if !strings.Contains(msg, "Subject: [Cronnor] nightly failed: HTTP 500") || !strings.Contains(msg, "To: ops@example.com, oncall@example.com") {
+		t.Errorf("unexpected email:\n%s", msg)
+	}
+
+	// The second alerts Slack; neither it nor the third repeats the failure
+	// or slow alerts
+	run(2)
+	alerts(3)
+	if text := receive(slack); text != "nightly failed 2 times in a row: HTTP 500" {
+		t.Errorf("unexpected Slack message %q", text)
+	}
+	run(3)
+
+	failing.Store(false)
+	run(4)
+	alerts(4)
+	if text := receive(slack); text != "nightly recovered after 3 failures" {
+		t.Errorf("unexpected Slack message %q", text)
+	}
+
+	// Delivery attempts continue in the background
+	byEvent := func() map[string]apiAlert {
+		events := make(map[string]apiAlert)
+		for _, a := range alerts(4) {
+			events[a.Event] = a
+		}
+		return events
+	}
+	settled := func(events map[string]apiAlert) bool {
+		for event, a := range events {
+			if len(a.Attempts) == 0 || (event == models.AlertOnSlow && len(a.Attempts) < 3) {
+				return false
+			}
+		}
+		return true
+	}
+	events := byEvent()
+	for deadline := time.Now().Add(5 * time.Second); !settled(events) && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+		events = byEvent()
+	}
+	if a := events[models.AlertOnSlow]; a.Delivered || len(a.Attempts) != 3 || !strings.Contains(*a.Attempts[2].Error, "503") {
+		t.Errorf("expected 3 failed attempts to deliver the slow alert, got %+v", a)
+	}
+	for _, event := range []string{models.AlertOnFailure, models.AlertOnConsecutive, models.AlertOnRecovery} {
+		if a := events[event]; !a.Delivered || len(a.Attempts) != 1 {
+			t.Errorf("expected the %s alert to be delivered once, got %+v", event, a)
+		}
+	}
+
+	// Deleting a channel removes its rules but keeps their alerts
+	if rec := web("/notifications/3/delete", url.Values{}); rec.Code != http.StatusSeeOther {
+		t.Fatalf("delete channel: expected 303, got %d", rec.Code)
+	}
+	var rules []models.AlertRule
+	json.Unmarshal(api("GET", "/api/v1/jobs/1/alert-rules", "").Body.Bytes(), &rules)
+	if len(rules) != 3 {
+		t.Fatalf("expected 3 rules left, got %d", len(rules))
+	}
+	if a := byEvent()[models.AlertOnSlow]; a.RuleID != nil {
+		t.Errorf("expected the deleted rule's alert to be kept without its rule, got %+v", a)
+	}
+	if rec := api("DELETE", "/api/v1/jobs/1/alert-rules/"+strconv.FormatInt(rules[0].ID, 10), ""); rec.Code != http.StatusNoContent {
+		t.Errorf("delete rule: expected 204, got %d", rec.Code)
+	}
+}
+
+func TestAlertDescribesItsOwnExecution(t *testing.T) {
+	s := newTestServer(t)
+	_, token := createTestUser(t, s, auth.RoleAdmin)
+
+	slack := make(chan string, 10)
+	slackStub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ Text string }
+		json.NewDecoder(r.Body).Decode(&body)
+		slack <- body.Text
+	}))
+	defer slackStub.Close()
+
+	notifier := notify.New(s.repo, notify.Config{Backoff: time.Millisecond})
+	defer notifier.Stop()
+
+	channelID, err := s.repo.CreateChannel("ops", models.ChannelSlack, slackStub.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("POST", "/api/v1/jobs", strings.NewReader(`{"name":"nightly","cron_expr":"0 0 * * * *","url":"http://example.com"}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	s.router.ServeHTTP(httptest.NewRecorder(), req)
+	if _, err := s.repo.CreateAlertRule(1, channelID, models.AlertOnFailure, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// A failure followed by a success, with the failure handled only after
+	// the success is logged
+	failed, err := s.repo.CreateJobLog(models.JobLog{JobID: 1, Status: "FAILED", HTTPCode: sql.NullInt64{Int64: 503, Valid: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.repo.CreateJobLog(models.JobLog{JobID: 1, Status: "SUCCESS", HTTPCode: sql.NullInt64{Int64: 200, Valid: true}}); err != nil {
+		t.Fatal(err)
+	}
+	notifier.HandleOutcome(events.Event{Type: events.ExecutionFinished, JobID: 1, LogID: failed, Status: "FAILED"})
+
+	select {
+	case text := <-slack:
+		if text != "nightly failed: HTTP 503" {
+			t.Errorf("expected the alert to describe the failed execution, got %q", text)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no notification was sent")
+	}
+}
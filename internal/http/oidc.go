@@ -0,0 +1,142 @@
+package http
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/rauche/cronnor/internal/auth"
+	"github.com/rauche/cronnor/internal/config"
+)
+
+// oidcCookieName holds the state, nonce and PKCE verifier of a login in flight
+const oidcCookieName = "cronnor_oidc"
+
+// oidcFlowTTL bounds how long a user may take to log in at the provider
+const oidcFlowTTL = 10 * time.Minute
+
+// newOIDCProvider configures single sign-on from the server configuration
+func newOIDCProvider(cfg *config.Config) (*auth.OIDCProvider, error) {
+	groupRoles, err := auth.ParseGroupRoles(cfg.OIDCRoleMapping)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OIDC_ROLE_MAPPING: %w", err)
+	}
+	if cfg.OIDCDefaultRole != "" && !auth.ValidRole(cfg.OIDCDefaultRole) {
+		return nil, fmt.Errorf("invalid OIDC_DEFAULT_ROLE: %s", cfg.OIDCDefaultRole)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return auth.NewOIDCProvider(ctx, auth.OIDCConfig{
+		IssuerURL:    cfg.OIDCIssuer,
+		ClientID:     cfg.OIDCClientID,
+		ClientSecret: cfg.OIDCClientSecret,
+		RedirectURL:  cfg.OIDCRedirectURL,
+		GroupsClaim:  cfg.OIDCGroupsClaim,
+		GroupRoles:   groupRoles,
+		DefaultRole:  auth.Role(cfg.OIDCDefaultRole),
+	})
+}
+
+// handleOIDCLogin sends the browser to the identity provider
+func (s *Server) handleOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	state, nonce, verifier, err := auth.NewOIDCFlow()
+	if err != nil {
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
+
+	flow := url.Values{
+		"state":    {state},
+		"nonce":    {nonce},
+		"verifier": {verifier},
+		"next":     {r.URL.Query().Get("next")},
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcCookieName,
+		Value:    flow.Encode(),
+		Path:     "/auth/oidc",
+		MaxAge:   int(oidcFlowTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   s.cfg.CookieSecure,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, s.oidc.AuthCodeURL(state, nonce, verifier), http.StatusFound)
+}
+
+// handleOIDCCallback completes the login, provisioning the user on first sign-in
+func (s *Server) handleOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(oidcCookieName)
+	if err != nil {
+		s.renderLoginError(w, r, http.StatusBadRequest, "Your sign-in attempt expired, please try again")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcCookieName,
+		Value:    "",
+		Path:     "/auth/oidc",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   s.cfg.CookieSecure,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	flow, err := url.ParseQuery(cookie.Value)
+	if err != nil {
+		s.renderLoginError(w, r, http.StatusBadRequest, "Your sign-in attempt expired, please try again")
+		return
+	}
+
+	query := r.URL.Query()
+	if msg := query.Get("error"); msg != "" {
+		log.Printf("OIDC provider returned error: %s: %s", msg, query.Get("error_description"))
+		s.renderLoginError(w, r, http.StatusUnauthorized, "Single sign-on failed")
+		return
+	}
+
+	state := flow.Get("state")
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(query.Get("state"))) != 1 {
+		s.renderLoginError(w, r, http.StatusBadRequest, "Your sign-in attempt expired, please try again")
+		return
+	}
+
+	identity, err := s.oidc.Exchange(r.Context(), query.Get("code"), flow.Get("nonce"), flow.Get("verifier"))
+	if err != nil {
+		log.Printf("OIDC login failed: %v", err)
+		s.renderLoginError(w, r, http.StatusUnauthorized, "Single sign-on failed")
+		return
+	}
+
+	role, err := s.oidc.MapRole(identity.Groups)
+	if errors.Is(err, auth.ErrNoRole) {
+		s.renderLoginError(w, r, http.StatusForbidden, "Your account has no access to Cronnor")
+		return
+	}
+
+	user, err := s.repo.UpsertOIDCUser(identity.Subject, identity.Username, string(role))
+	if err != nil {
+		http.Error(w, "Failed to provision user", http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.startSession(w, user.ID); err != nil {
+		http.Error(w, "Failed to start session", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, safeRedirect(flow.Get("next")), http.StatusSeeOther)
+}
+
+// renderLoginError shows the login page with an error message
+func (s *Server) renderLoginError(w http.ResponseWriter, r *http.Request, status int, msg string) {
+	s.renderLogin(w, r, status, map[string]interface{}{
+		"Error": msg,
+	})
+}
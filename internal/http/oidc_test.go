@@ -0,0 +1,298 @@
+package http
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rauche/cronnor/internal/auth"
+	"github.com/rauche/cronnor/internal/config"
+)
+
+// mockOIDCProvider is a minimal OpenID Connect provider that issues ID tokens
+// for a fixed identity and enforces PKCE on the token endpoint
+type mockOIDCProvider struct {
+	*httptest.Server
+	t      *testing.T
+	key    *rsa.PrivateKey
+	groups []string
+
+	mu         sync.Mutex
+	challenges map[string]string // code -> code_challenge
+	nonces     map[string]string // code -> nonce
+}
+
+// newMockOIDCProvider starts a provider whose users belong to groups
+func newMockOIDCProvider(t *testing.T, groups []string) *mockOIDCProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &mockOIDCProvider{
+		t:          t,
+		key:        key,
+		groups:     groups,
+		challenges: make(map[string]string),
+		nonces:     make(map[string]string),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.handleDiscovery)
+	mux.HandleFunc("/authorize", p.handleAuthorize)
+	mux.HandleFunc("/token", p.handleToken)
+	mux.HandleFunc("/jwks", p.handleJWKS)
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+
+	return p
+}
+
+func (p *mockOIDCProvider) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"issuer":                                p.URL,
+		"authorization_endpoint":                p.URL + "/authorize",
+		"token_endpoint":                        p.URL + "/token",
+		"jwks_uri":                              p.URL + "/jwks",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+// handleAuthorize logs the user straight in and redirects back with a code
+func (p *mockOIDCProvider) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		http.Error(w, "PKCE required", http.StatusBadRequest)
+		return
+	}
+
+	code := "code-" + q.Get("state")
+	p.mu.Lock()
+	p.challenges[code] = q.Get("code_challenge")
+	p.nonces[code] = q.Get("nonce")
+	p.mu.Unlock()
+
+	redirect, _ := url.Parse(q.Get("redirect_uri"))
+	redirect.RawQuery = url.Values{"code": {code}, "state": {q.Get("state")}}.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (p *mockOIDCProvider) handleToken(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	code := r.PostForm.Get("code")
+
+	p.mu.Lock()
+	challenge, nonce := p.challenges[code], p.nonces[code]
+	delete(p.challenges, code)
+	p.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if challenge == "" || base64.RawURLEncoding.EncodeToString(sum[:]) != challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	clientID, _, ok := r.BasicAuth()
+	if !ok {
+		clientID = r.PostForm.Get("client_id")
+	}
+	idToken := p.sign(map[string]interface{}{
+		"iss":                p.URL,
+		"sub":                "user-123",
+		"aud":                clientID,
+		"exp":                time.Now().Add(time.Hour).Unix(),
+		"iat":                time.Now().Unix(),
+		"nonce":              nonce,
+		"preferred_username": "jdoe",
+		"groups":             p.groups,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+func (p *mockOIDCProvider) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"kid": "test",
+			"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+		}},
+	})
+}
+
+// sign encodes claims as an RS256 JWT
+func (p *mockOIDCProvider) sign(claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": "test"})
+	payload, _ := json.Marshal(claims)
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	sum := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, sum[:])
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// newOIDCTestServer creates a server with single sign-on against provider
+func newOIDCTestServer(t *testing.T, provider *mockOIDCProvider, mapping, defaultRole string) *Server {
+	t.Helper()
+
+	s := newTestServer(t)
+	s.cfg = &config.Config{
+		SessionTTL:       time.Hour,
+		OIDCIssuer:       provider.URL,
+		OIDCClientID:     "cronnor",
+		OIDCClientSecret: "secret",
+		OIDCRedirectURL:  "http://cronnor.test/auth/oidc/callback",
+		OIDCGroupsClaim:  "groups",
+		OIDCRoleMapping:  mapping,
+		OIDCDefaultRole:  defaultRole,
+	}
+
+	var err error
+	if s.oidc, err = newOIDCProvider(s.cfg); err != nil {
+		t.Fatalf("failed to configure OIDC: %v", err)
+	}
+	s.router = chi.NewRouter()
+	s.setupRoutes()
+	return s
+}
+
+// oidcLogin runs the browser side of the login flow and returns the callback response
+func oidcLogin(t *testing.T, s *Server) *httptest.ResponseRecorder {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest("GET", "/auth/oidc/login?next=/jobs/new", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("login: expected redirect, got %d", rec.Code)
+	}
+	flowCookie := rec.Result().Cookies()[0]
+
+	// The provider authenticates the user and redirects back to the callback
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize: expected redirect, got %d", resp.StatusCode)
+	}
+	callback, _ := url.Parse(resp.Header.Get("Location"))
+
+	req := httptest.NewRequest("GET", callback.RequestURI(), nil)
+	req.AddCookie(flowCookie)
+	rec = httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestOIDCLoginProvisionsUserWithMappedRole(t *testing.T) {
+	provider := newMockOIDCProvider(t, []string{"staff", "platform"})
+	s := newOIDCTestServer(t, provider, "staff=viewer,platform=editor", "")
+
+	rec := oidcLogin(t, s)
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/jobs/new" {
+		t.Fatalf("callback: expected redirect to /jobs/new, got %d %s", rec.Code, rec.Header().Get("Location"))
+	}
+
+	var session *http.Cookie
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == sessionCookieName && c.Value != "" {
+			session = c
+		}
+	}
+	if session == nil {
+		t.Fatal("callback did not set a session cookie")
+	}
+
+	user, err := s.repo.GetUserByUsername("jdoe")
+	if err != nil {
+		t.Fatalf("user was not provisioned: %v", err)
+	}
+	if user.Role != string(auth.RoleEditor) {
+		t.Errorf("expected role editor, got %s", user.Role)
+	}
+
+	// The session grants the mapped role's permissions
+	req := httptest.NewRequest("GET", "/jobs/new", nil)
+	req.AddCookie(session)
+	rec = httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("editor session: expected 200 for /jobs/new, got %d", rec.Code)
+	}
+
+	// Logging in again reuses the account and follows group changes
+	provider.groups = []string{"staff"}
+	if rec := oidcLogin(t, s); rec.Code != http.StatusSeeOther {
+		t.Fatalf("second login: expected redirect, got %d", rec.Code)
+	}
+	if n, _ := s.repo.CountUsers(); n != 1 {
+		t.Errorf("expected 1 user after second login, got %d", n)
+	}
+	if user, _ := s.repo.GetUserByUsername("jdoe"); user.Role != string(auth.RoleViewer) {
+		t.Errorf("expected role viewer after group change, got %s", user.Role)
+	}
+}
+
+func TestOIDCLoginRejectsUnmappedGroups(t *testing.T) {
+	provider := newMockOIDCProvider(t, []string{"contractors"})
+	s := newOIDCTestServer(t, provider, "platform=admin", "")
+
+	rec := oidcLogin(t, s)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", rec.Code)
+	}
+	if n, _ := s.repo.CountUsers(); n != 0 {
+		t.Errorf("expected no users to be provisioned, got %d", n)
+	}
+}
+
+func TestOIDCCallbackRejectsStateMismatch(t *testing.T) {
+	provider := newMockOIDCProvider(t, []string{"platform"})
+	s := newOIDCTestServer(t, provider, "platform=admin", "")
+
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest("GET", "/auth/oidc/login", nil))
+
+	req := httptest.NewRequest("GET", "/auth/oidc/callback?code=x&state=forged", nil)
+	req.AddCookie(rec.Result().Cookies()[0])
+	rec = httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", rec.Code)
+	}
+	if strings.Contains(rec.Header().Get("Set-Cookie"), sessionCookieName+"=") {
+		t.Error("state mismatch must not start a session")
+	}
+}
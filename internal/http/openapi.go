@@ -0,0 +1,23 @@
+package http
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// openAPISpec is the OpenAPI document describing the /api/v1 routes.
+// Every route registered under /api/v1 must have a matching entry.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// handleOpenAPISpec serves the OpenAPI document
+func (s *Server) handleOpenAPISpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
+
+// handleAPIDocs shows the interactive API reference
+func (s *Server) handleAPIDocs(w http.ResponseWriter, r *http.Request) {
+	s.render(w, r, "api_docs.html", nil)
+}
@@ -0,0 +1,76 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const apiPrefix = "/api/v1"
+
+// specOperations returns the "METHOD /path" operations declared in the spec
+func specOperations(t *testing.T) map[string]bool {
+	t.Helper()
+
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatalf("openapi.json is not valid JSON: %v", err)
+	}
+
+	ops := make(map[string]bool)
+	for path, item := range spec.Paths {
+		for method := range item {
+			if method == "parameters" {
+				continue
+			}
+			ops[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+	return ops
+}
+
+// routeOperations returns the "METHOD /path" operations registered under /api/v1
+func routeOperations(t *testing.T) map[string]bool {
+	t.Helper()
+
+	s := &Server{router: chi.NewRouter()}
+	s.setupRoutes()
+
+	ops := make(map[string]bool)
+	err := chi.Walk(s.router, func(method, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		if strings.HasPrefix(route, apiPrefix+"/") {
+			path := strings.TrimSuffix(strings.TrimPrefix(route, apiPrefix), "/")
+			ops[method+" "+path] = true
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to walk routes: %v", err)
+	}
+	return ops
+}
+
+func TestOpenAPISpecCoversRoutes(t *testing.T) {
+	spec := specOperations(t)
+	routes := routeOperations(t)
+
+	if len(routes) == 0 {
+		t.Fatal("no routes registered under " + apiPrefix)
+	}
+
+	for op := range routes {
+		if !spec[op] {
+			t.Errorf("route %s is registered in setupRoutes but missing from openapi.json", op)
+		}
+	}
+	for op := range spec {
+		if !routes[op] {
+			t.Errorf("openapi.json documents %s but no such route is registered", op)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rauche/cronnor/internal/auth"
+	"github.com/rauche/cronnor/internal/config"
+	"github.com/rauche/cronnor/internal/events"
+	"github.com/rauche/cronnor/internal/jobs"
+	"github.com/rauche/cronnor/internal/metrics"
+	"github.com/rauche/cronnor/internal/models"
+	"github.com/rauche/cronnor/internal/storage"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// newTestServer creates a server backed by a fresh database
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+
+	repo, err := storage.New(filepath.Join(t.TempDir(), "cronnor.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+
+	if err := repo.RunMigrations("../../migrations"); err != nil {
+		t.Fatalf("failed to run migrations: %v", err)
+	}
+
+	templates, err := NewTemplateRenderer("../../web/templates")
+	if err != nil {
+		t.Fatalf("failed to load templates: %v", err)
+	}
+
+	m, err := metrics.New(repo, metrics.JobLabelName)
+	if err != nil {
+		t.Fatalf("failed to create metrics: %v", err)
+	}
+
+	bus := events.NewBus()
+	s := &Server{
+		router:    chi.NewRouter(),
+		cfg:       &config.Config{SessionTTL: time.Hour},
+		repo:      repo,
+		scheduler: jobs.NewScheduler(repo, bus, m, noop.NewTracerProvider()),
+		events:    bus,
+		metrics:   m,
+		templates: templates,
+	}
+	s.setupRoutes()
+	return s
+}
+
+// createTestUser creates a user with a role and returns a session cookie and
+// an admin-scoped API token acting on the user's behalf
+func createTestUser(t *testing.T, s *Server, role auth.Role) (*http.Cookie, string) {
+	t.Helper()
+
+	userID, err := s.repo.CreateUser(string(role), "unused", string(role))
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	sessionID, sessionHash, err := auth.GenerateSessionID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.repo.CreateSession(sessionHash, userID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	token, hash, prefix, err := auth.GenerateToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.repo.CreateAPIToken(models.CreateAPITokenParams{
+		UserID:    userID,
+		Name:      string(role),
+		TokenHash: hash,
+		Prefix:    prefix,
+		Scopes:    []string{auth.ScopeAdmin},
+	})
+	if err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+
+	return &http.Cookie{Name: sessionCookieName, Value: sessionID}, token
+}
+
+// routePermissions lists the permission every protected route requires.
+// TestRoutePermissionsComplete fails if a route is missing here.
+var routePermissions = []struct {
+	method string
+	path   string
+	perm   auth.Permission
+}{
+	{"GET", "/", auth.PermViewJobs},
+	{"GET", "/jobs", auth.PermViewJobs},
+	{"GET", "/jobs/list", auth.PermViewJobs},
+	{"GET", "/jobs/{id}", auth.PermViewJobs},
+	{"GET", "/trash", auth.PermViewJobs},
+	{"GET", "/events", auth.PermViewJobs},
+	{"GET", "/jobs/new", auth.PermEditJobs},
+	{"POST", "/jobs", auth.PermEditJobs},
+	{"POST", "/jobs/test", auth.PermEditJobs},
+	{"GET", "/jobs/{id}/edit", auth.PermEditJobs},
+	{"GET", "/jobs/{id}/duplicate", auth.PermEditJobs},
+	{"POST", "/jobs/{id}", auth.PermEditJobs},
+	{"POST", "/jobs/{id}/toggle", auth.PermToggleJobs},
+	{"POST", "/jobs/{id}/run", auth.PermRunJobs},
+	{"POST", "/tags/{tag}/pause", auth.PermToggleJobs},
+	{"POST", "/tags/{tag}/resume", auth.PermToggleJobs},
+	{"POST", "/tags/{tag}/run", auth.PermRunJobs},
+	{"POST", "/jobs/bulk", auth.PermToggleJobs},
+	{"POST", "/jobs/{id}/delete", auth.PermEditJobs},
+	{"DELETE", "/jobs/{id}", auth.PermEditJobs},
+	{"POST", "/jobs/{id}/revisions/{revision}/restore", auth.PermEditJobs},
+	{"POST", "/jobs/{id}/webhook", auth.PermEditJobs},
+	{"POST", "/jobs/{id}/webhook/delete", auth.PermEditJobs},
+	{"POST", "/jobs/{id}/ping-url", auth.PermEditJobs},
+	{"POST", "/jobs/{id}/alert-rules", auth.PermEditJobs},
+	{"POST", "/jobs/{id}/alert-rules/{rule}/delete", auth.PermEditJobs},
+	{"POST", "/trash/{id}/restore", auth.PermEditJobs},
+	{"POST", "/trash/{id}/purge", auth.PermEditJobs},
+	{"GET", "/tokens", auth.PermManageTokens},
+	{"POST", "/tokens", auth.PermManageTokens},
+	{"POST", "/tokens/{id}/revoke", auth.PermManageTokens},
+	{"GET", "/users", auth.PermManageUsers},
+	{"POST", "/users", auth.PermManageUsers},
+	{"POST", "/users/{id}/role", auth.PermManageUsers},
+	{"POST", "/users/{id}/delete", auth.PermManageUsers},
+	{"GET", "/notifications", auth.PermManageChannels},
+	{"POST", "/notifications", auth.PermManageChannels},
+	{"POST", "/notifications/{id}/delete", auth.PermManageChannels},
+	{"GET", "/digests", auth.PermViewJobs},
+	{"GET", "/digests/{id}/preview", auth.PermViewJobs},
+	{"POST", "/digests", auth.PermEditJobs},
+	{"POST", "/digests/{id}/delete", auth.PermEditJobs},
+	{"GET", "/audit", auth.PermViewAudit},
+	{"GET", "/audit/export", auth.PermViewAudit},
+	{"GET", "/api/docs", auth.PermViewJobs},
+	{"POST", "/logout", auth.PermViewJobs},
+
+	{"GET", "/api/v1/jobs", auth.PermViewJobs},
+	{"POST", "/api/v1/jobs", auth.PermEditJobs},
+	{"GET", "/api/v1/jobs/{id}", auth.PermViewJobs},
+	{"PUT", "/api/v1/jobs/{id}", auth.PermEditJobs},
+	{"DELETE", "/api/v1/jobs/{id}", auth.PermEditJobs},
+	{"POST", "/api/v1/jobs/{id}/toggle", auth.PermToggleJobs},
+	{"POST", "/api/v1/jobs/{id}/run", auth.PermRunJobs},
+	{"POST", "/api/v1/tags/{tag}/pause", auth.PermToggleJobs},
+	{"POST", "/api/v1/tags/{tag}/resume", auth.PermToggleJobs},
+	{"POST", "/api/v1/tags/{tag}/run", auth.PermRunJobs},
+	{"POST", "/api/v1/jobs/bulk", auth.PermToggleJobs},
+	{"GET", "/api/v1/jobs/{id}/logs", auth.PermViewJobs},
+	{"GET", "/api/v1/jobs/{id}/stats", auth.PermViewJobs},
+	{"GET", "/api/v1/jobs/{id}/revisions", auth.PermViewJobs},
+	{"POST", "/api/v1/jobs/{id}/revisions/{revision}/restore", auth.PermEditJobs},
+	{"POST", "/api/v1/jobs/{id}/webhook", auth.PermEditJobs},
+	{"DELETE", "/api/v1/jobs/{id}/webhook", auth.PermEditJobs},
+	{"POST", "/api/v1/jobs/{id}/ping-url", auth.PermEditJobs},
+	{"GET", "/api/v1/jobs/{id}/alert-rules", auth.PermViewJobs},
+	{"POST", "/api/v1/jobs/{id}/alert-rules", auth.PermEditJobs},
+	{"DELETE", "/api/v1/jobs/{id}/alert-rules/{rule}", auth.PermEditJobs},
+	{"GET", "/api/v1/jobs/{id}/alerts", auth.PermViewJobs},
+	{"GET", "/api/v1/channels", auth.PermViewJobs},
+	{"GET", "/api/v1/trash", auth.PermViewJobs},
+	{"POST", "/api/v1/trash/{id}/restore", auth.PermEditJobs},
+	{"DELETE", "/api/v1/trash/{id}", auth.PermEditJobs},
+}
+
+// publicRoutes are reachable without logging in
+var publicRoutes = map[string]bool{
+	"GET /healthz":                true,
+	"GET /readyz":                 true,
+	"GET /metrics":                true,
+	"GET /login":                  true,
+	"POST /login":                 true,
+	"GET /api/openapi.json":       true,
+	"GET /auth/oidc/login":        true,
+	"GET /auth/oidc/callback":     true,
+	"POST /hooks/{token}":         true,
+	"GET /ping/{token}":           true,
+	"POST /ping/{token}":          true,
+	"GET /ping/{token}/{signal}":  true,
+	"POST /ping/{token}/{signal}": true,
+}
+
+func TestRoutePermissionsComplete(t *testing.T) {
+	s := newTestServer(t)
+
+	known := make(map[string]bool)
+	for _, rp := range routePermissions {
+		known[rp.method+" "+rp.path] = true
+	}
+
+	chi.Walk(s.router, func(method, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		route = strings.TrimSuffix(route, "/*")
+		if route == "" {
+			route = "/"
+		}
+		op := method + " " + route
+		if strings.HasPrefix(route, "/static") || publicRoutes[op] {
+			return nil
+		}
+		if !known[op] {
+			t.Errorf("route %s has no entry in routePermissions", op)
+		}
+		return nil
+	})
+}
+
+func TestRoutePermissionsByRole(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+
+	for _, role := range auth.Roles {
+		for _, rp := range routePermissions {
+			// Each request gets a fresh server so side effects such as
+			// deleting the job or revoking the token don't leak
+			s := newTestServer(t)
+			cookie, token := createTestUser(t, s, role)
+
+			jobID, err := s.repo.CreateJob(models.CreateJobParams{
+				Name: "probe", CronExpr: "0 0 * * * *", URL: target.URL, Method: "GET", Tags: []string{"probe"},
+			})
+			if err != nil {
+				t.Fatalf("failed to create job: %v", err)
+			}
+
+			path := strings.NewReplacer("{id}", strconv.FormatInt(jobID, 10), "{revision}", "1", "{tag}", "probe").Replace(rp.path)
+			req := httptest.NewRequest(rp.method, path, nil)
+			if strings.HasPrefix(path, "/api/v1/") {
+				req.Header.Set("Authorization", "Bearer "+token)
+			} else {
+				req.AddCookie(cookie)
+				req.Header.Set(csrfHeader, csrfTokenFor(cookie.Value))
+			}
+
+			if rp.path == "/events" {
+				// Streams run until the client goes away
+				ctx, cancel := context.WithCancel(req.Context())
+				cancel()
+				req = req.WithContext(ctx)
+			}
+
+			rec := httptest.NewRecorder()
+			s.router.ServeHTTP(rec, req)
+
+			allowed := role.Can(rp.perm)
+			if allowed && (rec.Code == http.StatusForbidden || rec.Code == http.StatusUnauthorized || rec.Code >= 500) {
+				t.Errorf("%s %s: role %s should be allowed, got %d", rp.method, rp.path, role, rec.Code)
+			}
+			if !allowed && rec.Code != http.StatusForbidden {
+				t.Errorf("%s %s: role %s should be forbidden, got %d", rp.method, rp.path, role, rec.Code)
+			}
+		}
+	}
+}
+
+func TestRoutesRequireLogin(t *testing.T) {
+	s := newTestServer(t)
+
+	for _, rp := range routePermissions {
+		path := strings.NewReplacer("{id}", "1", "{revision}", "1", "{tag}", "probe").Replace(rp.path)
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, httptest.NewRequest(rp.method, path, nil))
+
+		if rec.Code != http.StatusSeeOther && rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s %s without credentials: expected redirect or 401, got %d", rp.method, rp.path, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rauche/cronnor/internal/models"
+	"github.com/rauche/cronnor/internal/storage"
+)
+
+// revisionView is a job revision with the fields it changed from the one before
+type revisionView struct {
+	models.JobRevision
+	Changes []models.FieldChange
+}
+
+// revisionHistory pairs each revision (newest first) with its changes
+func revisionHistory(revisions []models.JobRevision) []revisionView {
+	views := make([]revisionView, len(revisions))
+	for i, rev := range revisions {
+		var previous *models.Job
+		if i+1 < len(revisions) {
+			previous = revisions[i+1].Job()
+		}
+
+		// Revisions don't track whether the job is active
+		changes := []models.FieldChange{}
+		for _, c := range models.DiffJobs(previous, rev.Job()) {
+			if c.Field != "is_active" {
+				changes = append(changes, c)
+			}
+		}
+		views[i] = revisionView{JobRevision: rev, Changes: changes}
+	}
+	return views
+}
+
+// restoreRevision restores a job revision, reloads the scheduler and records
+// the change, returning the updated job
+func (s *Server) restoreRevision(r *http.Request, jobID, revision int64) (*models.Job, error) {
+	before, err := s.repo.GetJob(jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.RestoreJobRevision(jobID, revision); err != nil {
+		return nil, err
+	}
+	s.scheduler.ReloadJob(jobID)
+
+	after, err := s.repo.GetJob(jobID)
+	if err != nil {
+		return nil, err
+	}
+	s.auditJob(r, models.AuditJobRestore, before, after)
+
+	return after, nil
+}
+
+// handleRestoreRevision sets a job back to an earlier revision
+func (s *Server) handleRestoreRevision(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid job ID", http.StatusBadRequest)
+		return
+	}
+	revision, err := strconv.ParseInt(chi.URLParam(r, "revision"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid revision", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := s.restoreRevision(r, id, revision); err != nil {
+		if errors.Is(err, storage.ErrJobNotFound) || errors.Is(err, storage.ErrRevisionNotFound) {
+			http.Error(w, "Revision not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to restore revision", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/jobs/"+strconv.FormatInt(id, 10), http.StatusSeeOther)
+}
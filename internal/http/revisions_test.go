@@ -0,0 +1,120 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/rauche/cronnor/internal/auth"
+	"github.com/rauche/cronnor/internal/models"
+)
+
+func TestRestoreRevision(t *testing.T) {
+	s := newTestServer(t)
+	cookie, _ := createTestUser(t, s, auth.RoleEditor)
+
+	id, err := s.repo.CreateJob(models.CreateJobParams{
+		Name: "sync", CronExpr: "0 0 * * * *", URL: "http://example.com/v1", Method: "GET",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, url := range []string{"http://example.com/v2", "http://example.com/v2"} {
+		err := s.repo.UpdateJob(models.UpdateJobParams{
+			ID: id, Name: "sync", CronExpr: "0 0 * * * *", URL: url, Method: "GET",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	revisions := mustRevisions(t, s, id)
+	if len(revisions) != 2 {
+		t.Fatalf("saving an unchanged job should not add a revision, got %d revisions", len(revisions))
+	}
+
+	req := httptest.NewRequest("POST", "/jobs/"+strconv.FormatInt(id, 10)+"/revisions/1/restore", nil)
+	req.AddCookie(cookie)
+	req.Header.Set(csrfHeader, csrfTokenFor(cookie.Value))
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("expected redirect, got %d", rec.Code)
+	}
+
+	job, _ := s.repo.GetJob(id)
+	if job.URL != "http://example.com/v1" || job.Revision != 3 {
+		t.Errorf("expected r3 with the r1 URL, got r%d %s", job.Revision, job.URL)
+	}
+
+	history := revisionHistory(mustRevisions(t, s, id))
+	if c := history[0].Changes; len(c) != 1 || c[0].Field != "url" || c[0].After != "http://example.com/v1" {
+		t.Errorf("expected r3 to change only the URL, got %+v", c)
+	}
+}
+
+func mustRevisions(t *testing.T, s *Server, jobID int64) []models.JobRevision {
+	t.Helper()
+	revisions, err := s.repo.GetJobRevisions(jobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return revisions
+}
+
+func TestRestoreRevisionTeamAndTags(t *testing.T) {
+	s := newTestServer(t)
+	cookie, _ := createTestUser(t, s, auth.RoleEditor)
+
+	id, err := s.repo.CreateJob(models.CreateJobParams{
+		Kind: models.JobKindHeartbeat, Name: "backup", CronExpr: "0 0 * * * *",
+		Team: "data", Tags: []string{"nightly"}, Grace: 5 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Changing only the team, tags and grace period is a new revision
+	err = s.repo.UpdateJob(models.UpdateJobParams{
+		ID: id, Name: "backup", CronExpr: "0 0 * * * *",
+		Team: "ops", Tags: []string{"critical", "nightly"}, Grace: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	history := revisionHistory(mustRevisions(t, s, id))
+	if len(history) != 2 {
+		t.Fatalf("expected 2 revisions, got %d", len(history))
+	}
+	changed := map[string]bool{}
+	for _, c := range history[0].Changes {
+		changed[c.Field] = true
+	}
+	if len(changed) != 3 || !changed["team"] || !changed["tags"] || !changed["grace"] {
+		t.Errorf("expected r2 to change the team, tags and grace, got %+v", history[0].Changes)
+	}
+
+	// Bulk tagging records a revision too
+	if _, err := s.repo.BulkUpdateJobs(models.BulkJobParams{Action: models.BulkTag, IDs: []int64{id}, Tags: []string{"weekly"}}); err != nil {
+		t.Fatal(err)
+	}
+	if job, _ := s.repo.GetJob(id); job.Revision != 3 {
+		t.Errorf("expected tagging to record r3, got r%d", job.Revision)
+	}
+
+	req := httptest.NewRequest("POST", "/jobs/"+strconv.FormatInt(id, 10)+"/revisions/1/restore", nil)
+	req.AddCookie(cookie)
+	req.Header.Set(csrfHeader, csrfTokenFor(cookie.Value))
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("expected redirect, got %d", rec.Code)
+	}
+
+	job, _ := s.repo.GetJob(id)
+	if job.Team != "data" || len(job.Tags) != 1 || job.Tags[0] != "nightly" || job.Grace != 5*time.Minute || job.Kind != models.JobKindHeartbeat {
+		t.Errorf("expected r1's team, tags and grace to be restored, got %+v", job)
+	}
+}
@@ -9,20 +9,28 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/rauche/cronnor/internal/auth"
+	"github.com/rauche/cronnor/internal/config"
+	"github.com/rauche/cronnor/internal/events"
 	"github.com/rauche/cronnor/internal/jobs"
+	"github.com/rauche/cronnor/internal/metrics"
 	"github.com/rauche/cronnor/internal/storage"
 )
 
 // Server represents the HTTP server
 type Server struct {
 	router    *chi.Mux
+	cfg       *config.Config
 	repo      *storage.Repository
 	scheduler *jobs.Scheduler
+	events    *events.Bus
+	metrics   *metrics.Metrics
 	templates *TemplateRenderer
+	oidc      *auth.OIDCProvider
 }
 
 // NewServer creates a new HTTP server
-func NewServer(repo *storage.Repository, scheduler *jobs.Scheduler) (*Server, error) {
+func NewServer(cfg *config.Config, repo *storage.Repository, scheduler *jobs.Scheduler, bus *events.Bus, m *metrics.Metrics) (*Server, error) {
 	templates, err := NewTemplateRenderer("./web/templates")
 	if err != nil {
 		return nil, fmt.Errorf("failed to load templates: %w", err)
@@ -30,11 +38,20 @@ func NewServer(repo *storage.Repository, scheduler *jobs.Scheduler) (*Server, er
 
 	s := &Server{
 		router:    chi.NewRouter(),
+		cfg:       cfg,
 		repo:      repo,
 		scheduler: scheduler,
+		events:    bus,
+		metrics:   m,
 		templates: templates,
 	}
 
+	if cfg.OIDCIssuer != "" {
+		if s.oidc, err = newOIDCProvider(cfg); err != nil {
+			return nil, err
+		}
+	}
+
 	s.setupRoutes()
 	return s, nil
 }
@@ -46,26 +63,103 @@ func (s *Server) setupRoutes() {
 	// Middleware
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(30 * time.Second))
+	r.Use(s.instrument)
+	r.Use(requestTimeout(30*time.Second, "/events"))
 
 	// Static files
 	workDir, _ := filepath.Abs("./web/static")
 	filesDir := http.Dir(workDir)
 	r.Handle("/static/*", http.StripPrefix("/static/", http.FileServer(filesDir)))
 
-	// Web routes
-	r.Get("/", s.handleDashboard)
-	r.Get("/jobs", s.handleDashboard)
-	r.Get("/jobs/list", s.handleJobsList)            // API: Job list partial
-	r.Get("/jobs/new", s.handleJobForm)              // New job form
-	r.Post("/jobs", s.handleCreateJob)               // Create job
-	r.Get("/jobs/{id}/edit", s.handleJobEditForm)    // Edit form (must be before /jobs/{id})
-	r.Get("/jobs/{id}", s.handleJobDetail)           // Job details
-	r.Post("/jobs/{id}", s.handleUpdateJob)          // Update job
-	r.Post("/jobs/{id}/toggle", s.handleToggleJob)   // Toggle active
-	r.Post("/jobs/{id}/run", s.handleRunJob)         // Run now
-	r.Post("/jobs/{id}/delete", s.handleDeleteJob)   // Delete job (POST)
-	r.Delete("/jobs/{id}", s.handleDeleteJob)        // Delete job (DELETE)
+	// Public routes
+	r.Get("/healthz", s.handleHealthz)
+	r.Get("/readyz", s.handleReadyz)
+	r.Get("/metrics", s.handleMetrics) // Prometheus metrics, behind METRICS_TOKEN if set
+	r.Get("/login", s.handleLoginForm)
+	r.Post("/login", s.handleLogin)
+	r.Get("/api/openapi.json", s.handleOpenAPISpec)
+	r.Post("/hooks/{token}", s.handleWebhook) // Job trigger URLs, authenticated by the token
+	r.Get("/ping/{token}", s.handlePing)      // Heartbeat pings, authenticated by the token
+	r.Post("/ping/{token}", s.handlePing)
+	r.Get("/ping/{token}/{signal}", s.handlePing)
+	r.Post("/ping/{token}/{signal}", s.handlePing)
+	if s.oidc != nil {
+		r.Get("/auth/oidc/login", s.handleOIDCLogin)
+		r.Get("/auth/oidc/callback", s.handleOIDCCallback)
+	}
+
+	// Web routes (require a login session)
+	r.Group(func(r chi.Router) {
+		r.Use(s.requireSession)
+		r.Use(verifyCSRF)
+
+		view := r.With(requirePermission(auth.PermViewJobs))
+		view.Get("/", s.handleDashboard)
+		view.Get("/jobs", s.handleDashboard)
+		view.Get("/jobs/list", s.handleJobsList)  // API: Job list partial
+		view.Get("/jobs/{id}", s.handleJobDetail) // Job details
+		view.Get("/trash", s.handleTrash)         // Deleted jobs
+		view.Get("/events", s.handleEvents)       // Live updates (SSE)
+		view.Get("/digests", s.handleDigests)     // Digest reports
+		view.Get("/digests/{id}/preview", s.handlePreviewDigest)
+
+		edit := r.With(requirePermission(auth.PermEditJobs))
+		edit.Get("/jobs/new", s.handleJobForm)                     // New job form
+		edit.Post("/jobs", s.handleCreateJob)                      // Create job
+		edit.Post("/jobs/test", s.handleTestJob)                   // Dry-run an unsaved job
+		edit.Get("/jobs/{id}/edit", s.handleJobEditForm)           // Edit form
+		edit.Get("/jobs/{id}/duplicate", s.handleJobDuplicateForm) // Prefilled new job form
+		edit.Post("/jobs/{id}", s.handleUpdateJob)                 // Update job
+		edit.Post("/jobs/{id}/delete", s.handleDeleteJob)          // Delete job (POST)
+		edit.Delete("/jobs/{id}", s.handleDeleteJob)               // Delete job (DELETE)
+		edit.Post("/jobs/{id}/revisions/{revision}/restore", s.handleRestoreRevision)
+		edit.Post("/jobs/{id}/webhook", s.handleCreateWebhook)                     // New trigger URL
+		edit.Post("/jobs/{id}/webhook/delete", s.handleDeleteWebhook)              // Remove trigger URL
+		edit.Post("/jobs/{id}/ping-url", s.handleCreatePingURL)                    // New heartbeat ping URL
+		edit.Post("/jobs/{id}/alert-rules", s.handleCreateAlertRule)               // Add alert rule
+		edit.Post("/jobs/{id}/alert-rules/{rule}/delete", s.handleDeleteAlertRule) // Remove alert rule
+		edit.Post("/digests", s.handleCreateDigest)                                // New digest
+		edit.Post("/digests/{id}/delete", s.handleDeleteDigest)                    // Remove digest
+		edit.Post("/trash/{id}/restore", s.handleRestoreJob)                       // Take out of trash
+		edit.Post("/trash/{id}/purge", s.handlePurgeJob)                           // Delete permanently
+
+		r.With(requirePermission(auth.PermToggleJobs)).Post("/jobs/{id}/toggle", s.handleToggleJob)  // Toggle active
+		r.With(requirePermission(auth.PermRunJobs)).Post("/jobs/{id}/run", s.handleRunJob)           // Run now
+		r.With(requirePermission(auth.PermToggleJobs)).Post("/tags/{tag}/pause", s.handlePauseTag)   // Pause all with tag
+		r.With(requirePermission(auth.PermToggleJobs)).Post("/tags/{tag}/resume", s.handleResumeTag) // Resume all with tag
+		r.With(requirePermission(auth.PermRunJobs)).Post("/tags/{tag}/run", s.handleRunTag)          // Run all with tag
+		r.With(requirePermission(auth.PermToggleJobs)).Post("/jobs/bulk", s.handleBulkJobs)          // Bulk actions, each checks its own permission
+
+		// API tokens
+		tokens := r.With(requirePermission(auth.PermManageTokens))
+		tokens.Get("/tokens", s.handleTokens)
+		tokens.Post("/tokens", s.handleCreateToken)
+		tokens.Post("/tokens/{id}/revoke", s.handleRevokeToken)
+
+		// User management
+		users := r.With(requirePermission(auth.PermManageUsers))
+		users.Get("/users", s.handleUsers)
+		users.Post("/users", s.handleCreateUser)
+		users.Post("/users/{id}/role", s.handleUpdateUserRole)
+		users.Post("/users/{id}/delete", s.handleDeleteUser)
+
+		// Notification channels
+		channels := r.With(requirePermission(auth.PermManageChannels))
+		channels.Get("/notifications", s.handleChannels)
+		channels.Post("/notifications", s.handleCreateChannel)
+		channels.Post("/notifications/{id}/delete", s.handleDeleteChannel)
+
+		// Audit log
+		audit := r.With(requirePermission(auth.PermViewAudit))
+		audit.Get("/audit", s.handleAudit)
+		audit.Get("/audit/export", s.handleAuditExport)
+
+		r.Get("/api/docs", s.handleAPIDocs)
+		r.Post("/logout", s.handleLogout)
+	})
+
+	// JSON API (requires an API token)
+	r.Route("/api/v1", s.setupAPIRoutes)
 }
 
 // Start starts the HTTP server
@@ -0,0 +1,224 @@
+package http
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rauche/cronnor/internal/auth"
+	"github.com/rauche/cronnor/internal/storage"
+)
+
+// sessionCookieName is the name of the login session cookie
+const sessionCookieName = "cronnor_session"
+
+// loginCookieName holds the pre-session ID that the login form's CSRF token
+// is bound to, so another site can't log a browser into its own account
+const loginCookieName = "cronnor_login"
+
+// loginFormTTL is how long a loaded login form can be submitted
+const loginFormTTL = time.Hour
+
+// render renders a template, adding the values every page's layout needs
+func (s *Server) render(w http.ResponseWriter, r *http.Request, name string, data map[string]interface{}) {
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	data["CurrentUser"] = auth.UserFromContext(r.Context())
+	data["CSRFToken"] = csrfTokenFromContext(r.Context())
+
+	if err := s.templates.Render(w, name, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// requireSession rejects requests without a valid login session, sending
+// browsers to the login page
+func (s *Server) requireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(sessionCookieName)
+		if err == nil && cookie.Value != "" {
+			user, err := s.repo.GetSessionUser(auth.HashToken(cookie.Value))
+			if err == nil {
+				ctx := auth.WithUser(r.Context(), user)
+				ctx = withCSRFToken(ctx, csrfTokenFor(cookie.Value))
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+			if !errors.Is(err, storage.ErrSessionNotFound) {
+				http.Error(w, "Failed to load session", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		loginURL := "/login"
+		if r.Method == http.MethodGet && r.Header.Get("HX-Request") == "" {
+			loginURL += "?next=" + url.QueryEscape(r.URL.RequestURI())
+		}
+
+		// HTMX follows HX-Redirect with a full page load instead of swapping
+		// the login page into the target element
+		if r.Header.Get("HX-Request") != "" {
+			w.Header().Set("HX-Redirect", loginURL)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		http.Redirect(w, r, loginURL, http.StatusSeeOther)
+	})
+}
+
+// safeRedirect returns next if it is a local path, or the dashboard otherwise
+func safeRedirect(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/jobs"
+	}
+	return next
+}
+
+// handleLoginForm shows the login page
+func (s *Server) handleLoginForm(w http.ResponseWriter, r *http.Request) {
+	s.renderLogin(w, r, http.StatusOK, map[string]interface{}{
+		"Next": r.URL.Query().Get("next"),
+	})
+}
+
+// renderLogin renders the login page with status, binding its CSRF token to
+// the browser's pre-session login cookie and issuing one if there is none
+func (s *Server) renderLogin(w http.ResponseWriter, r *http.Request, status int, data map[string]interface{}) {
+	var id string
+	if cookie, err := r.Cookie(loginCookieName); err == nil && cookie.Value != "" {
+		id = cookie.Value
+	} else if id, err = auth.GenerateLoginID(); err != nil {
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     loginCookieName,
+		Value:    id,
+		Path:     "/login",
+		MaxAge:   int(loginFormTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   s.cfg.CookieSecure,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	data["OIDCEnabled"] = s.oidc != nil
+	w.WriteHeader(status)
+	s.render(w, r.WithContext(withCSRFToken(r.Context(), csrfTokenFor(id))), "login.html", data)
+}
+
+// validLoginCSRF reports whether a login form post carries the CSRF token
+// bound to the browser's login cookie
+func validLoginCSRF(r *http.Request) bool {
+	cookie, err := r.Cookie(loginCookieName)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(csrfTokenFor(cookie.Value)), []byte(r.PostFormValue(csrfField))) == 1
+}
+
+// handleLogin checks credentials and starts a session
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+	if !validLoginCSRF(r) {
+		http.Error(w, "Invalid or missing CSRF token, please reload the page", http.StatusForbidden)
+		return
+	}
+
+	username := strings.TrimSpace(r.FormValue("username"))
+	password := r.FormValue("password")
+	next := r.FormValue("next")
+
+	user, err := s.repo.GetUserByUsername(username)
+	if err != nil && !errors.Is(err, storage.ErrUserNotFound) {
+		http.Error(w, "Failed to load user", http.StatusInternalServerError)
+		return
+	}
+
+	if user == nil {
+		auth.CheckMissingUser(password)
+	}
+	if user == nil || !auth.CheckPassword(user.PasswordHash, password) {
+		s.renderLogin(w, r, http.StatusUnauthorized, map[string]interface{}{
+			"Next":     next,
+			"Username": username,
+			"Error":    "Invalid username or password",
+		})
+		return
+	}
+
+	if err := s.startSession(w, user.ID); err != nil {
+		http.Error(w, "Failed to start session", http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     loginCookieName,
+		Value:    "",
+		Path:     "/login",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   s.cfg.CookieSecure,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, safeRedirect(next), http.StatusSeeOther)
+}
+
+// startSession creates a session for a user and sets the session cookie
+func (s *Server) startSession(w http.ResponseWriter, userID int64) error {
+	if err := s.repo.DeleteExpiredSessions(); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	id, hash, err := auth.GenerateSessionID()
+	if err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().Add(s.cfg.SessionTTL)
+	if err := s.repo.CreateSession(hash, userID, expiresAt); err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    id,
+		Path:     "/",
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   s.cfg.CookieSecure,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return nil
+}
+
+// handleLogout ends the current session
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(sessionCookieName); err == nil {
+		if err := s.repo.DeleteSession(auth.HashToken(cookie.Value)); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   s.cfg.CookieSecure,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
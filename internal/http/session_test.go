@@ -0,0 +1,140 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rauche/cronnor/internal/auth"
+)
+
+func TestLoginSessions(t *testing.T) {
+	s := newTestServer(t)
+
+	hash, err := auth.HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.repo.CreateUser("alice", hash, string(auth.RoleEditor)); err != nil {
+		t.Fatal(err)
+	}
+
+	loginCookie := loadLoginForm(t, s)
+	login := func(username, password, next string) *httptest.ResponseRecorder {
+		form := url.Values{"username": {username}, "password": {password}, "next": {next}, csrfField: {csrfTokenFor(loginCookie.Value)}}
+		req := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(loginCookie)
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		return rec
+	}
+	get := func(path string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		return rec
+	}
+	sessionCookie := func(rec *httptest.ResponseRecorder) *http.Cookie {
+		for _, c := range rec.Result().Cookies() {
+			if c.Name == sessionCookieName {
+				return c
+			}
+		}
+		return nil
+	}
+
+	// Wrong credentials are rejected alike, without a session
+	for name, creds := range map[string][2]string{
+		"wrong password": {"alice", "battery staple"},
+		"unknown user":   {"bob", "correct horse"},
+	} {
+		rec := login(creds[0], creds[1], "")
+		if rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), "Invalid username or password") {
+			t.Errorf("%s: expected 401, got %d", name, rec.Code)
+		}
+		if sessionCookie(rec) != nil {
+			t.Errorf("%s: expected no session cookie", name)
+		}
+	}
+
+	// Pages need a session
+	if rec := get("/jobs", nil); rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/login?next=%2Fjobs" {
+		t.Errorf("without a session: expected a redirect to the login page, got %d %s", rec.Code, rec.Header().Get("Location"))
+	}
+
+	// Logging in sets a locked-down session cookie and follows next
+	rec := login("alice", "correct horse", "/trash")
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/trash" {
+		t.Fatalf("login: expected a redirect to /trash, got %d %s", rec.Code, rec.Header().Get("Location"))
+	}
+	cookie := sessionCookie(rec)
+	if cookie == nil {
+		t.Fatal("login: expected a session cookie")
+	}
+	if !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode || cookie.Path != "/" || cookie.Secure {
+		t.Errorf("unexpected session cookie flags: %+v", cookie)
+	}
+	if d := time.Until(cookie.Expires); d < 59*time.Minute || d > time.Hour {
+		t.Errorf("expected the session to last SESSION_TTL, got %s", d)
+	}
+	if user, err := s.repo.GetSessionUser(auth.HashToken(cookie.Value)); err != nil || user.Username != "alice" {
+		t.Errorf("expected the session to be stored by its hash, got %v (%v)", user, err)
+	}
+	if rec := get("/jobs", cookie); rec.Code != http.StatusOK {
+		t.Errorf("with a session: expected 200, got %d", rec.Code)
+	}
+
+	// next can only point within the site
+	if rec := login("alice", "correct horse", "//evil.example"); rec.Header().Get("Location") != "/jobs" {
+		t.Errorf("expected an off-site next to go to the dashboard, got %s", rec.Header().Get("Location"))
+	}
+
+	// Secure cookies when served over HTTPS
+	s.cfg.CookieSecure = true
+	if c := sessionCookie(login("alice", "correct horse", "")); c == nil || !c.Secure {
+		t.Errorf("expected a secure session cookie with COOKIE_SECURE, got %+v", c)
+	}
+	s.cfg.CookieSecure = false
+
+	// Logging out ends the session for good
+	req := httptest.NewRequest("POST", "/logout", nil)
+	req.Header.Set(csrfHeader, csrfTokenFor(cookie.Value))
+	req.AddCookie(cookie)
+	rec = httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/login" {
+		t.Errorf("logout: expected a redirect to /login, got %d", rec.Code)
+	}
+	if c := sessionCookie(rec); c == nil || c.MaxAge >= 0 {
+		t.Errorf("logout: expected the session cookie to be cleared, got %+v", c)
+	}
+	if rec := get("/jobs", cookie); rec.Code != http.StatusSeeOther {
+		t.Errorf("after logout: expected the old session to be rejected, got %d", rec.Code)
+	}
+
+	// Expired sessions are rejected; HTMX requests get a full-page redirect
+	user, _ := s.repo.GetUserByUsername("alice")
+	id, idHash, _ := auth.GenerateSessionID()
+	if err := s.repo.CreateSession(idHash, user.ID, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	expired := &http.Cookie{Name: sessionCookieName, Value: id}
+	if rec := get("/jobs", expired); rec.Code != http.StatusSeeOther {
+		t.Errorf("expired session: expected a redirect, got %d", rec.Code)
+	}
+	req = httptest.NewRequest("GET", "/jobs/list", nil)
+	req.Header.Set("HX-Request", "true")
+	req.AddCookie(expired)
+	rec = httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("HX-Redirect") != "/login" {
+		t.Errorf("expired HTMX session: expected 401 with HX-Redirect, got %d %s", rec.Code, rec.Header().Get("HX-Redirect"))
+	}
+}
@@ -0,0 +1,86 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rauche/cronnor/internal/models"
+)
+
+// tagParam returns the tag URL parameter
+func tagParam(r *http.Request) string {
+	return strings.ToLower(chi.URLParam(r, "tag"))
+}
+
+// setTagActive pauses or resumes every job carrying tag and returns the jobs
+// that changed, as they are now
+func (s *Server) setTagActive(r *http.Request, tag string, active bool) ([]models.Job, error) {
+	changed, err := s.repo.SetTagActive(tag, active)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range changed {
+		before := changed[i]
+		changed[i].IsActive = active
+		s.scheduler.ReloadJob(before.ID)
+		s.auditJob(r, models.AuditJobToggle, &before, &changed[i])
+	}
+
+	return changed, nil
+}
+
+// runTag starts an immediate execution of every job carrying tag
+func (s *Server) runTag(r *http.Request, tag string) ([]models.Job, error) {
+	jobs, err := s.repo.GetAllJobs(models.JobFilter{Tag: tag})
+	if err != nil {
+		return nil, err
+	}
+
+	started := make([]models.Job, 0, len(jobs))
+	for _, job := range jobs {
+		if err := s.scheduler.ExecuteNow(job.ID, manualTrigger(r)); err != nil {
+			continue
+		}
+		s.auditJob(r, models.AuditJobRun, &job, &job)
+		started = append(started, job)
+	}
+
+	return started, nil
+}
+
+// handlePauseTag disables every job carrying a tag
+func (s *Server) handlePauseTag(w http.ResponseWriter, r *http.Request) {
+	if _, err := s.setTagActive(r, tagParam(r), false); err != nil {
+		http.Error(w, "Failed to pause jobs", http.StatusInternalServerError)
+		return
+	}
+
+	// Return updated job list for HTMX
+	s.handleJobsList(w, r)
+}
+
+// handleResumeTag enables every job carrying a tag
+func (s *Server) handleResumeTag(w http.ResponseWriter, r *http.Request) {
+	if _, err := s.setTagActive(r, tagParam(r), true); err != nil {
+		http.Error(w, "Failed to resume jobs", http.StatusInternalServerError)
+		return
+	}
+
+	// Return updated job list for HTMX
+	s.handleJobsList(w, r)
+}
+
+// handleRunTag executes every job carrying a tag immediately
+func (s *Server) handleRunTag(w http.ResponseWriter, r *http.Request) {
+	started, err := s.runTag(r, tagParam(r))
+	if err != nil {
+		http.Error(w, "Failed to execute jobs", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Started " + strconv.Itoa(len(started)) + " job executions"))
+}
@@ -0,0 +1,84 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rauche/cronnor/internal/auth"
+	"github.com/rauche/cronnor/internal/models"
+)
+
+func TestFilterAndPauseJobsByTag(t *testing.T) {
+	s := newTestServer(t)
+	_, token := createTestUser(t, s, auth.RoleEditor)
+
+	api := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, body := range []string{
+		`{"name":"invoices","cron_expr":"0 0 * * * *","url":"http://example.com/a","team":"billing","tags":["Nightly","billing","nightly"]}`,
+		`{"name":"reports","cron_expr":"0 0 * * * *","url":"http://example.com/b","team":"analytics","tags":["nightly"]}`,
+		`{"name":"ping","cron_expr":"0 0 * * * *","url":"http://example.com/c"}`,
+	} {
+		if rec := api("POST", "/api/v1/jobs", body); rec.Code != http.StatusCreated {
+			t.Fatalf("create: expected 201, got %d: %s", rec.Code, rec.Body)
+		}
+	}
+
+	if rec := api("POST", "/api/v1/jobs", `{"name":"bad","cron_expr":"0 0 * * * *","url":"http://example.com","tags":["no spaces"]}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("invalid tag: expected 422, got %d", rec.Code)
+	}
+
+	list := func(query string) []apiJob {
+		t.Helper()
+		rec := api("GET", "/api/v1/jobs"+query, "")
+		var jobs []apiJob
+		if err := json.Unmarshal(rec.Body.Bytes(), &jobs); err != nil {
+			t.Fatalf("list %s: %v", query, err)
+		}
+		return jobs
+	}
+
+	if jobs := list("?tag=nightly"); len(jobs) != 2 {
+		t.Errorf("expected 2 nightly jobs, got %d", len(jobs))
+	}
+	if jobs := list("?tag=nightly&team=billing"); len(jobs) != 1 || strings.Join(jobs[0].Tags, ",") != "billing,nightly" {
+		t.Errorf("expected the billing job with normalized tags, got %+v", jobs)
+	}
+
+	rec := api("POST", "/api/v1/tags/nightly/pause", "")
+	var paused []apiJob
+	json.Unmarshal(rec.Body.Bytes(), &paused)
+	if rec.Code != http.StatusOK || len(paused) != 2 {
+		t.Fatalf("pause: expected 2 jobs, got %d: %s", rec.Code, rec.Body)
+	}
+
+	for _, job := range list("") {
+		if job.IsActive == (job.Name == "invoices" || job.Name == "reports") {
+			t.Errorf("job %s: unexpected is_active %v after pausing nightly", job.Name, job.IsActive)
+		}
+	}
+
+	// Pausing again changes nothing
+	if rec := api("POST", "/api/v1/tags/nightly/pause", ""); strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Errorf("second pause: expected no changes, got %s", rec.Body)
+	}
+
+	entries, err := s.repo.GetAuditEntries(models.AuditFilter{Action: models.AuditJobToggle})
+	if err != nil || len(entries) != 2 {
+		t.Errorf("expected 2 toggle audit entries, got %d (%v)", len(entries), err)
+	}
+
+	api("POST", "/api/v1/tags/nightly/resume", "")
+	if jobs := list("?tag=nightly"); !jobs[0].IsActive || !jobs[1].IsActive {
+		t.Error("resume should enable every nightly job")
+	}
+}
@@ -1,13 +1,17 @@
 package http
 
 import (
+	"encoding/json"
 	"fmt"
 	"html/template"
 	"net/http"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/rauche/cronnor/internal/auth"
+	"github.com/rauche/cronnor/internal/models"
 	"github.com/robfig/cron/v3"
 )
 
@@ -22,6 +26,11 @@ func NewTemplateRenderer(templatesDir string) (*TemplateRenderer, error) {
 		"formatTime":  formatTime,
 		"statusClass": statusClass,
 		"nextRun":     nextRun,
+		"sparkline":   sparkline,
+		"percent":     percent,
+		"can":         can,
+		"auditValue":  auditValue,
+		"join":        strings.Join,
 		"eq":          func(a, b string) bool { return a == b },
 	}
 
@@ -115,7 +124,7 @@ func statusClass(status string) string {
 	switch status {
 	case "SUCCESS":
 		return "status-success"
-	case "FAILED":
+	case "FAILED", "DOWN":
 		return "status-failed"
 	case "ERROR":
 		return "status-error"
@@ -134,3 +143,56 @@ func nextRun(cronExpr string) string {
 	next := schedule.Next(time.Now())
 	return formatTime(next)
 }
+
+// can reports whether the user's role has a permission, for hiding actions
+func can(user *models.User, perm string) bool {
+	return user != nil && auth.Role(user.Role).Can(auth.Permission(perm))
+}
+
+// auditValue formats a value from an audit log change for display
+func auditValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "—"
+	case string:
+		return v
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+func percent(v float64) string {
+	return strconv.FormatFloat(v, 'f', 1, 64) + "%"
+}
+
+// sparkline renders durations as a small inline SVG line chart
+func sparkline(values []int64) template.HTML {
+	const width, height = 300, 40
+	if len(values) < 2 {
+		return ""
+	}
+
+	var max int64 = 1
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+
+	var points strings.Builder
+	step := float64(width) / float64(len(values)-1)
+	for i, v := range values {
+		x := float64(i) * step
+		y := height - float64(v)/float64(max)*(height-2) - 1
+		fmt.Fprintf(&points, "%.1f,%.1f ", x, y)
+	}
+
+	return template.HTML(fmt.Sprintf(
+		`<svg viewBox="0 0 %d %d" width="100%%" height="%d" preserveAspectRatio="none" role="img" aria-label="Recent durations">`+
+			`<polyline fill="none" stroke="currentColor" stroke-width="1.5" points="%s"/></svg>`,
+		width, height, height, strings.TrimSpace(points.String()),
+	))
+}
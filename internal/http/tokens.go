@@ -0,0 +1,161 @@
+package http
+
+import (
+	"database/sql"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rauche/cronnor/internal/auth"
+	"github.com/rauche/cronnor/internal/models"
+)
+
+// tokenExpiryOptions lists the expiry choices offered on the tokens page, in days
+var tokenExpiryOptions = []int{7, 30, 90, 365}
+
+// renderTokens renders the tokens page, optionally with a newly created token
+func (s *Server) renderTokens(w http.ResponseWriter, r *http.Request, newToken, formError string) {
+	user := auth.UserFromContext(r.Context())
+	role := auth.Role(user.Role)
+
+	var tokens []models.APIToken
+	var err error
+	if role == auth.RoleAdmin {
+		tokens, err = s.repo.GetAPITokens()
+	} else {
+		tokens, err = s.repo.GetUserAPITokens(user.ID)
+	}
+	if err != nil {
+		http.Error(w, "Failed to load tokens", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]interface{}{
+		"Tokens":        tokens,
+		"Scopes":        auth.RoleScopes(role),
+		"ExpiryOptions": tokenExpiryOptions,
+		"NewToken":      newToken,
+		"Error":         formError,
+	}
+
+	s.render(w, r, "tokens.html", data)
+}
+
+// handleTokens shows the API tokens page
+func (s *Server) handleTokens(w http.ResponseWriter, r *http.Request) {
+	s.renderTokens(w, r, "", "")
+}
+
+// handleCreateToken creates an API token and shows its plaintext once
+func (s *Server) handleCreateToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		s.renderTokens(w, r, "", "Token name is required")
+		return
+	}
+
+	scopes := r.Form["scopes"]
+	if len(scopes) == 0 {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		s.renderTokens(w, r, "", "Select at least one scope")
+		return
+	}
+	user := auth.UserFromContext(r.Context())
+	allowed := auth.RoleScopes(auth.Role(user.Role))
+	for _, scope := range scopes {
+		if !slices.Contains(allowed, scope) {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			s.renderTokens(w, r, "", "Scope not available for your role: "+scope)
+			return
+		}
+	}
+
+	expiresAt := sql.NullTime{}
+	if days, err := strconv.Atoi(r.FormValue("expires_in")); err == nil && days > 0 {
+		expiresAt = sql.NullTime{Time: time.Now().UTC().AddDate(0, 0, days), Valid: true}
+	}
+
+	plaintext, hash, prefix, err := auth.GenerateToken()
+	if err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	id, err := s.repo.CreateAPIToken(models.CreateAPITokenParams{
+		UserID:    user.ID,
+		Name:      name,
+		TokenHash: hash,
+		Prefix:    prefix,
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		http.Error(w, "Failed to create token", http.StatusInternalServerError)
+		return
+	}
+
+	changes := []models.FieldChange{
+		{Field: "prefix", After: prefix},
+		{Field: "scopes", After: scopes},
+	}
+	if expiresAt.Valid {
+		changes = append(changes, models.FieldChange{Field: "expires_at", After: expiresAt.Time})
+	}
+	s.audit(r, models.AuditEntry{
+		Action:     models.AuditTokenCreate,
+		TargetType: "token",
+		TargetID:   id,
+		TargetName: name,
+		Changes:    changes,
+	})
+
+	s.renderTokens(w, r, plaintext, "")
+}
+
+// handleRevokeToken deletes an API token
+func (s *Server) handleRevokeToken(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid token ID", http.StatusBadRequest)
+		return
+	}
+
+	token, err := s.repo.GetAPIToken(id)
+	if err != nil {
+		http.Error(w, "Token not found", http.StatusNotFound)
+		return
+	}
+
+	user := auth.UserFromContext(r.Context())
+	if token.UserID != user.ID && auth.Role(user.Role) != auth.RoleAdmin {
+		http.Error(w, "You don't have permission to do that", http.StatusForbidden)
+		return
+	}
+
+	if err := s.repo.DeleteAPIToken(id); err != nil {
+		http.Error(w, "Failed to revoke token", http.StatusInternalServerError)
+		return
+	}
+
+	s.audit(r, models.AuditEntry{
+		Action:     models.AuditTokenRevoke,
+		TargetType: "token",
+		TargetID:   token.ID,
+		TargetName: token.Name,
+		Changes: []models.FieldChange{
+			{Field: "prefix", Before: token.Prefix},
+			{Field: "scopes", Before: token.Scopes},
+		},
+	})
+
+	http.Redirect(w, r, "/tokens", http.StatusSeeOther)
+}
@@ -0,0 +1,152 @@
+package http
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rauche/cronnor/internal/auth"
+	"github.com/rauche/cronnor/internal/models"
+)
+
+func TestAPITokens(t *testing.T) {
+	s := newTestServer(t)
+	cookie, adminToken := createTestUser(t, s, auth.RoleAdmin)
+
+	api := func(token, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		return rec
+	}
+	web := func(path string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set(csrfHeader, csrfTokenFor(cookie.Value))
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		return rec
+	}
+	// issue creates a token through the tokens page and returns its plaintext
+	issue := func(name string, scopes ...string) string {
+		t.Helper()
+		rec := web("/tokens", url.Values{"name": {name}, "scopes": scopes})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("create token: expected 200, got %d: %s", rec.Code, rec.Body)
+		}
+		plaintext := regexp.MustCompile(auth.TokenPrefix + `[0-9a-f]{64}`).FindString(rec.Body.String())
+		if plaintext == "" {
+			t.Fatal("expected the new token to be shown once")
+		}
+		return plaintext
+	}
+
+	api(adminToken, "POST", "/api/v1/jobs", `{"name":"sync","cron_expr":"0 0 * * * *","url":"http://example.com"}`)
+
+	readOnly := issue("dashboard", auth.ScopeJobsRead)
+	runner := issue("deploys", auth.ScopeJobsRun)
+
+	// Only the hash is stored, and lookups go through it
+	stored, err := s.repo.GetAPITokenByHash(auth.HashToken(readOnly))
+	if err != nil {
+		t.Fatalf("expected the token to be found by its hash: %v", err)
+	}
+	if stored.TokenHash == readOnly || !strings.HasPrefix(readOnly, stored.Prefix) {
+		t.Errorf("expected a hash and a display prefix to be stored, got %+v", stored)
+	}
+	if stored.LastUsedAt.Valid {
+		t.Error("expected an unused token to have no last use")
+	}
+
+	// Scopes are enforced per route
+	for _, tt := range []struct {
+		token  string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{readOnly, "GET", "/api/v1/jobs", "", http.StatusOK},
+		{readOnly, "GET", "/api/v1/jobs/1/logs", "", http.StatusOK},
+		{readOnly, "POST", "/api/v1/jobs", `{"name":"x","cron_expr":"0 0 * * * *","url":"http://example.com"}`, http.StatusForbidden},
+		{readOnly, "PUT", "/api/v1/jobs/1", `{"name":"x","cron_expr":"0 0 * * * *","url":"http://example.com"}`, http.StatusForbidden},
+		{readOnly, "DELETE", "/api/v1/jobs/1", "", http.StatusForbidden},
+		{readOnly, "POST", "/api/v1/jobs/1/toggle", "", http.StatusForbidden},
+		{readOnly, "POST", "/api/v1/jobs/1/run", "", http.StatusForbidden},
+		{runner, "GET", "/api/v1/jobs", "", http.StatusForbidden},
+		{runner, "POST", "/api/v1/jobs/1/toggle", "", http.StatusForbidden},
+		{runner, "POST", "/api/v1/jobs/1/run", "", http.StatusAccepted},
+	} {
+		if rec := api(tt.token, tt.method, tt.path, tt.body); rec.Code != tt.want {
+			t.Errorf("%s %s: expected %d, got %d: %s", tt.method, tt.path, tt.want, rec.Code, rec.Body)
+		}
+	}
+	if rec := api(readOnly, "DELETE", "/api/v1/jobs/1", ""); !strings.Contains(rec.Body.String(), "token lacks required scope: "+auth.ScopeJobsWrite) {
+		t.Errorf("expected the missing scope to be named, got %s", rec.Body)
+	}
+
+	stored, _ = s.repo.GetAPITokenByHash(auth.HashToken(readOnly))
+	if !stored.LastUsedAt.Valid || time.Since(stored.LastUsedAt.Time) > time.Minute {
+		t.Errorf("expected using the token to record its last use, got %v", stored.LastUsedAt)
+	}
+
+	// Scopes cannot exceed the owner's role
+	viewerCookie, _ := createTestUser(t, s, auth.RoleViewer)
+	req := httptest.NewRequest("POST", "/tokens", strings.NewReader(url.Values{"name": {"x"}, "scopes": {auth.ScopeJobsWrite}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set(csrfHeader, csrfTokenFor(viewerCookie.Value))
+	req.AddCookie(viewerCookie)
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("viewer write token: expected 422, got %d", rec.Code)
+	}
+
+	// Expired tokens are rejected
+	expired, hash, prefix, err := auth.GenerateToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.repo.CreateAPIToken(models.CreateAPITokenParams{
+		UserID:    stored.UserID,
+		Name:      "old",
+		TokenHash: hash,
+		Prefix:    prefix,
+		Scopes:    []string{auth.ScopeAdmin},
+		ExpiresAt: sql.NullTime{Time: time.Now().Add(-time.Minute), Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec := api(expired, "GET", "/api/v1/jobs", ""); rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), "token expired") {
+		t.Errorf("expired token: expected 401 token expired, got %d: %s", rec.Code, rec.Body)
+	}
+
+	// Revoked tokens stop working at once
+	if rec := web("/tokens/"+strconv.FormatInt(stored.ID, 10)+"/revoke", nil); rec.Code != http.StatusSeeOther {
+		t.Fatalf("revoke: expected 303, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := api(readOnly, "GET", "/api/v1/jobs", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("revoked token: expected 401, got %d", rec.Code)
+	}
+
+	for name, token := range map[string]string{
+		"missing": "",
+		"unknown": auth.TokenPrefix + strings.Repeat("0", 64),
+	} {
+		rec := api(token, "GET", "/api/v1/jobs", "")
+		if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s token: expected 401 with a challenge, got %d", name, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rauche/cronnor/internal/auth"
+	"github.com/rauche/cronnor/internal/jobs"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracing(t *testing.T) {
+	s := newTestServer(t)
+	_, token := createTestUser(t, s, auth.RoleAdmin)
+
+	var mu sync.Mutex
+	var traceparents []string
+	traceparent := func(i int) string {
+		mu.Lock()
+		defer mu.Unlock()
+		return traceparents[i]
+	}
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		traceparents = append(traceparents, r.Header.Get("traceparent"))
+		mu.Unlock()
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer target.Close()
+
+	api := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		return rec
+	}
+	// run starts a job and returns its latest execution once it is logged
+	run := func(id string) apiJobLog {
+		t.Helper()
+		if rec := api("POST", "/api/v1/jobs/"+id+"/run", ""); rec.Code != http.StatusAccepted {
+			t.Fatalf("run: expected 202, got %d: %s", rec.Code, rec.Body)
+		}
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			var logs []apiJobLog
+			json.Unmarshal(api("GET", "/api/v1/jobs/"+id+"/logs", "").Body.Bytes(), &logs)
+			if len(logs) > 0 {
+				return logs[0]
+			}
+		}
+		t.Fatal("the run was not logged")
+		return apiJobLog{}
+	}
+
+	for _, job := range []string{
+		`{"name":"untraced","cron_expr":"0 0 * * * *","url":"` + target.URL + `/ok"}`,
+		`{"name":"sync","cron_expr":"0 0 * * * *","url":"` + target.URL + `/ok?key=secret"}`,
+		`{"name":"report","cron_expr":"0 0 * * * *","url":"` + target.URL + `/fail"}`,
+	} {
+		if rec := api("POST", "/api/v1/jobs", job); rec.Code != http.StatusCreated {
+			t.Fatalf("create job: expected 201, got %d: %s", rec.Code, rec.Body)
+		}
+	}
+
+	// Tracing is off by default
+	if entry := run("1"); entry.TraceID != nil {
+		t.Errorf("expected no trace ID without tracing, got %s", *entry.TraceID)
+	}
+	if got := traceparent(0); got != "" {
+		t.Errorf("expected no traceparent without tracing, got %s", got)
+	}
+
+	// Spans are recorded as soon as they end
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	s.scheduler = jobs.NewScheduler(s.repo, s.events, s.metrics, tp)
+	// finished waits for the run's root span, which ends after it is logged
+	finished := func() tracetest.SpanStubs {
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			if spans := exporter.GetSpans(); len(spans) == 3 {
+				return spans
+			}
+		}
+		return exporter.GetSpans()
+	}
+
+	entry := run("2")
+	spans := finished()
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %d", len(spans))
+	}
+	byName := make(map[string]tracetest.SpanStub)
+	for _, span := range spans {
+		byName[span.Name] = span
+	}
+	root, execute, send := byName["run"], byName["execute"], byName["HTTP GET"]
+
+	traceID := root.SpanContext.TraceID()
+	if !traceID.IsValid() || execute.SpanContext.TraceID() != traceID || send.SpanContext.TraceID() != traceID {
+		t.Fatal("expected every span to share one trace")
+	}
+	if root.Parent.IsValid() || execute.Parent.SpanID() != root.SpanContext.SpanID() || send.Parent.SpanID() != execute.SpanContext.SpanID() {
+		t.Error("expected the spans to nest run > execute > HTTP GET")
+	}
+	for _, attr := range send.Attributes {
+		if strings.Contains(attr.Value.Emit(), "secret") {
+			t.Errorf("expected the query to stay out of the span, got %s=%s", attr.Key, attr.Value.Emit())
+		}
+	}
+
+	want := "00-" + traceID.String() + "-" + send.SpanContext.SpanID().String() + "-01"
+	if got := traceparent(1); got != want {
+		t.Errorf("expected traceparent %s, got %s", want, got)
+	}
+	if entry.TraceID == nil || *entry.TraceID != traceID.String() {
+		t.Errorf("expected the execution to record trace %s, got %v", traceID, entry.TraceID)
+	}
+
+	// Failed requests mark the execution and request spans as failed
+	exporter.Reset()
+	run("3")
+	for _, span := range finished() {
+		if span.Name != "run" && span.Status.Code != codes.Error {
+			t.Errorf("expected span %s to be failed, got %s", span.Name, span.Status.Code)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rauche/cronnor/internal/models"
+	"github.com/rauche/cronnor/internal/storage"
+)
+
+// trashedJob is a job in the trash with the time it will be purged
+type trashedJob struct {
+	models.Job
+	PurgeAt time.Time
+}
+
+// trashedJobs returns the jobs in the trash with their purge times, which are
+// zero when the trash is kept forever
+func (s *Server) trashedJobs() ([]trashedJob, error) {
+	jobs, err := s.repo.GetTrashedJobs()
+	if err != nil {
+		return nil, err
+	}
+
+	trashed := make([]trashedJob, len(jobs))
+	for i, job := range jobs {
+		trashed[i] = trashedJob{Job: job}
+		if s.cfg.TrashRetention > 0 {
+			trashed[i].PurgeAt = job.DeletedAt.Time.Add(s.cfg.TrashRetention)
+		}
+	}
+	return trashed, nil
+}
+
+// restoreJob takes a job out of the trash, schedules it again and records the change
+func (s *Server) restoreJob(r *http.Request, id int64) (*models.Job, error) {
+	before, err := s.repo.GetTrashedJob(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.RestoreJob(id); err != nil {
+		return nil, err
+	}
+
+	job, err := s.repo.GetJob(id)
+	if err != nil {
+		return nil, err
+	}
+	s.scheduler.AddJob(*job)
+	s.auditJob(r, models.AuditJobUndelete, before, job)
+
+	return job, nil
+}
+
+// purgeJob permanently deletes a job in the trash and records the change
+func (s *Server) purgeJob(r *http.Request, id int64) error {
+	job, err := s.repo.GetTrashedJob(id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.PurgeJob(id); err != nil {
+		return err
+	}
+	s.auditJob(r, models.AuditJobPurge, job, nil)
+
+	return nil
+}
+
+// handleTrash shows the jobs in the trash
+func (s *Server) handleTrash(w http.ResponseWriter, r *http.Request) {
+	jobs, err := s.trashedJobs()
+	if err != nil {
+		http.Error(w, "Failed to load trash", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]interface{}{
+		"Jobs":          jobs,
+		"RetentionDays": int(s.cfg.TrashRetention.Hours() / 24),
+	}
+
+	s.render(w, r, "trash.html", data)
+}
+
+// handleRestoreJob takes a job out of the trash
+func (s *Server) handleRestoreJob(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid job ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := s.restoreJob(r, id); err != nil {
+		if errors.Is(err, storage.ErrJobNotFound) {
+			http.Error(w, "Job not found in trash", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to restore job", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/jobs/"+strconv.FormatInt(id, 10), http.StatusSeeOther)
+}
+
+// handlePurgeJob permanently deletes a job in the trash
+func (s *Server) handlePurgeJob(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid job ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.purgeJob(r, id); err != nil {
+		if errors.Is(err, storage.ErrJobNotFound) {
+			http.Error(w, "Job not found in trash", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to purge job", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/trash", http.StatusSeeOther)
+}
@@ -0,0 +1,83 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/rauche/cronnor/internal/auth"
+	"github.com/rauche/cronnor/internal/models"
+)
+
+func TestTrashRestoreAndPurge(t *testing.T) {
+	s := newTestServer(t)
+	cookie, token := createTestUser(t, s, auth.RoleEditor)
+
+	id, err := s.repo.CreateJob(models.CreateJobParams{
+		Name: "sync", CronExpr: "0 0 * * * *", URL: "http://example.com", Method: "GET",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.repo.CreateJobLog(models.JobLog{JobID: id, Status: "success"}); err != nil {
+		t.Fatal(err)
+	}
+	path := strconv.FormatInt(id, 10)
+
+	serve := func(method, path string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.AddCookie(cookie)
+		req.Header.Set(csrfHeader, csrfTokenFor(cookie.Value))
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := serve("POST", "/jobs/"+path+"/delete"); code >= 400 {
+		t.Fatalf("delete: got %d", code)
+	}
+	if _, err := s.repo.GetJob(id); err == nil {
+		t.Error("trashed job should not be returned by GetJob")
+	}
+	if jobs, _ := s.repo.GetAllJobs(models.JobFilter{}); len(jobs) != 0 {
+		t.Errorf("trashed job should not be listed, got %d jobs", len(jobs))
+	}
+	if logs, _ := s.repo.GetJobLogs(id, 10); len(logs) != 1 {
+		t.Errorf("trashed job should keep its logs, got %d", len(logs))
+	}
+
+	// Recently trashed jobs survive the retention sweep
+	if n, err := s.repo.PurgeDeletedJobs(time.Hour); err != nil || n != 0 {
+		t.Errorf("expected nothing to be purged, got %d (%v)", n, err)
+	}
+
+	if code := serve("POST", "/trash/"+path+"/restore"); code != http.StatusSeeOther {
+		t.Fatalf("restore: expected redirect, got %d", code)
+	}
+	if _, err := s.repo.GetJob(id); err != nil {
+		t.Fatalf("restored job should be visible: %v", err)
+	}
+	if code := serve("POST", "/trash/"+path+"/restore"); code != http.StatusNotFound {
+		t.Errorf("restoring a job not in the trash: expected 404, got %d", code)
+	}
+
+	// Purging through the API removes the job and its history
+	if err := s.repo.DeleteJob(id); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("DELETE", "/api/v1/trash/"+path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("purge: expected 204, got %d", rec.Code)
+	}
+	if _, err := s.repo.GetTrashedJob(id); err == nil {
+		t.Error("purged job should be gone from the trash")
+	}
+	if logs, _ := s.repo.GetJobLogs(id, 10); len(logs) != 0 {
+		t.Errorf("purged job should lose its logs, got %d", len(logs))
+	}
+}
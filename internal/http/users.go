@@ -0,0 +1,166 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rauche/cronnor/internal/auth"
+	"github.com/rauche/cronnor/internal/models"
+)
+
+// renderUsers renders the user management page with an optional form error
+func (s *Server) renderUsers(w http.ResponseWriter, r *http.Request, formError string) {
+	users, err := s.repo.GetUsers()
+	if err != nil {
+		http.Error(w, "Failed to load users", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]interface{}{
+		"Users": users,
+		"Roles": auth.Roles,
+		"Error": formError,
+	}
+
+	s.render(w, r, "users.html", data)
+}
+
+// handleUsers shows the user management page
+func (s *Server) handleUsers(w http.ResponseWriter, r *http.Request) {
+	s.renderUsers(w, r, "")
+}
+
+// handleCreateUser creates a user account
+func (s *Server) handleCreateUser(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	username := strings.TrimSpace(r.FormValue("username"))
+	role := r.FormValue("role")
+
+	if username == "" {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		s.renderUsers(w, r, "Username is required")
+		return
+	}
+	if !auth.ValidRole(role) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		s.renderUsers(w, r, "Unknown role: "+role)
+		return
+	}
+
+	hash, err := auth.HashPassword(r.FormValue("password"))
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		s.renderUsers(w, r, err.Error())
+		return
+	}
+
+	id, err := s.repo.CreateUser(username, hash, role)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		s.renderUsers(w, r, "Failed to create user; the username may already be taken")
+		return
+	}
+
+	s.audit(r, models.AuditEntry{
+		Action:     models.AuditUserCreate,
+		TargetType: "user",
+		TargetID:   id,
+		TargetName: username,
+		Changes: []models.FieldChange{
+			{Field: "username", After: username},
+			{Field: "role", After: role},
+		},
+	})
+
+	http.Redirect(w, r, "/users", http.StatusSeeOther)
+}
+
+// userIDParam parses the user ID URL parameter and rejects the current user,
+// so admins can't lock themselves out
+func userIDParam(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return 0, false
+	}
+
+	if id == auth.UserFromContext(r.Context()).ID {
+		http.Error(w, "You can't change your own account here", http.StatusBadRequest)
+		return 0, false
+	}
+
+	return id, true
+}
+
+// handleUpdateUserRole changes a user's role
+func (s *Server) handleUpdateUserRole(w http.ResponseWriter, r *http.Request) {
+	id, ok := userIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	role := r.FormValue("role")
+	if !auth.ValidRole(role) {
+		http.Error(w, "Unknown role", http.StatusBadRequest)
+		return
+	}
+
+	user, err := s.repo.GetUser(id)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	if err := s.repo.UpdateUserRole(id, role); err != nil {
+		http.Error(w, "Failed to update user", http.StatusInternalServerError)
+		return
+	}
+
+	s.audit(r, models.AuditEntry{
+		Action:     models.AuditUserRole,
+		TargetType: "user",
+		TargetID:   id,
+		TargetName: user.Username,
+		Changes:    []models.FieldChange{{Field: "role", Before: user.Role, After: role}},
+	})
+
+	http.Redirect(w, r, "/users", http.StatusSeeOther)
+}
+
+// handleDeleteUser deletes a user account
+func (s *Server) handleDeleteUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := userIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := s.repo.GetUser(id)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	if err := s.repo.DeleteUser(id); err != nil {
+		http.Error(w, "Failed to delete user", http.StatusInternalServerError)
+		return
+	}
+
+	s.audit(r, models.AuditEntry{
+		Action:     models.AuditUserDelete,
+		TargetType: "user",
+		TargetID:   id,
+		TargetName: user.Username,
+		Changes: []models.FieldChange{
+			{Field: "username", Before: user.Username},
+			{Field: "role", Before: user.Role},
+		},
+	})
+
+	http.Redirect(w, r, "/users", http.StatusSeeOther)
+}
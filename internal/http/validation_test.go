@@ -0,0 +1,116 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/rauche/cronnor/internal/auth"
+	"github.com/rauche/cronnor/internal/models"
+)
+
+func TestJobValidation(t *testing.T) {
+	s := newTestServer(t)
+	cookie, token := createTestUser(t, s, auth.RoleEditor)
+
+	post := func(path string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set(csrfHeader, csrfTokenFor(cookie.Value))
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	invalid := url.Values{
+		"name":      {"  "},
+		"cron_expr": {"every tuesday"},
+		"url":       {"ftp://files.example.com/report"},
+		"method":    {"FETCH"},
+		"payload":   {`{"full": true`},
+		"tags":      {"nightly, no spaces"},
+	}
+
+	rec := post("/jobs", invalid)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("create: expected 422, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		"is required",
+		"is not a valid cron expression",
+		"must be an absolute http or https URL",
+		"must be one of GET, POST, PUT, PATCH, DELETE, HEAD",
+		"must be valid JSON",
+		"may only contain letters",
+		`value="ftp://files.example.com/report"`,
+		`value="nightly, no spaces"`,
+		`action="/jobs"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("create: expected the form to contain %s", want)
+		}
+	}
+	if jobs, _ := s.repo.GetAllJobs(models.JobFilter{}); len(jobs) != 0 {
+		t.Fatalf("create: expected no job to be saved, got %d", len(jobs))
+	}
+
+	id, err := s.repo.CreateJob(models.CreateJobParams{
+		Name: "sync", CronExpr: "0 0 * * * *", URL: "http://example.com", Method: "GET",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec = post("/jobs/1", invalid)
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), `action="/jobs/1"`) {
+		t.Errorf("update: expected the edit form again, got %d", rec.Code)
+	}
+	if job, _ := s.repo.GetJob(id); job.URL != "http://example.com" || job.Revision != 1 {
+		t.Errorf("update: expected the job to be unchanged, got r%d %s", job.Revision, job.URL)
+	}
+
+	req := httptest.NewRequest("PUT", "/api/v1/jobs/1", strings.NewReader(
+		`{"name":"sync","cron_expr":"0 0 * * * *","url":"http://example.com","method":"post","payload":"not json"}`,
+	))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec = httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+
+	var resp apiError
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if rec.Code != http.StatusUnprocessableEntity || len(resp.Fields) != 1 || resp.Fields["payload"] != "must be valid JSON" {
+		t.Errorf("api: expected a payload error, got %d: %s", rec.Code, rec.Body)
+	}
+
+	valid := url.Values{
+		"name":      {" sync "},
+		"cron_expr": {"0 */5 * * * *"},
+		"url":       {"https://example.com/sync"},
+		"method":    {"post"},
+		"payload":   {`{"full": true}`},
+	}
+	if rec := post("/jobs/1", valid); rec.Code != http.StatusSeeOther {
+		t.Fatalf("valid update: expected redirect, got %d", rec.Code)
+	}
+	if job, _ := s.repo.GetJob(id); job.Name != "sync" || job.Method != "POST" || job.CronExpr != "0 */5 * * * *" {
+		t.Errorf("valid update: expected normalized values, got %+v", job)
+	}
+
+	// A grace period that is not a number is rejected rather than defaulted
+	for _, grace := range []string{"soon", "-5"} {
+		heartbeat := url.Values{"kind": {"heartbeat"}, "name": {"backup"}, "cron_expr": {"0 0 * * * *"}, "grace_seconds": {grace}}
+		if rec := post("/jobs", heartbeat); rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), "must be a non-negative integer") {
+			t.Errorf("grace %q: expected a grace period error, got %d", grace, rec.Code)
+		}
+	}
+	// Only heartbeat jobs have one
+	valid.Set("grace_seconds", "soon")
+	if rec := post("/jobs/1", valid); rec.Code != http.StatusSeeOther {
+		t.Errorf("grace for an HTTP job: expected it to be ignored, got %d", rec.Code)
+	}
+}
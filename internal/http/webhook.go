@@ -0,0 +1,310 @@
+package http
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rauche/cronnor/internal/auth"
+	"github.com/rauche/cronnor/internal/jobs"
+	"github.com/rauche/cronnor/internal/models"
+	"github.com/rauche/cronnor/internal/storage"
+)
+
+// maxWebhookBody bounds the body of a trigger call
+const maxWebhookBody = 1 << 20
+
+// webhookCredentials are shown once, when a job's trigger URL is created
+type webhookCredentials struct {
+	URL    string `json:"url"`
+	Token  string `json:"token"`
+	Secret string `json:"secret,omitempty"`
+}
+
+// absoluteURL returns path as an absolute URL on this server, as reached
+// through r
+func absoluteURL(r *http.Request, path string) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host + path
+}
+
+// webhookURL returns the absolute trigger URL for token, as reached through r
+func webhookURL(r *http.Request, token string) string {
+	return absoluteURL(r, "/hooks/"+token)
+}
+
+// createWebhook gives a job a new trigger URL, invalidating any previous one,
+// and records the change. Heartbeat jobs cannot have one.
+func (s *Server) createWebhook(r *http.Request, id int64, signed bool) (*webhookCredentials, error) {
+	before, err := s.repo.GetJob(id)
+	if err != nil {
+		return nil, err
+	}
+	if before.IsHeartbeat() {
+		return nil, jobs.ErrNotRunnable
+	}
+
+	token, hash, err := auth.GenerateWebhookToken()
+	if err != nil {
+		return nil, err
+	}
+
+	creds := &webhookCredentials{URL: webhookURL(r, token), Token: token}
+	var secret sql.NullString
+	if signed {
+		if creds.Secret, err = auth.GenerateWebhookSecret(); err != nil {
+			return nil, err
+		}
+		secret = sql.NullString{String: creds.Secret, Valid: true}
+	}
+
+	if err := s.repo.SetJobWebhook(id, hash, secret); err != nil {
+		return nil, err
+	}
+	s.auditWebhook(r, before)
+
+	return creds, nil
+}
+
+// deleteWebhook removes a job's trigger URL and records the change
+func (s *Server) deleteWebhook(r *http.Request, id int64) error {
+	before, err := s.repo.GetJob(id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.DeleteJobWebhook(id); err != nil {
+		return err
+	}
+	s.auditWebhook(r, before)
+
+	return nil
+}
+
+// auditWebhook records a change to a job's trigger URL
+func (s *Server) auditWebhook(r *http.Request, before *models.Job) {
+	after, err := s.repo.GetJob(before.ID)
+	if err != nil {
+		after = before
+	}
+	s.auditJob(r, models.AuditJobWebhook, before, after)
+}
+
+// handleCreateWebhook creates a trigger URL for a job and shows it once
+func (s *Server) handleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid job ID", http.StatusBadRequest)
+		return
+	}
+
+	creds, err := s.createWebhook(r, id, r.FormValue("hmac") == "true")
+	if errors.Is(err, storage.ErrJobNotFound) {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	} else if errors.Is(err, jobs.ErrNotRunnable) {
+		http.Error(w, "Heartbeat jobs cannot have a trigger URL", http.StatusConflict)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to create trigger URL", http.StatusInternalServerError)
+		return
+	}
+
+	s.renderWebhook(w, r, id, creds)
+}
+
+// handleDeleteWebhook removes a job's trigger URL
+func (s *Server) handleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid job ID", http.StatusBadRequest)
+		return
+	}
+
+	err = s.deleteWebhook(r, id)
+	if errors.Is(err, storage.ErrJobNotFound) {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to remove trigger URL", http.StatusInternalServerError)
+		return
+	}
+
+	s.renderWebhook(w, r, id, nil)
+}
+
+// renderWebhook renders a job's trigger URL card
+func (s *Server) renderWebhook(w http.ResponseWriter, r *http.Request, id int64, creds *webhookCredentials) {
+	job, err := s.repo.GetJob(id)
+	if err != nil {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	data := map[string]interface{}{
+		"Job":     job,
+		"Webhook": creds,
+	}
+	if creds != nil && creds.Secret != "" {
+		data["SignatureHeader"] = auth.SignatureHeader
+	}
+
+	s.render(w, r, "_webhook.html", data)
+}
+
+// handleAPICreateWebhook creates a trigger URL for a job, replacing any
+// previous one, and returns it. The token and secret are not shown again.
+func (s *Server) handleAPICreateWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiJobID(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		HMAC bool `json:"hmac"`
+	}
+	if r.ContentLength != 0 {
+		if err := decodeJSON(r, &req); err != nil {
+			writeAPIError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+			return
+		}
+	}
+
+	creds, err := s.createWebhook(r, id, req.HMAC)
+	if err != nil {
+		writeJobError(w, err, "failed to create webhook")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, creds)
+}
+
+// handleAPIDeleteWebhook removes a job's trigger URL
+func (s *Server) handleAPIDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiJobID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.deleteWebhook(r, id); err != nil {
+		writeJobError(w, err, "failed to delete webhook")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// payloadData returns a trigger call's query and body as payload template
+// variables. JSON bodies are decoded; form bodies become their first values.
+func payloadData(r *http.Request, body []byte) (jobs.PayloadData, error) {
+	data := jobs.PayloadData{
+		Query:   firstValues(r.URL.Query()),
+		RawBody: string(body),
+	}
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		return data, nil
+	}
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return data, err
+		}
+		data.Body = firstValues(form)
+		return data, nil
+	}
+
+	// Other content types are only decoded if they happen to be JSON
+	var decoded interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&decoded); err == nil {
+		data.Body = decoded
+	} else if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		return data, err
+	}
+	return data, nil
+}
+
+// firstValues keeps the first value of each key
+func firstValues(values map[string][]string) map[string]string {
+	first := make(map[string]string, len(values))
+	for key, v := range values {
+		if len(v) > 0 {
+			first[key] = v[0]
+		}
+	}
+	return first
+}
+
+// handleWebhook runs the job that owns the trigger token in the URL. The
+// caller must sign the body if the job has an HMAC secret.
+func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	if err != nil {
+		writeAPIError(w, http.StatusRequestEntityTooLarge, "request body too large")
+		return
+	}
+
+	token := chi.URLParam(r, "token")
+	job, secret, err := s.repo.GetJobByWebhook(auth.HashToken(token))
+	if errors.Is(err, storage.ErrJobNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not found")
+		return
+	} else if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "failed to load job")
+		return
+	}
+
+	if secret.Valid && !auth.CheckSignature(secret.String, body, r.Header.Get(auth.SignatureHeader)) {
+		writeAPIError(w, http.StatusUnauthorized, "invalid signature")
+		return
+	}
+
+	if !job.IsActive {
+		writeAPIError(w, http.StatusConflict, "job is paused")
+		return
+	}
+
+	data, err := payloadData(r, body)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid body: "+err.Error())
+		return
+	}
+
+	// Render once up front so callers hear about bad input rather than
+	// finding an ERROR in the execution history
+	if job.Payload.Valid {
+		if _, err := jobs.RenderPayload(job.Payload.String, data); err != nil {
+			writeJSON(w, http.StatusUnprocessableEntity, apiError{
+				Error:  "validation failed",
+				Fields: map[string]string{"payload": err.Error()},
+			})
+			return
+		}
+	}
+
+	// Name the URL by its prefix, like API tokens, so runs from a replaced
+	// URL can be told apart
+	s.scheduler.Run(*job, jobs.Trigger{
+		Type:  models.TriggerWebhook,
+		Actor: token[:len(auth.WebhookTokenPrefix)+8],
+		Data:  data,
+	})
+
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "started"})
+}
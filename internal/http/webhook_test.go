@@ -0,0 +1,185 @@
+package http
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rauche/cronnor/internal/auth"
+	"github.com/rauche/cronnor/internal/jobs"
+	"github.com/rauche/cronnor/internal/models"
+)
+
+func TestWebhookTrigger(t *testing.T) {
+	s := newTestServer(t)
+	cookie, token := createTestUser(t, s, auth.RoleEditor)
+
+	received := make(chan string, 1)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- string(body)
+	}))
+	defer target.Close()
+
+	api := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := api("POST", "/api/v1/jobs", `{"name":"deploy","cron_expr":"0 0 * * * *","url":"`+target.URL+`","method":"POST",`+
+		`"payload":"{\"ref\": {{ json .Body.ref }}, \"env\": {{ json .Query.env }}}"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create job: expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = api("POST", "/api/v1/jobs/1/webhook", `{"hmac":true}`)
+	var creds webhookCredentials
+	json.Unmarshal(rec.Body.Bytes(), &creds)
+	if rec.Code != http.StatusCreated || !strings.HasPrefix(creds.Token, auth.WebhookTokenPrefix) || creds.Secret == "" {
+		t.Fatalf("create webhook: got %d: %s", rec.Code, rec.Body)
+	}
+	if creds.URL != "http://example.com/hooks/"+creds.Token {
+		t.Errorf("expected the URL to use the request host, got %s", creds.URL)
+	}
+
+	call := func(path, body, signature string) int {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if signature != "" {
+			req.Header.Set(auth.SignatureHeader, signature)
+		}
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	hook := "/hooks/" + creds.Token + "?env=prod"
+	body := `{"ref":"main"}`
+
+	if code := call(hook, body, ""); code != http.StatusUnauthorized {
+		t.Errorf("unsigned: expected 401, got %d", code)
+	}
+	if code := call(hook, body, auth.Sign("wrong", []byte(body))); code != http.StatusUnauthorized {
+		t.Errorf("bad signature: expected 401, got %d", code)
+	}
+	if code := call(hook, `[1]`, auth.Sign(creds.Secret, []byte(`[1]`))); code != http.StatusUnprocessableEntity {
+		t.Errorf("unrenderable body: expected 422, got %d", code)
+	}
+	if code := call("/hooks/whk_unknown", body, ""); code != http.StatusNotFound {
+		t.Errorf("unknown token: expected 404, got %d", code)
+	}
+
+	if code := call(hook, body, auth.Sign(creds.Secret, []byte(body))); code != http.StatusAccepted {
+		t.Fatalf("signed: expected 202, got %d", code)
+	}
+	select {
+	case got := <-received:
+		if got != `{"ref": "main", "env": "prod"}` {
+			t.Errorf("expected the rendered payload, got %s", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the job never ran")
+	}
+
+	var logs []models.JobLog
+	for i := 0; len(logs) == 0; i++ {
+		if i == 100 {
+			t.Fatal("the execution was never logged")
+		}
+		time.Sleep(10 * time.Millisecond)
+		logs, _ = s.repo.GetJobLogs(1, 10)
+	}
+	if logs[0].Trigger != models.TriggerWebhook || logs[0].Actor != creds.Token[:12] {
+		t.Errorf("expected a webhook run by %s, got %q by %q", creds.Token[:12], logs[0].Trigger, logs[0].Actor)
+	}
+
+	if job, _ := s.repo.GetJob(1); !job.Webhook || !job.WebhookHMAC {
+		t.Error("expected the job to report its signed trigger URL")
+	}
+
+	api("POST", "/api/v1/jobs/1/toggle", "")
+	if code := call(hook, body, auth.Sign(creds.Secret, []byte(body))); code != http.StatusConflict {
+		t.Errorf("paused: expected 409, got %d", code)
+	}
+	api("POST", "/api/v1/jobs/1/toggle", "")
+
+	// Regenerating from the job page replaces the old URL
+	form := url.Values{"hmac": {"false"}}
+	req := httptest.NewRequest("POST", "/jobs/1/webhook", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set(csrfHeader, csrfTokenFor(cookie.Value))
+	req.AddCookie(cookie)
+	rec = httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "/hooks/"+auth.WebhookTokenPrefix) {
+		t.Fatalf("regenerate: expected the new URL, got %d", rec.Code)
+	}
+	if code := call(hook, body, auth.Sign(creds.Secret, []byte(body))); code != http.StatusNotFound {
+		t.Errorf("old token: expected 404, got %d", code)
+	}
+
+	if rec := api("DELETE", "/api/v1/jobs/1/webhook", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("delete webhook: expected 204, got %d", rec.Code)
+	}
+	if job, _ := s.repo.GetJob(1); job.Webhook {
+		t.Error("expected the trigger URL to be removed")
+	}
+}
+
+func TestPayloadSentVerbatim(t *testing.T) {
+	s := newTestServer(t)
+	_, token := createTestUser(t, s, auth.RoleEditor)
+
+	received := make(chan string, 1)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- string(body)
+	}))
+	defer target.Close()
+
+	// Not a valid template, but valid JSON
+	payload := `{"message": "{{ deploy }} at {{"}`
+	body, _ := json.Marshal(map[string]string{
+		"name": "notify", "cron_expr": "0 0 * * * *", "url": target.URL, "method": "POST", "payload": payload,
+	})
+	req := httptest.NewRequest("POST", "/api/v1/jobs", strings.NewReader(string(body)))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create job: expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+
+	job, err := s.repo.GetJob(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.scheduler.Run(*job, jobs.Trigger{Type: models.TriggerSchedule, ScheduledAt: time.Now()})
+	select {
+	case got := <-received:
+		if got != payload {
+			t.Errorf("expected the payload as written, got %s", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the job never ran")
+	}
+
+	var logs []models.JobLog
+	for i := 0; len(logs) == 0; i++ {
+		if i == 100 {
+			t.Fatal("the execution was never logged")
+		}
+		time.Sleep(10 * time.Millisecond)
+		logs, _ = s.repo.GetJobLogs(1, 10)
+	}
+	if logs[0].Status != "SUCCESS" {
+		t.Errorf("expected a successful scheduled run, got %s", logs[0].Status)
+	}
+}
@@ -2,47 +2,162 @@ package jobs
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"database/sql"
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptrace"
 	"time"
 
+	"github.com/rauche/cronnor/internal/events"
+	"github.com/rauche/cronnor/internal/metrics"
 	"github.com/rauche/cronnor/internal/models"
 	"github.com/rauche/cronnor/internal/storage"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Executor handles HTTP job execution
 type Executor struct {
-	repo   *storage.Repository
-	client *http.Client
+	repo     *storage.Repository
+	events   *events.Bus
+	metrics  *metrics.Metrics
+	tracer   trace.Tracer
+	client   *http.Client
+	outcomes *outcomeHandlers
 }
 
-// NewExecutor creates a new executor
-func NewExecutor(repo *storage.Repository) *Executor {
+// NewExecutor creates a new executor that publishes executions to bus,
+// records them in m and traces them with tracer
+func NewExecutor(repo *storage.Repository, bus *events.Bus, m *metrics.Metrics, tracer trace.Tracer) *Executor {
 	return &Executor{
-		repo: repo,
+		repo:    repo,
+		events:  bus,
+		metrics: m,
+		tracer:  tracer,
 		client: &http.Client{
 			Timeout: 10 * time.Second,
 		},
 	}
 }
 
-// Execute runs a job and logs the result
-func (e *Executor) Execute(job models.Job) error {
+// maxResponseBody bounds how much of a response body is read
+const maxResponseBody = 10 * 1024
+
+// Timing breaks a request down into its phases. Phases that did not happen,
+// such as DNS on a reused connection, are zero.
+type Timing struct {
+	DNS       time.Duration
+	Connect   time.Duration
+	TLS       time.Duration
+	FirstByte time.Duration // from the request being sent to the first response byte
+	Transfer  time.Duration // reading the response body
+	Total     time.Duration
+}
+
+// Result is the response to a job's request
+type Result struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+	Body       []byte
+	Truncated  bool // the body was longer than maxResponseBody
+	Timing     Timing
+}
+
+// Trigger describes what started an execution
+type Trigger struct {
+	Type        string      // models.TriggerSchedule, TriggerManual or TriggerWebhook
+	ActorID     int64       // user who started a manual run
+	Actor       string      // who or what started the run, for display
+	ScheduledAt time.Time   // cron fire time of scheduled runs
+	Data        PayloadData // variables for the payload template of webhook runs
+}
+
+// newJobLog starts the log entry of an execution that began at start, linking
+// it to the trace in ctx if there is one
+func newJobLog(ctx context.Context, job models.Job, trigger Trigger, start time.Time) models.JobLog {
+	log := models.JobLog{
+		JobID:       job.ID,
+		JobRevision: sql.NullInt64{Int64: job.Revision, Valid: true},
+		Trigger:     trigger.Type,
+		ActorID:     trigger.ActorID,
+		Actor:       trigger.Actor,
+		StartedAt:   sql.NullTime{Time: start, Valid: true},
+	}
+	if !trigger.ScheduledAt.IsZero() {
+		log.ScheduledAt = sql.NullTime{Time: trigger.ScheduledAt, Valid: true}
+		log.LagMs = sql.NullInt64{Int64: start.Sub(trigger.ScheduledAt).Milliseconds(), Valid: true}
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		log.TraceID = sql.NullString{String: sc.TraceID().String(), Valid: true}
+	}
+	return log
+}
+
+// send performs a job's request for trigger, timing each phase. Webhook runs
+// render the payload template with the call's data; other runs send the
+// payload verbatim. The request is traced as a client span of ctx and carries
+// its traceparent header.
+func (e *Executor) send(ctx context.Context, job models.Job, trigger Trigger) (result *Result, err error) {
+	ctx, span := e.tracer.Start(ctx, "HTTP "+job.Method, trace.WithSpanKind(trace.SpanKindClient))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetAttributes(semconv.HTTPResponseStatusCode(result.StatusCode))
+			if result.StatusCode >= 400 {
+				span.SetStatus(codes.Error, result.Status)
+			}
+		}
+		span.End()
+	}()
+
+	var timing Timing
+	var dnsStart, connectStart, tlsStart, wrote, firstByte time.Time
+	clientTrace := &httptrace.ClientTrace{
+		DNSStart:             func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+		DNSDone:              func(httptrace.DNSDoneInfo) { timing.DNS = time.Since(dnsStart) },
+		ConnectStart:         func(string, string) { connectStart = time.Now() },
+		ConnectDone:          func(string, string, error) { timing.Connect = time.Since(connectStart) },
+		TLSHandshakeStart:    func() { tlsStart = time.Now() },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { timing.TLS = time.Since(tlsStart) },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { wrote = time.Now() },
+		GotFirstResponseByte: func() { firstByte = time.Now() },
+	}
 	start := time.Now()
 
 	// Prepare request
 	var body io.Reader
 	if job.Payload.Valid && job.Payload.String != "" {
-		body = bytes.NewBufferString(job.Payload.String)
+		payload := job.Payload.String
+		if trigger.Type == models.TriggerWebhook {
+			if payload, err = RenderPayload(payload, trigger.Data); err != nil {
+				return nil, fmt.Errorf("failed to render payload: %w", err)
+			}
+		}
+		body = bytes.NewBufferString(payload)
 	}
 
-	req, err := http.NewRequest(job.Method, job.URL, body)
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, clientTrace), job.Method, job.URL, body)
 	if err != nil {
-		return e.logError(job.ID, start, fmt.Errorf("failed to create request: %w", err))
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
+	// The query is left out of the span since it may hold secrets
+	span.SetAttributes(
+		semconv.HTTPRequestMethodKey.String(req.Method),
+		semconv.URLScheme(req.URL.Scheme),
+		semconv.ServerAddress(req.URL.Hostname()),
+		semconv.URLPath(req.URL.Path),
+	)
+	propagation.TraceContext{}.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
 	// Set headers
 	if job.Payload.Valid && job.Payload.String != "" {
 		req.Header.Set("Content-Type", "application/json")
@@ -52,69 +167,126 @@ func (e *Executor) Execute(job models.Job) error {
 	// Execute request
 	resp, err := e.client.Do(req)
 	if err != nil {
-		return e.logError(job.ID, start, fmt.Errorf("failed to execute request: %w", err))
+		return nil, fmt.Errorf("failed to execute request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Read response
-	responseBody, err := io.ReadAll(io.LimitReader(resp.Body, 10*1024)) // Limit to 10KB
+	responseBody, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody+1))
 	if err != nil {
-		return e.logError(job.ID, start, fmt.Errorf("failed to read response: %w", err))
+		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
-	duration := time.Since(start).Milliseconds()
+	end := time.Now()
+	timing.Total = end.Sub(start)
+	if !firstByte.IsZero() {
+		if !wrote.IsZero() {
+			timing.FirstByte = firstByte.Sub(wrote)
+		}
+		timing.Transfer = end.Sub(firstByte)
+	}
+
+	result = &Result{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header,
+		Body:       responseBody,
+		Timing:     timing,
+	}
+	if len(responseBody) > maxResponseBody {
+		result.Body = responseBody[:maxResponseBody]
+		result.Truncated = true
+	}
+
+	return result, nil
+}
+
+// Execute runs a job and logs the result, recording what triggered it and
+// tracing it as a child of ctx
+func (e *Executor) Execute(ctx context.Context, job models.Job, trigger Trigger) error {
+	ctx, span := e.tracer.Start(ctx, "execute")
+	defer span.End()
+
+	start := time.Now()
+	e.events.Publish(events.Event{Type: events.ExecutionStarted, JobID: job.ID})
+
+	e.metrics.ExecutionStarted()
+	result, err := e.send(ctx, job, trigger)
+	e.metrics.ExecutionDone()
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return e.logError(ctx, job, trigger, start, err)
+	}
+
+	duration := result.Timing.Total.Milliseconds()
 
 	// Determine status
 	status := "SUCCESS"
-	if resp.StatusCode >= 400 {
+	if result.StatusCode >= 400 {
 		status = "FAILED"
+		span.SetStatus(codes.Error, result.Status)
 	}
 
 	// Log execution
-	log := models.JobLog{
-		JobID:      job.ID,
-		Status:     status,
-		HTTPCode:   sql.NullInt64{Int64: int64(resp.StatusCode), Valid: true},
-		DurationMs: sql.NullInt64{Int64: duration, Valid: true},
-		ResponseBody: sql.NullString{
-			String: string(responseBody),
-			Valid:  len(responseBody) > 0,
-		},
+	log := newJobLog(ctx, job, trigger, start)
+	log.Status = status
+	log.HTTPCode = sql.NullInt64{Int64: int64(result.StatusCode), Valid: true}
+	log.DurationMs = sql.NullInt64{Int64: duration, Valid: true}
+	log.ResponseBody = sql.NullString{
+		String: string(result.Body),
+		Valid:  len(result.Body) > 0,
 	}
 
-	if err := e.repo.CreateJobLog(log); err != nil {
+	logID, err := e.repo.CreateJobLog(log)
+	if err != nil {
 		return fmt.Errorf("failed to create job log: %w", err)
 	}
+	e.metrics.ObserveExecution(job, log)
 
 	// Update job status
 	if err := e.repo.UpdateJobStatus(job.ID, status); err != nil {
 		return fmt.Errorf("failed to update job status: %w", err)
 	}
 
+	e.finished(events.Event{Type: events.ExecutionFinished, JobID: job.ID, LogID: logID, Status: status})
 	return nil
 }
 
+// DryRun sends a job's request once without logging the result or touching
+// the job's status, so unsaved configurations can be tried out
+func (e *Executor) DryRun(job models.Job) (*Result, error) {
+	return e.send(context.Background(), job, Trigger{})
+}
+
 // logError logs an error execution
-func (e *Executor) logError(jobID int64, start time.Time, err error) error {
+func (e *Executor) logError(ctx context.Context, job models.Job, trigger Trigger, start time.Time, err error) error {
 	duration := time.Since(start).Milliseconds()
 
-	log := models.JobLog{
-		JobID:      jobID,
-		Status:     "ERROR",
-		DurationMs: sql.NullInt64{Int64: duration, Valid: true},
-		ErrorMessage: sql.NullString{
-			String: err.Error(),
-			Valid:  true,
-		},
+	log := newJobLog(ctx, job, trigger, start)
+	log.Status = "ERROR"
+	log.DurationMs = sql.NullInt64{Int64: duration, Valid: true}
+	log.ErrorMessage = sql.NullString{
+		String: err.Error(),
+		Valid:  true,
 	}
 
-	if logErr := e.repo.CreateJobLog(log); logErr != nil {
+	logID, logErr := e.repo.CreateJobLog(log)
+	if logErr != nil {
 		return fmt.Errorf("failed to log error: %w (original error: %v)", logErr, err)
 	}
+	e.metrics.ObserveExecution(job, log)
 
-	if statusErr := e.repo.UpdateJobStatus(jobID, "ERROR"); statusErr != nil {
+	if statusErr := e.repo.UpdateJobStatus(job.ID, "ERROR"); statusErr != nil {
 		return fmt.Errorf("failed to update status: %w (original error: %v)", statusErr, err)
 	}
 
+	e.finished(events.Event{Type: events.ExecutionFinished, JobID: job.ID, LogID: logID, Status: "ERROR"})
+
 	return err
 }
+
+// finished hands a logged outcome to the outcome handlers, then publishes it
+func (e *Executor) finished(event events.Event) {
+	e.outcomes.call(event)
+	e.events.Publish(event)
+}
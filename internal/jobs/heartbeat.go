@@ -0,0 +1,175 @@
+package jobs
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/rauche/cronnor/internal/events"
+	"github.com/rauche/cronnor/internal/models"
+)
+
+// ErrNotRunnable is returned when asked to run a heartbeat job, which has no
+// request to send
+var ErrNotRunnable = errors.New("heartbeat jobs cannot be run")
+
+// ErrNotHeartbeat is returned when asked for the ping URL of a job that is
+// not a heartbeat job
+var ErrNotHeartbeat = errors.New("only heartbeat jobs have a ping URL")
+
+// DefaultGrace is how late a heartbeat may be when no grace period is set
+const DefaultGrace = 5 * time.Minute
+
+// Heartbeat pings
+const (
+	PingStart   = "start"
+	PingSuccess = "success"
+	PingFail    = "fail"
+)
+
+// pingStatuses maps each ping to the status it records
+var pingStatuses = map[string]string{
+	PingStart:   "STARTED",
+	PingSuccess: "SUCCESS",
+	PingFail:    "FAILED",
+}
+
+// ValidPing reports whether ping is a known heartbeat ping
+func ValidPing(ping string) bool {
+	_, ok := pingStatuses[ping]
+	return ok
+}
+
+// Ping records a heartbeat job's ping in its history. Success and fail pings
+// that follow a start ping are timed from it.
+func (s *Scheduler) Ping(job models.Job, ping string, body []byte, source string) error {
+	status, ok := pingStatuses[ping]
+	if !ok {
+		return fmt.Errorf("unknown ping %q", ping)
+	}
+
+	now := time.Now()
+	entry := models.JobLog{
+		JobID:       job.ID,
+		JobRevision: sql.NullInt64{Int64: job.Revision, Valid: true},
+		Status:      status,
+		Trigger:     models.TriggerPing,
+		Actor:       source,
+		StartedAt:   sql.NullTime{Time: now, Valid: true},
+	}
+	if len(body) > maxResponseBody {
+		body = body[:maxResponseBody]
+	}
+	entry.ResponseBody = sql.NullString{String: string(body), Valid: len(body) > 0}
+
+	if ping == PingStart {
+		if _, err := s.repo.CreateJobLog(entry); err != nil {
+			return fmt.Errorf("failed to record ping: %w", err)
+		}
+		s.events.Publish(events.Event{Type: events.ExecutionStarted, JobID: job.ID})
+		return nil
+	}
+
+	if last, err := s.repo.GetLatestJobLog(job.ID); err == nil && last != nil && last.Status == "STARTED" && last.StartedAt.Valid {
+		entry.StartedAt = last.StartedAt
+		entry.DurationMs = sql.NullInt64{Int64: now.Sub(last.StartedAt.Time).Milliseconds(), Valid: true}
+	}
+
+	logID, err := s.repo.CreateJobLog(entry)
+	if err != nil {
+		return fmt.Errorf("failed to record ping: %w", err)
+	}
+	s.metrics.ObserveExecution(job, entry)
+	if err := s.repo.UpdateJobStatus(job.ID, status); err != nil {
+		return fmt.Errorf("failed to update job status: %w", err)
+	}
+
+	event := events.Event{Type: events.ExecutionFinished, JobID: job.ID, LogID: logID, Status: status}
+	s.outcomes.call(event)
+	s.events.Publish(event)
+	return nil
+}
+
+// PingDue returns when a heartbeat job's next ping is expected: on its
+// schedule after its last ping, or after it was created if it was never
+// pinged. ok is false if its cron expression is invalid.
+func PingDue(job models.Job) (due time.Time, ok bool) {
+	schedule, err := ParseCronExpr(job.CronExpr)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	since := job.CreatedAt
+	if job.LastRunAt.Valid {
+		since = job.LastRunAt.Time
+	}
+	return schedule.Next(since), true
+}
+
+// heartbeatStatus returns the status a heartbeat job should have at now: LATE
+// once its next ping is overdue and DOWN once the grace period is over too.
+// It returns "" if the status should stay as it is. Failed and never pinged
+// jobs go LATE too; only DOWN jobs are left alone.
+func heartbeatStatus(job models.Job, now time.Time) string {
+	if job.LastStatus.String == "DOWN" {
+		return ""
+	}
+
+	due, ok := PingDue(job)
+	if !ok || !now.After(due) {
+		return ""
+	}
+
+	grace := job.Grace
+	if grace <= 0 {
+		grace = DefaultGrace
+	}
+	if now.After(due.Add(grace)) {
+		return "DOWN"
+	}
+	return "LATE"
+}
+
+// CheckHeartbeats marks every active heartbeat job that missed a ping as of
+// now LATE or DOWN
+func (s *Scheduler) CheckHeartbeats(now time.Time) {
+	jobs, err := s.repo.GetActiveJobs()
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return
+	}
+
+	for _, job := range jobs {
+		if !job.IsHeartbeat() {
+			continue
+		}
+
+		status := heartbeatStatus(job, now)
+		if status == "" || status == job.LastStatus.String {
+			continue
+		}
+
+		marked, err := s.repo.MarkHeartbeat(job.ID, status, job.LastRunAt)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+			continue
+		}
+		if marked {
+			log.Printf("Heartbeat job %d (%s) is %s", job.ID, job.Name, status)
+			event := events.Event{Type: events.JobChanged, JobID: job.ID, Status: status}
+			s.outcomes.call(event)
+			s.events.Publish(event)
+		}
+	}
+}
+
+// MonitorHeartbeats checks heartbeat jobs for missed pings every interval
+func (s *Scheduler) MonitorHeartbeats(interval time.Duration) error {
+	check := func() { s.CheckHeartbeats(time.Now()) }
+	if _, err := s.cron.AddFunc("@every "+interval.String(), check); err != nil {
+		return fmt.Errorf("failed to schedule heartbeat checks: %w", err)
+	}
+	return nil
+}
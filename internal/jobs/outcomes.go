@@ -0,0 +1,44 @@
+package jobs
+
+import (
+	"sync"
+
+	"github.com/rauche/cronnor/internal/events"
+)
+
+// OutcomeHandler is called with every outcome once it is logged: finished
+// executions and pings, whose events carry their log entry's ID, and
+// heartbeat jobs turning LATE or DOWN. Unlike bus subscribers, which may
+// fall behind and miss events, handlers see every outcome, in the goroutine
+// that recorded it.
+type OutcomeHandler func(events.Event)
+
+// outcomeHandlers holds the handlers registered with a scheduler; a nil
+// *outcomeHandlers calls none
+type outcomeHandlers struct {
+	mu       sync.RWMutex
+	handlers []OutcomeHandler
+}
+
+func (o *outcomeHandlers) add(h OutcomeHandler) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.handlers = append(o.handlers, h)
+}
+
+func (o *outcomeHandlers) call(e events.Event) {
+	if o == nil {
+		return
+	}
+
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	for _, h := range o.handlers {
+		h(e)
+	}
+}
+
+// OnOutcome registers h to be called with every outcome from now on
+func (s *Scheduler) OnOutcome(h OutcomeHandler) {
+	s.outcomes.add(h)
+}
@@ -0,0 +1,52 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"text/template"
+)
+
+// ErrPayloadNotJSON is returned when a payload does not render to valid JSON
+var ErrPayloadNotJSON = errors.New("payload is not valid JSON")
+
+// PayloadData is what a job's payload template can refer to. Only webhook
+// runs render the template; other runs send the payload verbatim.
+type PayloadData struct {
+	Query   map[string]string // first value of each query parameter
+	Body    interface{}       // the decoded JSON or form body
+	RawBody string
+}
+
+var payloadFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// RenderPayload expands a payload template such as {"ref": {{ json .Body.ref }}}
+// with data. The result must be valid JSON.
+func RenderPayload(payload string, data PayloadData) (string, error) {
+	tmpl, err := template.New("payload").Funcs(payloadFuncs).Parse(payload)
+	if err != nil {
+		return "", err
+	}
+
+	if data.Query == nil {
+		data.Query = map[string]string{}
+	}
+	if data.Body == nil {
+		data.Body = map[string]interface{}{}
+	}
+
+	var out strings.Builder
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+
+	if !json.Valid([]byte(out.String())) {
+		return "", ErrPayloadNotJSON
+	}
+	return out.String(), nil
+}
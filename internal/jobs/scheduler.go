@@ -1,30 +1,64 @@
 package jobs
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"log"
 	"sync"
+	"time"
 
+	"github.com/rauche/cronnor/internal/events"
+	"github.com/rauche/cronnor/internal/metrics"
 	"github.com/rauche/cronnor/internal/models"
 	"github.com/rauche/cronnor/internal/storage"
 	"github.com/robfig/cron/v3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// cronParser parses expressions the same way as the scheduler's cron.WithSeconds
+var cronParser = cron.NewParser(
+	cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
+)
+
+// ParseCronExpr parses a cron expression using the scheduler's format
+func ParseCronExpr(expr string) (cron.Schedule, error) {
+	return cronParser.Parse(expr)
+}
+
 // Scheduler manages cron jobs
 type Scheduler struct {
 	cron     *cron.Cron
 	repo     *storage.Repository
 	executor *Executor
+	events   *events.Bus
+	metrics  *metrics.Metrics
+	tracer   trace.Tracer
+	outcomes *outcomeHandlers
 	entries  map[int64]cron.EntryID // job ID -> cron entry ID
 	mu       sync.RWMutex
 }
 
-// NewScheduler creates a new scheduler
-func NewScheduler(repo *storage.Repository) *Scheduler {
+// tracerName identifies the spans of job executions
+const tracerName = "github.com/rauche/cronnor/internal/jobs"
+
+// NewScheduler creates a new scheduler that publishes job changes and
+// executions to bus, records them in m and traces them with tp
+func NewScheduler(repo *storage.Repository, bus *events.Bus, m *metrics.Metrics, tp trace.TracerProvider) *Scheduler {
+	tracer := tp.Tracer(tracerName)
+	outcomes := &outcomeHandlers{}
+	executor := NewExecutor(repo, bus, m, tracer)
+	executor.outcomes = outcomes
 	return &Scheduler{
 		cron:     cron.New(cron.WithSeconds()),
 		repo:     repo,
-		executor: NewExecutor(repo),
+		executor: executor,
+		events:   bus,
+		metrics:  m,
+		tracer:   tracer,
+		outcomes: outcomes,
 		entries:  make(map[int64]cron.EntryID),
 	}
 }
@@ -63,65 +97,189 @@ func (s *Scheduler) AddJob(job models.Job) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	return s.addJob(job)
+}
+
+// addJob schedules a job; the caller holds s.mu
+func (s *Scheduler) addJob(job models.Job) error {
+	defer s.events.Publish(events.Event{Type: events.JobChanged, JobID: job.ID})
+
 	// Remove existing entry if present
 	if entryID, exists := s.entries[job.ID]; exists {
 		s.cron.Remove(entryID)
 		delete(s.entries, job.ID)
+		s.metrics.SetSchedulerEntries(len(s.entries))
 	}
 
-	// Only schedule if active
-	if !job.IsActive {
+	// Only schedule if active; heartbeat jobs are pinged instead of run
+	if !job.IsActive || job.IsHeartbeat() {
+		s.recordNextRun(job.ID, nil)
 		return nil
 	}
 
 	// Add job to cron
-	entryID, err := s.cron.AddFunc(job.CronExpr, func() {
-		s.executeJob(job)
-	})
+	schedule, err := ParseCronExpr(job.CronExpr)
 	if err != nil {
 		return fmt.Errorf("failed to add cron job: %w", err)
 	}
+	fires := &fireTimes{Schedule: schedule}
+	entryID := s.cron.Schedule(fires, cron.FuncJob(func() {
+		scheduled := fires.due(time.Now())
+		s.recordNextRun(job.ID, schedule)
+		s.executeJob(job, Trigger{Type: models.TriggerSchedule, ScheduledAt: scheduled})
+	}))
 
 	s.entries[job.ID] = entryID
+	s.metrics.SetSchedulerEntries(len(s.entries))
+	s.recordNextRun(job.ID, schedule)
 	log.Printf("Scheduled job %d (%s) with cron expression: %s", job.ID, job.Name, job.CronExpr)
 
 	return nil
 }
 
+// fireTimes wraps a job's schedule to remember the times it gave the cron run
+// loop, so each run can tell which one it was started for. The loop records
+// the time on the cron entry only after starting the run.
+type fireTimes struct {
+	cron.Schedule
+	mu    sync.Mutex
+	times []time.Time
+}
+
+// Next returns the schedule's next time after t and remembers it
+func (f *fireTimes) Next(t time.Time) time.Time {
+	next := f.Schedule.Next(t)
+	f.mu.Lock()
+	f.times = append(f.times, next)
+	f.mu.Unlock()
+	return next
+}
+
+// due returns the latest remembered time that has passed at now, which is
+// the one a run starting at now was fired for, and forgets it and those
+// before it. It returns the zero time if none has passed.
+func (f *fireTimes) due(now time.Time) time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var due time.Time
+	i := 0
+	for ; i < len(f.times) && !f.times[i].After(now); i++ {
+		due = f.times[i]
+	}
+	f.times = f.times[i:]
+	return due
+}
+
 // RemoveJob removes a job from the scheduler
 func (s *Scheduler) RemoveJob(jobID int64) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	s.removeJob(jobID)
+}
+
+// removeJob unschedules a job; the caller holds s.mu
+func (s *Scheduler) removeJob(jobID int64) {
 	if entryID, exists := s.entries[jobID]; exists {
 		s.cron.Remove(entryID)
 		delete(s.entries, jobID)
+		s.metrics.SetSchedulerEntries(len(s.entries))
+		s.recordNextRun(jobID, nil)
 		log.Printf("Removed job %d from scheduler", jobID)
 	}
+	s.events.Publish(events.Event{Type: events.JobChanged, JobID: jobID})
+}
+
+// recordNextRun stores when a job will next run so listings can sort by it;
+// a nil schedule clears it
+func (s *Scheduler) recordNextRun(jobID int64, schedule cron.Schedule) {
+	var next sql.NullTime
+	if schedule != nil {
+		next = sql.NullTime{Time: schedule.Next(time.Now()), Valid: true}
+	}
+
+	if err := s.repo.SetJobNextRun(jobID, next); err != nil {
+		log.Printf("Warning: %v", err)
+	}
 }
 
-// ExecuteNow executes a job immediately (bypassing the cron schedule)
-func (s *Scheduler) ExecuteNow(jobID int64) error {
+// ExecuteNow executes a job immediately (bypassing the cron schedule) on
+// behalf of trigger
+func (s *Scheduler) ExecuteNow(jobID int64, trigger Trigger) error {
 	job, err := s.repo.GetJob(jobID)
 	if err != nil {
 		return fmt.Errorf("failed to get job: %w", err)
 	}
+	if job.IsHeartbeat() {
+		return ErrNotRunnable
+	}
 
-	go s.executeJob(*job)
+	go s.executeJob(*job, trigger)
 	return nil
 }
 
-// executeJob executes a job
-func (s *Scheduler) executeJob(job models.Job) {
+// Run executes a job immediately on behalf of trigger
+func (s *Scheduler) Run(job models.Job, trigger Trigger) {
+	go s.executeJob(job, trigger)
+}
+
+// DryRun sends a job's request once, without recording anything. The job
+// need not be saved.
+func (s *Scheduler) DryRun(job models.Job) (*Result, error) {
+	return s.executor.DryRun(job)
+}
+
+// executeJob executes a job in a new trace. Scheduled runs start their span
+// at the cron fire time, so schedule lag shows up in the trace.
+func (s *Scheduler) executeJob(job models.Job, trigger Trigger) {
 	log.Printf("Executing job %d (%s): %s %s", job.ID, job.Name, job.Method, job.URL)
 
-	if err := s.executor.Execute(job); err != nil {
+	opts := []trace.SpanStartOption{
+		trace.WithNewRoot(),
+		trace.WithAttributes(
+			attribute.Int64("cronnor.job.id", job.ID),
+			attribute.String("cronnor.job.name", job.Name),
+			attribute.String("cronnor.trigger", trigger.Type),
+		),
+	}
+	if !trigger.ScheduledAt.IsZero() {
+		opts = append(opts, trace.WithTimestamp(trigger.ScheduledAt))
+	}
+	ctx, span := s.tracer.Start(context.Background(), "run", opts...)
+	defer span.End()
+
+	if err := s.executor.Execute(ctx, job, trigger); err != nil {
+		span.SetStatus(codes.Error, err.Error())
 		log.Printf("Job %d (%s) execution failed: %v", job.ID, job.Name, err)
 	} else {
 		log.Printf("Job %d (%s) executed successfully", job.ID, job.Name)
 	}
 }
 
+// SchedulePurge permanently deletes jobs that have been in the trash for
+// longer than retention, checking every hour
+func (s *Scheduler) SchedulePurge(retention time.Duration) error {
+	purge := func() {
+		n, err := s.repo.PurgeDeletedJobs(retention)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+			return
+		}
+		if n > 0 {
+			log.Printf("Purged %d job(s) from the trash", n)
+		}
+	}
+
+	if _, err := s.cron.AddFunc("@hourly", purge); err != nil {
+		return fmt.Errorf("failed to schedule trash purge: %w", err)
+	}
+
+	// Catch up on anything that expired while the server was down
+	go purge()
+	return nil
+}
+
 // ReloadJob reloads a job (e.g., after update)
 func (s *Scheduler) ReloadJob(jobID int64) error {
 	job, err := s.repo.GetJob(jobID)
@@ -131,3 +289,33 @@ func (s *Scheduler) ReloadJob(jobID int64) error {
 
 	return s.AddJob(*job)
 }
+
+// ReloadJobs reloads several jobs in one pass, unscheduling any that no
+// longer exist or are in the trash
+func (s *Scheduler) ReloadJobs(jobIDs []int64) error {
+	jobs, err := s.repo.GetJobsByID(jobIDs)
+	if err != nil {
+		return fmt.Errorf("failed to get jobs: %w", err)
+	}
+
+	found := make(map[int64]models.Job, len(jobs))
+	for _, job := range jobs {
+		found[job.ID] = job
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range jobIDs {
+		job, ok := found[id]
+		if !ok {
+			s.removeJob(id)
+			continue
+		}
+		if err := s.addJob(job); err != nil {
+			log.Printf("Warning: failed to schedule job %d (%s): %v", job.ID, job.Name, err)
+		}
+	}
+
+	return nil
+}
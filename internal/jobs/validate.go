@@ -0,0 +1,124 @@
+package jobs
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rauche/cronnor/internal/models"
+)
+
+// MaxNameLength bounds the length of a job name
+const MaxNameLength = 200
+
+// MaxGrace bounds a heartbeat job's grace period
+const MaxGrace = 7 * 24 * time.Hour
+
+// Methods lists the HTTP methods a job may use
+var Methods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD"}
+
+// ValidateJob normalizes a submitted job configuration and returns a message
+// for each invalid field, keyed by its form and JSON field name
+func ValidateJob(in *models.JobInput) map[string]string {
+	fields := make(map[string]string)
+
+	in.Kind = strings.TrimSpace(in.Kind)
+	if in.Kind == "" {
+		in.Kind = models.JobKindHTTP
+	}
+	if !validKind(in.Kind) {
+		fields["kind"] = "must be one of " + strings.Join(models.JobKinds, ", ")
+	}
+
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" {
+		fields["name"] = "is required"
+	} else if utf8.RuneCountInString(in.Name) > MaxNameLength {
+		fields["name"] = "must be at most " + strconv.Itoa(MaxNameLength) + " characters"
+	}
+
+	in.CronExpr = strings.TrimSpace(in.CronExpr)
+	if in.CronExpr == "" {
+		fields["cron_expr"] = "is required"
+	} else if _, err := ParseCronExpr(in.CronExpr); err != nil {
+		fields["cron_expr"] = "is not a valid cron expression: " + err.Error()
+	}
+
+	// Heartbeat jobs send nothing; they only need a grace period
+	if in.Kind == models.JobKindHeartbeat {
+		in.URL, in.Method, in.Payload = "", "GET", ""
+		if in.Grace == 0 {
+			in.Grace = DefaultGrace
+		}
+		if in.Grace < time.Second || in.Grace > MaxGrace {
+			fields["grace_seconds"] = "must be between 1 and " + strconv.Itoa(int(MaxGrace/time.Second)) + " seconds"
+		}
+	} else {
+		in.Grace = 0
+		validateRequest(in, fields)
+	}
+
+	in.Team = strings.TrimSpace(in.Team)
+
+	for i := range in.Tags {
+		in.Tags[i] = strings.TrimSpace(in.Tags[i])
+	}
+	if tags, err := models.NormalizeTags(in.Tags); err != nil {
+		fields["tags"] = err.Error()
+	} else {
+		in.Tags = tags
+	}
+
+	return fields
+}
+
+// validateRequest checks the request an HTTP job sends
+func validateRequest(in *models.JobInput, fields map[string]string) {
+	in.URL = strings.TrimSpace(in.URL)
+	if u, err := url.Parse(in.URL); in.URL == "" {
+		fields["url"] = "is required"
+	} else if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		fields["url"] = "must be an absolute http or https URL"
+	}
+
+	in.Method = strings.ToUpper(strings.TrimSpace(in.Method))
+	if in.Method == "" {
+		in.Method = "GET"
+	}
+	if !validMethod(in.Method) {
+		fields["method"] = "must be one of " + strings.Join(Methods, ", ")
+	}
+
+	// Payloads are sent with Content-Type: application/json. Webhook runs
+	// render them as templates; other runs send them as written.
+	if strings.TrimSpace(in.Payload) == "" {
+		in.Payload = ""
+	} else if !json.Valid([]byte(in.Payload)) {
+		if _, err := RenderPayload(in.Payload, PayloadData{}); err == ErrPayloadNotJSON {
+			fields["payload"] = "must be valid JSON"
+		} else if err != nil {
+			fields["payload"] = "is not a valid template: " + err.Error()
+		}
+	}
+}
+
+func validKind(kind string) bool {
+	for _, k := range models.JobKinds {
+		if kind == k {
+			return true
+		}
+	}
+	return false
+}
+
+func validMethod(method string) bool {
+	for _, m := range Methods {
+		if method == m {
+			return true
+		}
+	}
+	return false
+}
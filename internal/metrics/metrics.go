@@ -0,0 +1,196 @@
+package metrics
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rauche/cronnor/internal/models"
+	"github.com/rauche/cronnor/internal/storage"
+)
+
+// Per-job label modes, chosen with METRICS_JOB_LABEL. Every job adds a set
+// of series when labelled by name, so large installations can label by team
+// or not at all.
+const (
+	JobLabelName = "name" // a job_name label
+	JobLabelTeam = "team" // a team label, shared by the team's jobs
+	JobLabelNone = "none" // no per-job label
+)
+
+// JobLabelModes lists the per-job label modes
+var JobLabelModes = []string{JobLabelName, JobLabelTeam, JobLabelNone}
+
+// ValidJobLabelMode reports whether mode is a known per-job label mode
+func ValidJobLabelMode(mode string) bool {
+	for _, m := range JobLabelModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// Metrics records how jobs and the HTTP server behave, for Prometheus to
+// scrape
+type Metrics struct {
+	registry *prometheus.Registry
+	mode     string
+
+	executions *prometheus.CounterVec
+	duration   *prometheus.HistogramVec
+	lag        *prometheus.HistogramVec
+	inFlight   prometheus.Gauge
+	entries    prometheus.Gauge
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+}
+
+// New creates the metrics with the given per-job label mode, counting active
+// jobs in repo whenever they are scraped
+func New(repo *storage.Repository, mode string) (*Metrics, error) {
+	if !ValidJobLabelMode(mode) {
+		return nil, fmt.Errorf("invalid job label mode %q", mode)
+	}
+
+	var jobLabels []string
+	switch mode {
+	case JobLabelName:
+		jobLabels = []string{"job_name"}
+	case JobLabelTeam:
+		jobLabels = []string{"team"}
+	}
+
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		mode:     mode,
+
+		executions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cronnor_executions_total",
+			Help: "Job executions, including heartbeat pings, by outcome and trigger.",
+		}, append(jobLabels, "status", "trigger")),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "cronnor_execution_duration_seconds",
+			Help: "How long job executions took.",
+			// Heartbeat jobs are timed from their start ping and may run for
+			// hours
+			Buckets: prometheus.ExponentialBuckets(0.01, 4, 10),
+		}, append(jobLabels, "status")),
+		lag: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "cronnor_schedule_lag_seconds",
+			Help:    "How late scheduled executions started after they were due.",
+			Buckets: []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 15, 60, 300},
+		}, jobLabels),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "cronnor_executions_in_flight",
+			Help: "Job requests being sent right now.",
+		}),
+		entries: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "cronnor_scheduler_entries",
+			Help: "Jobs the scheduler has on its cron schedule.",
+		}),
+
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cronnor_http_requests_total",
+			Help: "HTTP requests handled, by route pattern.",
+		}, []string{"method", "route", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "cronnor_http_request_duration_seconds",
+			Help:    "How long HTTP requests took to handle, by route pattern.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+	}
+
+	m.registry.MustRegister(
+		m.executions, m.duration, m.lag, m.inFlight, m.entries,
+		m.requests, m.requestDuration,
+		activeJobsCollector{repo: repo, desc: activeJobsDesc},
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	return m, nil
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// jobLabels returns the per-job label values for job
+func (m *Metrics) jobLabels(job models.Job) []string {
+	switch m.mode {
+	case JobLabelName:
+		return []string{job.Name}
+	case JobLabelTeam:
+		return []string{job.Team}
+	}
+	return nil
+}
+
+// ExecutionStarted counts a job request as in flight until ExecutionDone
+func (m *Metrics) ExecutionStarted() {
+	m.inFlight.Inc()
+}
+
+// ExecutionDone stops counting a job request as in flight
+func (m *Metrics) ExecutionDone() {
+	m.inFlight.Dec()
+}
+
+// ObserveExecution records an execution from its log entry
+func (m *Metrics) ObserveExecution(job models.Job, entry models.JobLog) {
+	labels := m.jobLabels(job)
+
+	m.executions.WithLabelValues(append(labels, entry.Status, entry.Trigger)...).Inc()
+	if entry.DurationMs.Valid {
+		m.duration.WithLabelValues(append(labels, entry.Status)...).Observe(seconds(entry.DurationMs.Int64))
+	}
+	if entry.LagMs.Valid {
+		m.lag.WithLabelValues(labels...).Observe(seconds(entry.LagMs.Int64))
+	}
+}
+
+// SetSchedulerEntries records how many jobs are on the cron schedule
+func (m *Metrics) SetSchedulerEntries(n int) {
+	m.entries.Set(float64(n))
+}
+
+// ObserveRequest records an HTTP request handled by the route matching
+// pattern
+func (m *Metrics) ObserveRequest(method, pattern string, code int, took time.Duration) {
+	m.requests.WithLabelValues(method, pattern, strconv.Itoa(code)).Inc()
+	m.requestDuration.WithLabelValues(method, pattern).Observe(took.Seconds())
+}
+
+func seconds(ms int64) float64 {
+	return float64(ms) / 1000
+}
+
+var activeJobsDesc = prometheus.NewDesc("cronnor_jobs_active", "Enabled jobs outside the trash.", nil, nil)
+
+// activeJobsCollector counts active jobs when scraped
+type activeJobsCollector struct {
+	repo *storage.Repository
+	desc *prometheus.Desc
+}
+
+func (c activeJobsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c activeJobsCollector) Collect(ch chan<- prometheus.Metric) {
+	count, err := c.repo.CountActiveJobs()
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(count))
+}
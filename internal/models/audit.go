@@ -0,0 +1,136 @@
+package models
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Audit actions
+const (
+	AuditJobCreate     = "job.create"
+	AuditJobUpdate     = "job.update"
+	AuditJobToggle     = "job.toggle"
+	AuditJobDelete     = "job.delete"
+	AuditJobRun        = "job.run"
+	AuditJobRestore    = "job.restore"
+	AuditJobUndelete   = "job.undelete"
+	AuditJobPurge      = "job.purge"
+	AuditJobWebhook    = "job.webhook"
+	AuditJobPingURL    = "job.ping_url"
+	AuditJobAlert      = "job.alert_rule"
+	AuditTokenCreate   = "token.create"
+	AuditTokenRevoke   = "token.revoke"
+	AuditUserCreate    = "user.create"
+	AuditUserRole      = "user.role"
+	AuditUserDelete    = "user.delete"
+	AuditChannelCreate = "channel.create"
+	AuditChannelDelete = "channel.delete"
+	AuditDigestCreate  = "digest.create"
+	AuditDigestDelete  = "digest.delete"
+)
+
+// AuditActions lists every audit action, in display order
+var AuditActions = []string{
+	AuditJobCreate, AuditJobUpdate, AuditJobToggle, AuditJobDelete, AuditJobRun, AuditJobRestore,
+	AuditJobUndelete, AuditJobPurge, AuditJobWebhook, AuditJobPingURL, AuditJobAlert,
+	AuditTokenCreate, AuditTokenRevoke,
+	AuditUserCreate, AuditUserRole, AuditUserDelete,
+	AuditChannelCreate, AuditChannelDelete,
+	AuditDigestCreate, AuditDigestDelete,
+}
+
+// AuditEntry is one record in the audit trail
+type AuditEntry struct {
+	ID         int64         `json:"id"`
+	ActorID    int64         `json:"actor_id,omitempty"`
+	Actor      string        `json:"actor"`
+	Action     string        `json:"action"`
+	TargetType string        `json:"target_type"`
+	TargetID   int64         `json:"target_id,omitempty"`
+	TargetName string        `json:"target_name"`
+	SourceIP   string        `json:"source_ip"`
+	Changes    []FieldChange `json:"changes"`
+	CreatedAt  time.Time     `json:"created_at"`
+}
+
+// FieldChange is a field's value before and after a change. Before is nil
+// for created objects and After is nil for deleted ones.
+type FieldChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditFilter narrows down an audit log query; zero values match everything
+type AuditFilter struct {
+	Actor      string
+	Action     string
+	TargetType string
+	TargetID   int64
+	Since      time.Time
+	Until      time.Time
+	Limit      int
+}
+
+// auditIgnoredJobFields are runtime state rather than configuration
+var auditIgnoredJobFields = map[string]bool{
+	"id":          true,
+	"created_at":  true,
+	"last_run_at": true,
+	"last_status": true,
+	"revision":    true,
+	"deleted_at":  true,
+	"next_run_at": true,
+}
+
+// DiffJobs returns the configuration fields that differ between two versions
+// of a job. Either side may be nil.
+func DiffJobs(before, after *Job) []FieldChange {
+	var bv, av reflect.Value
+	if before != nil {
+		bv = reflect.ValueOf(*before)
+	}
+	if after != nil {
+		av = reflect.ValueOf(*after)
+	}
+
+	changes := []FieldChange{}
+	t := reflect.TypeOf(Job{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" || auditIgnoredJobFields[name] {
+			continue
+		}
+
+		var b, a interface{}
+		if bv.IsValid() {
+			b = auditValue(bv.Field(i).Interface())
+		}
+		if av.IsValid() {
+			a = auditValue(av.Field(i).Interface())
+		}
+		if !reflect.DeepEqual(b, a) {
+			changes = append(changes, FieldChange{Field: name, Before: b, After: a})
+		}
+	}
+
+	return changes
+}
+
+// auditValue unwraps sql.Null* values so they serialize as plain JSON, and
+// spells out durations
+func auditValue(v interface{}) interface{} {
+	if d, ok := v.(time.Duration); ok {
+		return d.String()
+	}
+	if valuer, ok := v.(driver.Valuer); ok {
+		value, err := valuer.Value()
+		if err != nil {
+			return nil
+		}
+		return value
+	}
+	return v
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Digest is a scheduled report on the health of a team's or tag's jobs,
+// sent to a notification channel
+type Digest struct {
+	ID          int64          `json:"id"`
+	Name        string         `json:"name"`
+	Team        string         `json:"team"`
+	Tag         string         `json:"tag"`
+	CronExpr    string         `json:"cron_expr"`
+	Window      string         `json:"window"` // a stats window: 24h, 7d or 30d
+	ChannelID   int64          `json:"channel_id"`
+	ChannelName string         `json:"channel_name"`
+	ChannelType string         `json:"channel_type"`
+	NextRunAt   sql.NullTime   `json:"next_run_at,omitempty"`
+	LastSentAt  sql.NullTime   `json:"last_sent_at,omitempty"`
+	LastError   sql.NullString `json:"last_error,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+}
+
+// Scope describes the jobs the digest covers
+func (d Digest) Scope() string {
+	switch {
+	case d.Team != "" && d.Tag != "":
+		return "team " + d.Team + ", tag " + d.Tag
+	case d.Team != "":
+		return "team " + d.Team
+	case d.Tag != "":
+		return "tag " + d.Tag
+	}
+	return "all jobs"
+}
+
+// Filter returns the job filter selecting the digest's jobs
+func (d Digest) Filter() JobFilter {
+	return JobFilter{Team: d.Team, Tag: d.Tag}
+}
+
+// DigestInput is a submitted digest configuration
+type DigestInput struct {
+	Name      string
+	Team      string
+	Tag       string
+	CronExpr  string
+	Window    string
+	ChannelID int64
+}
+
+// DigestReport summarizes the health of a digest's jobs over its window
+type DigestReport struct {
+	Name        string      `json:"name"`
+	Scope       string      `json:"scope"`
+	Window      string      `json:"window"`
+	From        time.Time   `json:"from"`
+	To          time.Time   `json:"to"`
+	Jobs        int64       `json:"jobs"`
+	Paused      int64       `json:"paused"`
+	Runs        int64       `json:"runs"`
+	Successes   int64       `json:"successes"`
+	Failures    int64       `json:"failures"` // failed and errored runs
+	SuccessRate float64     `json:"success_rate"`
+	Failing     []DigestJob `json:"failing"` // jobs with failures, most first
+	Slowest     []DigestJob `json:"slowest"` // jobs by p95 duration, slowest first
+	NotRun      []DigestJob `json:"not_run"` // active jobs without runs in the window
+}
+
+// DigestJob is a job's line in a digest report
+type DigestJob struct {
+	ID          int64      `json:"id"`
+	Name        string     `json:"name"`
+	Runs        int64      `json:"runs"`
+	Failures    int64      `json:"failures"`
+	SuccessRate float64    `json:"success_rate"`
+	P95Ms       int64      `json:"p95_ms"`
+	MaxMs       int64      `json:"max_ms"`
+	TopError    string     `json:"top_error,omitempty"`
+	LastStatus  string     `json:"last_status,omitempty"`
+	LastRunAt   *time.Time `json:"last_run_at"`
+}
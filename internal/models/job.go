@@ -7,18 +7,53 @@ import (
 
 // Job represents a scheduled HTTP job
 type Job struct {
-	ID         int64          `json:"id"`
-	Name       string         `json:"name"`
-	CronExpr   string         `json:"cron_expr"`
-	URL        string         `json:"url"`
-	Method     string         `json:"method"`
-	Payload    sql.NullString `json:"payload,omitempty"`
-	IsActive   bool           `json:"is_active"`
-	CreatedAt  time.Time      `json:"created_at"`
-	LastRunAt  sql.NullTime   `json:"last_run_at,omitempty"`
-	LastStatus sql.NullString `json:"last_status,omitempty"`
+	ID          int64          `json:"id"`
+	Name        string         `json:"name"`
+	CronExpr    string         `json:"cron_expr"`
+	URL         string         `json:"url"`
+	Method      string         `json:"method"`
+	Payload     sql.NullString `json:"payload,omitempty"`
+	IsActive    bool           `json:"is_active"`
+	CreatedAt   time.Time      `json:"created_at"`
+	LastRunAt   sql.NullTime   `json:"last_run_at,omitempty"`
+	LastStatus  sql.NullString `json:"last_status,omitempty"`
+	Revision    int64          `json:"revision"`
+	DeletedAt   sql.NullTime   `json:"deleted_at,omitempty"`
+	Team        string         `json:"team"`
+	Tags        []string       `json:"tags"`
+	NextRunAt   sql.NullTime   `json:"next_run_at,omitempty"`
+	Webhook     bool           `json:"webhook"`      // has a trigger URL
+	WebhookHMAC bool           `json:"webhook_hmac"` // trigger calls must be signed
+	Kind        string         `json:"kind"`
+	Grace       time.Duration  `json:"grace"`    // how long a heartbeat may be late before it is DOWN
+	PingURL     bool           `json:"ping_url"` // heartbeat jobs only; has a ping URL
 }
 
+// Job kinds
+const (
+	JobKindHTTP      = "http"      // sends a request on its schedule
+	JobKindHeartbeat = "heartbeat" // expects to be pinged on its schedule
+)
+
+// JobKinds lists every job kind, the default first
+var JobKinds = []string{JobKindHTTP, JobKindHeartbeat}
+
+// IsHeartbeat reports whether the job is pinged rather than run
+func (j Job) IsHeartbeat() bool {
+	return j.Kind == JobKindHeartbeat
+}
+
+// Execution triggers
+const (
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+	TriggerWebhook  = "webhook"
+	TriggerPing     = "ping" // a heartbeat job's ping
+)
+
+// Triggers lists every execution trigger, in display order
+var Triggers = []string{TriggerSchedule, TriggerManual, TriggerWebhook, TriggerPing}
+
 // JobLog represents an execution log entry
 type JobLog struct {
 	ID           int64          `json:"id"`
@@ -28,24 +63,230 @@ type JobLog struct {
 	DurationMs   sql.NullInt64  `json:"duration_ms,omitempty"`
 	ResponseBody sql.NullString `json:"response_body,omitempty"`
 	ErrorMessage sql.NullString `json:"error_message,omitempty"`
+	JobRevision  sql.NullInt64  `json:"job_revision,omitempty"`
+	Trigger      string         `json:"trigger"`
+	ActorID      int64          `json:"actor_id,omitempty"` // user who started a manual run
+	Actor        string         `json:"actor"`
+	ScheduledAt  sql.NullTime   `json:"scheduled_at,omitempty"` // cron fire time of scheduled runs
+	StartedAt    sql.NullTime   `json:"started_at,omitempty"`
+	LagMs        sql.NullInt64  `json:"lag_ms,omitempty"`   // from ScheduledAt to StartedAt
+	TraceID      sql.NullString `json:"trace_id,omitempty"` // OpenTelemetry trace of the execution
 	CreatedAt    time.Time      `json:"created_at"`
 }
 
+// JobLogFilter narrows down a job's execution history; zero values match
+// everything
+type JobLogFilter struct {
+	Trigger string
+	Actor   string
+	MinLag  time.Duration
+	Limit   int
+}
+
+// JobRevision is a saved version of a job's configuration
+type JobRevision struct {
+	JobID     int64          `json:"job_id"`
+	Revision  int64          `json:"revision"`
+	Name      string         `json:"name"`
+	CronExpr  string         `json:"cron_expr"`
+	URL       string         `json:"url"`
+	Method    string         `json:"method"`
+	Payload   sql.NullString `json:"payload,omitempty"`
+	Team      string         `json:"team"`
+	Tags      []string       `json:"tags"`
+	Grace     time.Duration  `json:"grace"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// Job returns the revision's configuration as a job, for diffing
+func (r JobRevision) Job() *Job {
+	return &Job{
+		ID:       r.JobID,
+		Name:     r.Name,
+		CronExpr: r.CronExpr,
+		URL:      r.URL,
+		Method:   r.Method,
+		Payload:  r.Payload,
+		Team:     r.Team,
+		Tags:     r.Tags,
+		Grace:    r.Grace,
+		Revision: r.Revision,
+	}
+}
+
 // CreateJobParams represents parameters for creating a new job
 type CreateJobParams struct {
+	Name          string
+	CronExpr      string
+	URL           string
+	Method        string
+	Payload       sql.NullString
+	Team          string
+	Tags          []string
+	Disabled      bool
+	Kind          string
+	Grace         time.Duration
+	PingTokenHash string // heartbeat jobs only; the token itself is not stored
+}
+
+// UpdateJobParams represents parameters for updating a job
+type UpdateJobParams struct {
+	ID       int64
 	Name     string
 	CronExpr string
 	URL      string
 	Method   string
 	Payload  sql.NullString
+	Team     string
+	Tags     []string
+	Grace    time.Duration
 }
 
-// UpdateJobParams represents parameters for updating a job
-type UpdateJobParams struct {
-	ID       int64
+// JobInput is a job configuration as submitted through the web form or the
+// API, before validation
+type JobInput struct {
+	Kind     string
 	Name     string
 	CronExpr string
 	URL      string
 	Method   string
-	Payload  sql.NullString
+	Payload  string
+	Team     string
+	Tags     []string
+	Grace    time.Duration
+}
+
+// payload returns the payload as stored, NULL when empty
+func (in JobInput) payload() sql.NullString {
+	if in.Payload == "" {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: in.Payload, Valid: true}
+}
+
+// CreateParams returns the parameters for creating a job from the input
+func (in JobInput) CreateParams() CreateJobParams {
+	return CreateJobParams{
+		Name:     in.Name,
+		CronExpr: in.CronExpr,
+		URL:      in.URL,
+		Method:   in.Method,
+		Payload:  in.payload(),
+		Team:     in.Team,
+		Tags:     in.Tags,
+		Kind:     in.Kind,
+		Grace:    in.Grace,
+	}
+}
+
+// UpdateParams returns the parameters for updating job id from the input
+func (in JobInput) UpdateParams(id int64) UpdateJobParams {
+	return UpdateJobParams{
+		ID:       id,
+		Name:     in.Name,
+		CronExpr: in.CronExpr,
+		URL:      in.URL,
+		Method:   in.Method,
+		Payload:  in.payload(),
+		Team:     in.Team,
+		Tags:     in.Tags,
+		Grace:    in.Grace,
+	}
+}
+
+// Job returns an unsaved job carrying the input, for redisplaying a form
+func (in JobInput) Job() *Job {
+	return &Job{
+		Name:     in.Name,
+		CronExpr: in.CronExpr,
+		URL:      in.URL,
+		Method:   in.Method,
+		Payload:  in.payload(),
+		Team:     in.Team,
+		Tags:     in.Tags,
+		Kind:     in.Kind,
+		Grace:    in.Grace,
+		IsActive: true,
+	}
+}
+
+// JobFilter narrows a job listing; empty fields match every job
+type JobFilter struct {
+	Tag  string
+	Team string
+	// Search matches part of a job's name or URL, or one of its tags
+	Search string
+}
+
+// Sort keys for job listings
+const (
+	JobSortCreated = "created"
+	JobSortName    = "name"
+	JobSortNextRun = "next_run"
+	JobSortLastRun = "last_run"
+	JobSortStatus  = "status"
+)
+
+// JobSorts lists the valid sort keys, the default first
+var JobSorts = []string{JobSortCreated, JobSortName, JobSortNextRun, JobSortLastRun, JobSortStatus}
+
+// JobListOptions selects one page of a filtered, sorted job listing
+type JobListOptions struct {
+	Filter  JobFilter
+	Sort    string
+	Desc    bool
+	Page    int
+	PerPage int
+}
+
+// JobPage is one page of a job listing
+type JobPage struct {
+	Jobs    []Job
+	Total   int
+	Page    int
+	PerPage int
+}
+
+// Pages returns the number of pages in the listing, at least one
+func (p JobPage) Pages() int {
+	if p.Total == 0 || p.PerPage <= 0 {
+		return 1
+	}
+	return (p.Total + p.PerPage - 1) / p.PerPage
+}
+
+// First returns the 1-based position of the page's first job
+func (p JobPage) First() int {
+	if p.Total == 0 {
+		return 0
+	}
+	return (p.Page-1)*p.PerPage + 1
+}
+
+// Last returns the 1-based position of the page's last job
+func (p JobPage) Last() int {
+	return p.First() + len(p.Jobs) - 1
+}
+
+// Actions that can be applied to a selection of jobs at once
+const (
+	BulkEnable   = "enable"
+	BulkDisable  = "disable"
+	BulkDelete   = "delete"
+	BulkRun      = "run"
+	BulkTag      = "tag"
+	BulkRetarget = "retarget"
+)
+
+// BulkActions lists the valid bulk actions
+var BulkActions = []string{BulkEnable, BulkDisable, BulkDelete, BulkRun, BulkTag, BulkRetarget}
+
+// BulkJobParams describes an action applied to several jobs at once
+type BulkJobParams struct {
+	IDs    []int64
+	Action string
+	// Tags are added to every job by BulkTag
+	Tags []string
+	// Host replaces the host, and port, of every job's URL for BulkRetarget
+	Host string
 }
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+)
+
+// Notification channel types
+const (
+	ChannelEmail   = "email"   // target is a comma-separated list of addresses
+	ChannelSlack   = "slack"   // target is a Slack-compatible incoming webhook URL
+	ChannelWebhook = "webhook" // target is a URL that is POSTed the alert as JSON
+)
+
+// ChannelTypes lists every notification channel type, in display order
+var ChannelTypes = []string{ChannelEmail, ChannelSlack, ChannelWebhook}
+
+// NotificationChannel is somewhere alerts are sent
+type NotificationChannel struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	Target    string    `json:"-"` // may embed credentials, so only admins see it
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Alert rule events
+const (
+	AlertOnFailure     = "failure"              // the first failure of an incident
+	AlertOnConsecutive = "consecutive_failures" // Threshold failures in a row
+	AlertOnRecovery    = "recovery"             // the success that ends an incident
+	AlertOnSlow        = "slow"                 // a run longer than Threshold ms
+)
+
+// AlertEvents lists every alert rule event, in display order
+var AlertEvents = []string{AlertOnFailure, AlertOnConsecutive, AlertOnRecovery, AlertOnSlow}
+
+// AlertRule sends a job's alerts about an event to a channel
+type AlertRule struct {
+	ID          int64     `json:"id"`
+	JobID       int64     `json:"job_id"`
+	ChannelID   int64     `json:"channel_id"`
+	ChannelName string    `json:"channel_name"`
+	ChannelType string    `json:"channel_type"`
+	Event       string    `json:"event"`
+	Threshold   int64     `json:"threshold"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Condition describes when the rule alerts
+func (r AlertRule) Condition() string {
+	switch r.Event {
+	case AlertOnFailure:
+		return "on failure"
+	case AlertOnConsecutive:
+		return "after " + strconv.FormatInt(r.Threshold, 10) + " consecutive failures"
+	case AlertOnRecovery:
+		return "on recovery"
+	case AlertOnSlow:
+		return "when a run takes over " + strconv.FormatInt(r.Threshold, 10) + "ms"
+	}
+	return r.Event
+}
+
+// String describes the rule for the audit log
+func (r AlertRule) String() string {
+	return r.Condition() + " → " + r.ChannelName
+}
+
+// Incident is a run of failures of a job, open until the job next succeeds
+type Incident struct {
+	ID         int64        `json:"id"`
+	JobID      int64        `json:"job_id"`
+	Failures   int64        `json:"failures"`
+	OpenedAt   time.Time    `json:"opened_at"`
+	ResolvedAt sql.NullTime `json:"resolved_at,omitempty"`
+}
+
+// Alert is a notification raised by a rule
+type Alert struct {
+	ID          int64                 `json:"id"`
+	RuleID      int64                 `json:"rule_id"`
+	JobID       int64                 `json:"job_id"`
+	ChannelName string                `json:"channel_name"`
+	Event       string                `json:"event"`
+	DedupKey    string                `json:"dedup_key"`
+	Message     string                `json:"message"`
+	CreatedAt   time.Time             `json:"created_at"`
+	Attempts    []NotificationAttempt `json:"attempts"`
+}
+
+// Delivered reports whether any attempt to send the alert succeeded
+func (a Alert) Delivered() bool {
+	for _, attempt := range a.Attempts {
+		if attempt.Status == AttemptSent {
+			return true
+		}
+	}
+	return false
+}
+
+// Notification attempt statuses
+const (
+	AttemptSent   = "sent"
+	AttemptFailed = "failed"
+)
+
+// NotificationAttempt is one try at delivering an alert
+type NotificationAttempt struct {
+	ID        int64          `json:"id"`
+	AlertID   int64          `json:"alert_id"`
+	Attempt   int            `json:"attempt"`
+	Status    string         `json:"status"`
+	Error     sql.NullString `json:"error,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+}
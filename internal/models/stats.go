@@ -0,0 +1,24 @@
+package models
+
+// JobStats holds computed execution statistics for a job over a time window
+type JobStats struct {
+	JobID          int64          `json:"job_id"`
+	Window         string         `json:"window"`
+	Runs           int64          `json:"runs"`
+	Successes      int64          `json:"successes"`
+	Failures       int64          `json:"failures"`
+	Errors         int64          `json:"errors"`
+	SuccessRate    float64        `json:"success_rate"`
+	P50Ms          int64          `json:"p50_ms"`
+	P95Ms          int64          `json:"p95_ms"`
+	P99Ms          int64          `json:"p99_ms"`
+	MaxMs          int64          `json:"max_ms"`
+	TopErrors      []OutcomeCount `json:"top_errors"`
+	TopStatusCodes []OutcomeCount `json:"top_status_codes"`
+}
+
+// OutcomeCount is the number of times an error message or status code was seen
+type OutcomeCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
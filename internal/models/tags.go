@@ -0,0 +1,40 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MaxTagLength is the longest tag a job may carry
+const MaxTagLength = 32
+
+// NormalizeTags lowercases, deduplicates and sorts tags, dropping empty ones.
+// Tags may contain letters, digits, '-', '_', '.' and ':'.
+func NormalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var normalized []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if len(tag) > MaxTagLength {
+			return nil, fmt.Errorf("tag %q is longer than %d characters", tag, MaxTagLength)
+		}
+		for _, c := range tag {
+			if !validTagChar(c) {
+				return nil, fmt.Errorf("tag %q may only contain letters, digits, '-', '_', '.' and ':'", tag)
+			}
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+func validTagChar(c rune) bool {
+	return (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || strings.ContainsRune("-_.:", c)
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// APIToken represents a hashed API token and its granted scopes
+type APIToken struct {
+	ID         int64        `json:"id"`
+	UserID     int64        `json:"user_id"`
+	Name       string       `json:"name"`
+	TokenHash  string       `json:"-"`
+	Prefix     string       `json:"prefix"`
+	Scopes     []string     `json:"scopes"`
+	ExpiresAt  sql.NullTime `json:"expires_at,omitempty"`
+	LastUsedAt sql.NullTime `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time    `json:"created_at"`
+}
+
+// Expired reports whether the token has passed its expiry time
+func (t APIToken) Expired() bool {
+	return t.ExpiresAt.Valid && time.Now().After(t.ExpiresAt.Time)
+}
+
+// CreateAPITokenParams represents parameters for creating an API token
+type CreateAPITokenParams struct {
+	UserID    int64
+	Name      string
+	TokenHash string
+	Prefix    string
+	Scopes    []string
+	ExpiresAt sql.NullTime
+}
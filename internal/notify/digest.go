@@ -0,0 +1,248 @@
+package notify
+
+import (
+	"bytes"
+	"database/sql"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/rauche/cronnor/internal/jobs"
+	"github.com/rauche/cronnor/internal/models"
+	"github.com/rauche/cronnor/internal/storage"
+)
+
+// Digest report sections are capped so a large team's digest stays readable
+const (
+	maxFailing = 10
+	maxSlowest = 5
+	maxNotRun  = 20
+)
+
+//go:embed templates
+var digestTemplates embed.FS
+
+var digestFuncs = map[string]interface{}{
+	"plural":  plural,
+	"percent": func(v float64) string { return fmt.Sprintf("%.1f%%", v) },
+	"date":    func(t time.Time) string { return t.UTC().Format("Mon Jan 2 15:04 MST") },
+	"lastRun": func(t *time.Time) string {
+		if t == nil {
+			return "never"
+		}
+		return t.UTC().Format("Jan 2 15:04 MST")
+	},
+}
+
+var (
+	digestHTML = htmltemplate.Must(htmltemplate.New("digest.html").Funcs(digestFuncs).ParseFS(digestTemplates, "templates/digest.html"))
+	digestText = texttemplate.Must(texttemplate.New("digest.txt").Funcs(digestFuncs).ParseFS(digestTemplates, "templates/digest.txt"))
+)
+
+// DigestWindows lists the windows a digest may report on
+var DigestWindows = storage.StatsWindows
+
+// NextDigestRun returns when a digest with the given schedule is next due
+func NextDigestRun(cronExpr string, after time.Time) (time.Time, error) {
+	schedule, err := jobs.ParseCronExpr(cronExpr)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return schedule.Next(after), nil
+}
+
+// BuildDigest reports on the health of a digest's jobs over its window.
+// Stats always cover the window up to the present; now dates the report.
+func BuildDigest(repo *storage.Repository, d models.Digest, now time.Time) (*models.DigestReport, error) {
+	list, err := repo.GetAllJobs(d.Filter())
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.DigestReport{
+		Name:    d.Name,
+		Scope:   d.Scope(),
+		Window:  d.Window,
+		From:    now.Add(-storage.StatsWindowDuration(d.Window)),
+		To:      now,
+		Jobs:    int64(len(list)),
+		Failing: []models.DigestJob{},
+		Slowest: []models.DigestJob{},
+		NotRun:  []models.DigestJob{},
+	}
+
+	for _, job := range list {
+		stats, err := repo.GetJobStats(job.ID, d.Window)
+		if err != nil {
+			return nil, err
+		}
+
+		line := models.DigestJob{
+			ID:          job.ID,
+			Name:        job.Name,
+			Runs:        stats.Runs,
+			Failures:    stats.Failures + stats.Errors,
+			SuccessRate: stats.SuccessRate,
+			P95Ms:       stats.P95Ms,
+			MaxMs:       stats.MaxMs,
+			LastStatus:  job.LastStatus.String,
+		}
+		if job.LastRunAt.Valid {
+			line.LastRunAt = &job.LastRunAt.Time
+		}
+		line.TopError = topFailure(stats)
+
+		report.Runs += line.Runs
+		report.Successes += stats.Successes
+		report.Failures += line.Failures
+		if !job.IsActive {
+			report.Paused++
+		}
+
+		switch {
+		case line.Runs == 0 && job.IsActive:
+			report.NotRun = append(report.NotRun, line)
+		case line.Runs > 0:
+			report.Slowest = append(report.Slowest, line)
+			if line.Failures > 0 {
+				report.Failing = append(report.Failing, line)
+			}
+		}
+	}
+	if report.Runs > 0 {
+		report.SuccessRate = float64(report.Successes) / float64(report.Runs) * 100
+	}
+
+	sort.SliceStable(report.Failing, func(i, j int) bool { return report.Failing[i].Failures > report.Failing[j].Failures })
+	sort.SliceStable(report.Slowest, func(i, j int) bool { return report.Slowest[i].P95Ms > report.Slowest[j].P95Ms })
+	report.Failing = truncate(report.Failing, maxFailing)
+	report.Slowest = truncate(report.Slowest, maxSlowest)
+	report.NotRun = truncate(report.NotRun, maxNotRun)
+
+	return report, nil
+}
+
+// topFailure describes the most frequent reason a job's runs failed: an
+// error message or, failing that, an unsuccessful HTTP status
+func topFailure(stats *models.JobStats) string {
+	if len(stats.TopErrors) > 0 {
+		return stats.TopErrors[0].Value
+	}
+	for _, code := range stats.TopStatusCodes {
+		if !strings.HasPrefix(code.Value, "2") {
+			return "HTTP " + code.Value
+		}
+	}
+	return ""
+}
+
+func truncate(lines []models.DigestJob, n int) []models.DigestJob {
+	if len(lines) > n {
+		return lines[:n]
+	}
+	return lines
+}
+
+// RenderDigest renders a digest report as plain text and as HTML
+func RenderDigest(report *models.DigestReport) (string, string, error) {
+	var text, html bytes.Buffer
+	if err := digestText.Execute(&text, report); err != nil {
+		return "", "", fmt.Errorf("failed to render digest: %w", err)
+	}
+	if err := digestHTML.Execute(&html, report); err != nil {
+		return "", "", fmt.Errorf("failed to render digest: %w", err)
+	}
+	return text.String(), html.String(), nil
+}
+
+// digestSubject is the email subject of a digest report
+func digestSubject(report *models.DigestReport) string {
+	subject := fmt.Sprintf("%s: %.1f%% success", report.Name, report.SuccessRate)
+	if report.Runs == 0 {
+		subject = report.Name + ": no runs"
+	}
+	if n := len(report.Failing); n > 0 {
+		subject += fmt.Sprintf(", %s failing", plural(int64(n), "job"))
+	}
+	return subject
+}
+
+// SendDueDigests sends every digest due by now in the background and
+// schedules its next run
+func (n *Notifier) SendDueDigests(now time.Time) error {
+	digests, err := n.repo.GetDigests()
+	if err != nil {
+		return err
+	}
+
+	for _, d := range digests {
+		// Compared here rather than in SQL, where stored times don't compare
+		// reliably with query parameters
+		if !d.NextRunAt.Valid || d.NextRunAt.Time.After(now) {
+			continue
+		}
+
+		next, err := NextDigestRun(d.CronExpr, now)
+		if err != nil {
+			log.Printf("Warning: digest %d has an invalid schedule: %v", d.ID, err)
+			continue
+		}
+		if err := n.repo.SetDigestNextRun(d.ID, next); err != nil {
+			return err
+		}
+
+		n.sendDigest(d, now)
+	}
+
+	return nil
+}
+
+// sendDigest builds and delivers a digest in the background, retrying with
+// backoff, and records the outcome
+func (n *Notifier) sendDigest(d models.Digest, now time.Time) {
+	n.deliveries.Add(1)
+	go func() {
+		defer n.deliveries.Done()
+
+		err := n.deliverDigest(d, now)
+		var sendErr sql.NullString
+		if err != nil {
+			log.Printf("Warning: failed to send digest %q: %v", d.Name, err)
+			sendErr = sql.NullString{String: err.Error(), Valid: true}
+		}
+		if err := n.repo.RecordDigestSent(d.ID, now, sendErr); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}()
+}
+
+func (n *Notifier) deliverDigest(d models.Digest, now time.Time) error {
+	report, err := BuildDigest(n.repo, d, now)
+	if err != nil {
+		return err
+	}
+	text, html, err := RenderDigest(report)
+	if err != nil {
+		return err
+	}
+
+	channel, err := n.repo.GetChannel(d.ChannelID)
+	if err != nil {
+		return err
+	}
+
+	delay := n.cfg.Backoff
+	for attempt := 1; ; attempt++ {
+		err = n.sendReport(*channel, report, text, html)
+		if err == nil || attempt == n.cfg.Attempts {
+			return err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
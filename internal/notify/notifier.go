@@ -0,0 +1,282 @@
+package notify
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rauche/cronnor/internal/events"
+	"github.com/rauche/cronnor/internal/models"
+	"github.com/rauche/cronnor/internal/storage"
+)
+
+// Config configures how alerts are delivered
+type Config struct {
+	SMTP     SMTPConfig
+	Attempts int           // delivery attempts per alert; defaults to 3
+	Backoff  time.Duration // wait before retrying, doubled after each attempt; defaults to 5s
+
+	// DigestInterval is how often due digests are looked for; defaults to a minute
+	DigestInterval time.Duration
+}
+
+// Message is an alert as delivered. Webhook channels are POSTed it as JSON.
+type Message struct {
+	AlertID int64     `json:"alert_id"`
+	Event   string    `json:"event"`
+	JobID   int64     `json:"job_id"`
+	JobName string    `json:"job_name"`
+	Status  string    `json:"status"`
+	Text    string    `json:"text"`
+	Time    time.Time `json:"time"`
+}
+
+// Notifier raises alerts from job executions according to each job's rules,
+// sends digests on their schedules, and delivers both to notification channels
+type Notifier struct {
+	repo   *storage.Repository
+	cfg    Config
+	client *http.Client
+
+	quit       chan struct{}
+	loops      sync.WaitGroup
+	deliveries sync.WaitGroup
+}
+
+// New creates a notifier. Register HandleOutcome with the scheduler to have
+// it follow executions, and call Start to have it send digests.
+func New(repo *storage.Repository, cfg Config) *Notifier {
+	if cfg.Attempts <= 0 {
+		cfg.Attempts = 3
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = 5 * time.Second
+	}
+	if cfg.DigestInterval <= 0 {
+		cfg.DigestInterval = time.Minute
+	}
+
+	return &Notifier{
+		repo:   repo,
+		cfg:    cfg,
+		client: &http.Client{Timeout: sendTimeout},
+	}
+}
+
+// HandleOutcome evaluates alert rules for a logged execution or ping, or for
+// a heartbeat job going DOWN. It is meant for jobs.Scheduler.OnOutcome,
+// which calls it once the outcome's log entry is written.
+func (n *Notifier) HandleOutcome(e events.Event) {
+	if err := n.handle(e); err != nil {
+		log.Printf("Warning: failed to evaluate alert rules for job %d: %v", e.JobID, err)
+	}
+}
+
+// Start sends digests when due, until Stop is called
+func (n *Notifier) Start() {
+	n.quit = make(chan struct{})
+	n.loops.Add(1)
+
+	go func() {
+		defer n.loops.Done()
+		ticker := time.NewTicker(n.cfg.DigestInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				if err := n.SendDueDigests(now); err != nil {
+					log.Printf("Warning: failed to send digests: %v", err)
+				}
+			case <-n.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops sending digests and waits for deliveries in progress
+func (n *Notifier) Stop() {
+	if n.quit != nil {
+		close(n.quit)
+		n.loops.Wait()
+		n.quit = nil
+	}
+	n.deliveries.Wait()
+}
+
+// handle raises the alerts an event calls for
+func (n *Notifier) handle(e events.Event) error {
+	// The event's status is the outcome; the log entry that recorded it
+	// only adds detail to the message
+	var entry, prev *models.JobLog
+	switch {
+	case e.Type == events.ExecutionFinished:
+		var err error
+		if entry, prev, err = n.repo.GetJobOutcome(e.JobID, e.LogID); err != nil {
+			return err
+		}
+	case e.Type == events.JobChanged && e.Status == "DOWN":
+		// A missed heartbeat has no execution, but counts as a failure
+	default:
+		return nil
+	}
+
+	job, err := n.repo.GetJob(e.JobID)
+	if err != nil {
+		return err
+	}
+
+	status := e.Status
+	failed := status != "SUCCESS"
+
+	// Incidents are tracked even without rules, so rules added later see
+	// failures that are already under way
+	incident, err := n.repo.RecordOutcome(job.ID, failed)
+	if err != nil {
+		return err
+	}
+
+	rules, err := n.repo.GetAlertRules(job.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, rule := range rules {
+		key, text := check(rule, *job, entry, prev, incident, failed)
+		if key == "" {
+			continue
+		}
+
+		alert := models.Alert{
+			RuleID:      rule.ID,
+			JobID:       job.ID,
+			ChannelName: rule.ChannelName,
+			Event:       rule.Event,
+			DedupKey:    key,
+			Message:     text,
+		}
+		id, created, err := n.repo.CreateAlert(alert)
+		if err != nil {
+			return err
+		}
+		if !created {
+			continue
+		}
+
+		n.deliver(rule.ChannelID, Message{
+			AlertID: id,
+			Event:   rule.Event,
+			JobID:   job.ID,
+			JobName: job.Name,
+			Status:  status,
+			Text:    text,
+			Time:    time.Now().UTC(),
+		})
+	}
+
+	return nil
+}
+
+// check returns the dedup key and text of the alert rule raises for an
+// execution, given the one before it, or an empty key if it raises none.
+// Failure alerts are keyed by incident so each is raised once per incident.
+func check(rule models.AlertRule, job models.Job, entry, prev *models.JobLog, incident *models.Incident, failed bool) (string, string) {
+	switch rule.Event {
+	case models.AlertOnFailure:
+		if failed && incident != nil {
+			return incidentKey(incident), fmt.Sprintf("%s failed: %s", job.Name, describeFailure(entry))
+		}
+	case models.AlertOnConsecutive:
+		if failed && incident != nil && incident.Failures >= rule.Threshold {
+			return incidentKey(incident), fmt.Sprintf("%s failed %d times in a row: %s", job.Name, incident.Failures, describeFailure(entry))
+		}
+	case models.AlertOnRecovery:
+		if !failed && incident != nil {
+			return incidentKey(incident), fmt.Sprintf("%s recovered after %s", job.Name, plural(incident.Failures, "failure"))
+		}
+	case models.AlertOnSlow:
+		if entry == nil || !slow(entry, rule.Threshold) {
+			return "", ""
+		}
+		// Only the first of several slow runs in a row alerts
+		if prev != nil && slow(prev, rule.Threshold) {
+			return "", ""
+		}
+		return "log:" + strconv.FormatInt(entry.ID, 10),
+			fmt.Sprintf("%s took %dms, over its %dms threshold", job.Name, entry.DurationMs.Int64, rule.Threshold)
+	}
+	return "", ""
+}
+
+// deliver sends a message to a channel in the background, retrying with
+// backoff, and records every attempt
+func (n *Notifier) deliver(channelID int64, msg Message) {
+	n.deliveries.Add(1)
+	go func() {
+		defer n.deliveries.Done()
+
+		channel, err := n.repo.GetChannel(channelID)
+		if err != nil {
+			log.Printf("Warning: failed to deliver alert %d: %v", msg.AlertID, err)
+			return
+		}
+
+		delay := n.cfg.Backoff
+		for attempt := 1; attempt <= n.cfg.Attempts; attempt++ {
+			sendErr := n.send(*channel, msg)
+
+			record := models.NotificationAttempt{AlertID: msg.AlertID, Attempt: attempt, Status: models.AttemptSent}
+			if sendErr != nil {
+				record.Status = models.AttemptFailed
+				record.Error = sql.NullString{String: sendErr.Error(), Valid: true}
+			}
+			if err := n.repo.CreateNotificationAttempt(record); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+
+			if sendErr == nil {
+				return
+			}
+			log.Printf("Warning: failed to send alert %d to %s (attempt %d): %v", msg.AlertID, channel.Name, attempt, sendErr)
+			if attempt < n.cfg.Attempts {
+				time.Sleep(delay)
+				delay *= 2
+			}
+		}
+	}()
+}
+
+func incidentKey(incident *models.Incident) string {
+	return "incident:" + strconv.FormatInt(incident.ID, 10)
+}
+
+func slow(entry *models.JobLog, thresholdMs int64) bool {
+	return entry.DurationMs.Valid && entry.DurationMs.Int64 > thresholdMs
+}
+
+// describeFailure summarizes why an execution failed; entry is nil for a
+// heartbeat job that missed its ping
+func describeFailure(entry *models.JobLog) string {
+	switch {
+	case entry == nil:
+		return "no ping was received in time"
+	case entry.ErrorMessage.Valid:
+		return entry.ErrorMessage.String
+	case entry.HTTPCode.Valid:
+		return "HTTP " + strconv.FormatInt(entry.HTTPCode.Int64, 10)
+	case entry.Trigger == models.TriggerPing:
+		return "a fail ping was received"
+	}
+	return entry.Status
+}
+
+func plural(n int64, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return strconv.FormatInt(n, 10) + " " + noun + "s"
+}
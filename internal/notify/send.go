@@ -0,0 +1,197 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rauche/cronnor/internal/models"
+)
+
+// sendTimeout bounds a single delivery attempt
+const sendTimeout = 10 * time.Second
+
+// SMTPConfig is the mail server email channels send through
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// send delivers a message to a channel once
+func (n *Notifier) send(channel models.NotificationChannel, msg Message) error {
+	switch channel.Type {
+	case models.ChannelEmail:
+		body := fmt.Sprintf("%s\n\nJob: %s (#%d)\nStatus: %s\nEvent: %s\n", msg.Text, msg.JobName, msg.JobID, msg.Status, msg.Event)
+		return n.sendMail(channel.Target, msg.Text, body, "", msg.Time)
+	case models.ChannelSlack:
+		return n.post(channel.Target, map[string]string{"text": msg.Text})
+	case models.ChannelWebhook:
+		return n.post(channel.Target, msg)
+	}
+	return fmt.Errorf("unknown channel type %q", channel.Type)
+}
+
+// post POSTs payload as JSON and expects a 2xx response
+func (n *Notifier) post(url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Cronnor/1.0")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+	return nil
+}
+
+// sendMail mails a plain text body, with an optional HTML alternative, to a
+// comma-separated list of addresses, upgrading to TLS when the server offers it
+func (n *Notifier) sendMail(to, subject, text, html string, date time.Time) error {
+	cfg := n.cfg.SMTP
+	if cfg.Host == "" {
+		return errors.New("SMTP_HOST is not configured")
+	}
+
+	var recipients []string
+	for _, addr := range strings.Split(to, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			recipients = append(recipients, addr)
+		}
+	}
+
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	conn, err := net.DialTimeout("tcp", addr, sendTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	conn.SetDeadline(time.Now().Add(sendTimeout))
+
+	c, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: cfg.Host}); err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+	if cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)); err != nil {
+			return fmt.Errorf("failed to authenticate: %w", err)
+		}
+	}
+
+	if err := c.Mail(cfg.From); err != nil {
+		return fmt.Errorf("MAIL FROM rejected: %w", err)
+	}
+	for _, rcpt := range recipients {
+		if err := c.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("RCPT TO %s rejected: %w", rcpt, err)
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("DATA rejected: %w", err)
+	}
+
+	// The subject comes from user input, so keep it to one header line
+	subject = strings.NewReplacer("\r", " ", "\n", " ").Replace(subject)
+	fmt.Fprintf(w, "From: %s\r\n", cfg.From)
+	fmt.Fprintf(w, "To: %s\r\n", strings.Join(recipients, ", "))
+	fmt.Fprintf(w, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "[Cronnor] "+subject))
+	fmt.Fprintf(w, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	fmt.Fprintf(w, "MIME-Version: 1.0\r\n")
+	if err := writeMailBody(w, text, html); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("message rejected: %w", err)
+	}
+	return c.Quit()
+}
+
+// writeMailBody writes the Content-Type header and body of a message: plain
+// text, or multipart/alternative when there is an HTML version too
+func writeMailBody(w io.Writer, text, html string) error {
+	if html == "" {
+		fmt.Fprintf(w, "Content-Type: text/plain; charset=utf-8\r\n")
+		fmt.Fprintf(w, "Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		return writeQuotedPrintable(w, text)
+	}
+
+	parts := multipart.NewWriter(w)
+	fmt.Fprintf(w, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", parts.Boundary())
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", html},
+	} {
+		pw, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return err
+		}
+		if err := writeQuotedPrintable(pw, part.body); err != nil {
+			return err
+		}
+	}
+	return parts.Close()
+}
+
+func writeQuotedPrintable(w io.Writer, body string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := io.WriteString(qp, body); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// sendReport delivers a digest report to a channel once. Email gets the
+// HTML version too, and webhooks the report itself.
+func (n *Notifier) sendReport(channel models.NotificationChannel, report *models.DigestReport, text, html string) error {
+	switch channel.Type {
+	case models.ChannelEmail:
+		return n.sendMail(channel.Target, digestSubject(report), text, html, report.To)
+	case models.ChannelSlack:
+		return n.post(channel.Target, map[string]string{"text": text})
+	case models.ChannelWebhook:
+		return n.post(channel.Target, map[string]interface{}{"event": "digest", "text": text, "report": report})
+	}
+	return fmt.Errorf("unknown channel type %q", channel.Type)
+}
@@ -0,0 +1,113 @@
+package notify
+
+import (
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rauche/cronnor/internal/jobs"
+	"github.com/rauche/cronnor/internal/models"
+	"github.com/rauche/cronnor/internal/storage"
+)
+
+// MaxChannelNameLength bounds the length of a channel name
+const MaxChannelNameLength = 100
+
+// MaxConsecutiveFailures bounds the threshold of consecutive failure rules
+const MaxConsecutiveFailures = 1000
+
+// ValidateChannel checks a notification channel and returns a message for
+// each invalid field, keyed by its form and JSON field name
+func ValidateChannel(name, channelType, target string) map[string]string {
+	fields := make(map[string]string)
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		fields["name"] = "is required"
+	} else if utf8.RuneCountInString(name) > MaxChannelNameLength {
+		fields["name"] = "must be at most " + strconv.Itoa(MaxChannelNameLength) + " characters"
+	}
+
+	target = strings.TrimSpace(target)
+	switch channelType {
+	case models.ChannelEmail:
+		if target == "" {
+			fields["target"] = "is required"
+			break
+		}
+		for _, addr := range strings.Split(target, ",") {
+			if _, err := mail.ParseAddress(strings.TrimSpace(addr)); err != nil {
+				fields["target"] = "must be a comma-separated list of email addresses"
+				break
+			}
+		}
+	case models.ChannelSlack, models.ChannelWebhook:
+		u, err := url.Parse(target)
+		if target == "" {
+			fields["target"] = "is required"
+		} else if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fields["target"] = "must be an http or https URL"
+		}
+	default:
+		fields["type"] = "must be one of " + strings.Join(models.ChannelTypes, ", ")
+	}
+
+	return fields
+}
+
+// ValidateRule checks an alert rule, clearing the threshold of events that
+// take none, and returns a message for each invalid field
+func ValidateRule(event string, threshold *int64) map[string]string {
+	fields := make(map[string]string)
+
+	switch event {
+	case models.AlertOnConsecutive:
+		if *threshold < 2 || *threshold > MaxConsecutiveFailures {
+			fields["threshold"] = "must be between 2 and " + strconv.Itoa(MaxConsecutiveFailures) + " failures"
+		}
+	case models.AlertOnSlow:
+		if *threshold < 1 {
+			fields["threshold"] = "must be at least 1 ms"
+		}
+	case models.AlertOnFailure, models.AlertOnRecovery:
+		*threshold = 0
+	default:
+		fields["event"] = "must be one of " + strings.Join(models.AlertEvents, ", ")
+	}
+
+	return fields
+}
+
+// ValidateDigest normalizes a submitted digest configuration and returns a
+// message for each invalid field
+func ValidateDigest(in *models.DigestInput) map[string]string {
+	fields := make(map[string]string)
+
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" {
+		fields["name"] = "is required"
+	} else if utf8.RuneCountInString(in.Name) > MaxChannelNameLength {
+		fields["name"] = "must be at most " + strconv.Itoa(MaxChannelNameLength) + " characters"
+	}
+
+	in.Team = strings.TrimSpace(in.Team)
+	in.Tag = strings.ToLower(strings.TrimSpace(in.Tag))
+
+	in.CronExpr = strings.TrimSpace(in.CronExpr)
+	if in.CronExpr == "" {
+		fields["cron_expr"] = "is required"
+	} else if _, err := jobs.ParseCronExpr(in.CronExpr); err != nil {
+		fields["cron_expr"] = "is not a valid cron expression: " + err.Error()
+	}
+
+	if in.Window == "" {
+		in.Window = "7d"
+	}
+	if !storage.ValidStatsWindow(in.Window) {
+		fields["window"] = "must be one of " + strings.Join(storage.StatsWindows, ", ")
+	}
+
+	return fields
+}
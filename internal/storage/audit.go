@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/rauche/cronnor/internal/models"
+)
+
+// CreateAuditEntry appends an entry to the audit log
+func (r *Repository) CreateAuditEntry(entry models.AuditEntry) error {
+	if entry.Changes == nil {
+		entry.Changes = []models.FieldChange{}
+	}
+	changes, err := json.Marshal(entry.Changes)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit changes: %w", err)
+	}
+
+	query := `
+		INSERT INTO audit_log (actor_id, actor, action, target_type, target_id, target_name, source_ip, changes)
+		VALUES (NULLIF(?, 0), ?, ?, ?, NULLIF(?, 0), ?, ?, ?)
+	`
+
+	_, err = r.db.Exec(query, entry.ActorID, entry.Actor, entry.Action, entry.TargetType,
+		entry.TargetID, entry.TargetName, entry.SourceIP, string(changes))
+	if err != nil {
+		return fmt.Errorf("failed to create audit entry: %w", err)
+	}
+
+	return nil
+}
+
+// GetAuditEntries retrieves audit log entries matching a filter, newest first
+func (r *Repository) GetAuditEntries(filter models.AuditFilter) ([]models.AuditEntry, error) {
+	var where []string
+	var args []interface{}
+
+	if filter.Actor != "" {
+		where = append(where, "actor LIKE ?")
+		args = append(args, "%"+filter.Actor+"%")
+	}
+	if filter.Action != "" {
+		where = append(where, "action = ?")
+		args = append(args, filter.Action)
+	}
+	if filter.TargetType != "" {
+		where = append(where, "target_type = ?")
+		args = append(args, filter.TargetType)
+	}
+	if filter.TargetID != 0 {
+		where = append(where, "target_id = ?")
+		args = append(args, filter.TargetID)
+	}
+	if !filter.Since.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, filter.Since.UTC().Format("2006-01-02 15:04:05"))
+	}
+	if !filter.Until.IsZero() {
+		where = append(where, "created_at < ?")
+		args = append(args, filter.Until.UTC().Format("2006-01-02 15:04:05"))
+	}
+
+	query := `
+		SELECT id, COALESCE(actor_id, 0), actor, action, target_type, COALESCE(target_id, 0),
+		       target_name, source_ip, changes, created_at
+		FROM audit_log
+	`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY created_at DESC, id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		var e models.AuditEntry
+		var changes string
+		err := rows.Scan(&e.ID, &e.ActorID, &e.Actor, &e.Action, &e.TargetType, &e.TargetID,
+			&e.TargetName, &e.SourceIP, &changes, &e.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		if err := json.Unmarshal([]byte(changes), &e.Changes); err != nil {
+			return nil, fmt.Errorf("failed to decode audit changes: %w", err)
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/rauche/cronnor/internal/models"
+)
+
+// querier is implemented by *sql.DB and *sql.Tx
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// getJobsByID retrieves the jobs with the given IDs that are not in the
+// trash, ordered by ID
+func getJobsByID(q querier, ids []int64) ([]models.Job, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+
+	query := `
+		SELECT ` + jobColumns + `
+		FROM jobs
+		WHERE deleted_at IS NULL AND id IN (` + strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ") + `)
+		ORDER BY id
+	`
+
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []models.Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job: %w", err)
+		}
+		jobs = append(jobs, *job)
+	}
+
+	return jobs, rows.Err()
+}
+
+// GetJobsByID retrieves the jobs with the given IDs that are not in the
+// trash, ordered by ID. Unknown IDs are skipped.
+func (r *Repository) GetJobsByID(ids []int64) ([]models.Job, error) {
+	return getJobsByID(r.db, ids)
+}
+
+// BulkUpdateJobs applies an action to every job in params.IDs in one
+// transaction and returns the jobs as they were before. If any job does not
+// exist nothing is changed and ErrJobNotFound is returned.
+func (r *Repository) BulkUpdateJobs(params models.BulkJobParams) ([]models.Job, error) {
+	seen := make(map[int64]bool)
+	var ids []int64
+	for _, id := range params.IDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	before, err := getJobsByID(tx, ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(before) != len(ids) {
+		return nil, ErrJobNotFound
+	}
+
+	for _, job := range before {
+		switch params.Action {
+		case models.BulkEnable, models.BulkDisable:
+			_, err = tx.Exec(`UPDATE jobs SET is_active = ? WHERE id = ?`, params.Action == models.BulkEnable, job.ID)
+		case models.BulkDelete:
+			_, err = tx.Exec(`UPDATE jobs SET deleted_at = CURRENT_TIMESTAMP WHERE id = ?`, job.ID)
+		case models.BulkTag:
+			err = tagJob(tx, job, params.Tags)
+		case models.BulkRetarget:
+			err = retargetJob(tx, job, params.Host)
+		case models.BulkRun:
+			// Runs change nothing; the jobs only need to exist
+		default:
+			return nil, fmt.Errorf("unknown bulk action %q", params.Action)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to %s job %d: %w", params.Action, job.ID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit jobs: %w", err)
+	}
+
+	return before, nil
+}
+
+// retargetJob points a job's URL at another host, recording a revision.
+// Heartbeat jobs have no URL and are left alone.
+func retargetJob(tx *sql.Tx, job models.Job, host string) error {
+	if job.IsHeartbeat() {
+		return nil
+	}
+
+	u, err := url.Parse(job.URL)
+	if err != nil {
+		return err
+	}
+	u.Host = host
+
+	return updateJob(tx, models.UpdateJobParams{
+		ID:       job.ID,
+		Name:     job.Name,
+		CronExpr: job.CronExpr,
+		URL:      u.String(),
+		Method:   job.Method,
+		Payload:  job.Payload,
+		Team:     job.Team,
+		Tags:     job.Tags,
+		Grace:    job.Grace,
+	})
+}
+
+// tagJob adds tags to a job, recording a revision if any are new
+func tagJob(tx *sql.Tx, job models.Job, tags []string) error {
+	return updateJob(tx, models.UpdateJobParams{
+		ID:       job.ID,
+		Name:     job.Name,
+		CronExpr: job.CronExpr,
+		URL:      job.URL,
+		Method:   job.Method,
+		Payload:  job.Payload,
+		Team:     job.Team,
+		Tags:     append(slices.Clone(job.Tags), tags...),
+		Grace:    job.Grace,
+	})
+}
@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rauche/cronnor/internal/models"
+)
+
+// ErrDigestNotFound is returned when a digest does not exist
+var ErrDigestNotFound = errors.New("digest not found")
+
+// digestColumns are the digests columns, joined with the channel, read by
+// scanDigest
+const digestColumns = `d.id, d.name, d.team, d.tag, d.cron_expr, d.stats_window, d.channel_id, nc.name, nc.type,
+	d.next_run_at, d.last_sent_at, d.last_error, d.created_at`
+
+// scanDigest scans a row selected with digestColumns
+func scanDigest(row rowScanner) (*models.Digest, error) {
+	var d models.Digest
+	err := row.Scan(&d.ID, &d.Name, &d.Team, &d.Tag, &d.CronExpr, &d.Window, &d.ChannelID, &d.ChannelName, &d.ChannelType,
+		&d.NextRunAt, &d.LastSentAt, &d.LastError, &d.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// CreateDigest stores a new digest, first due at next
+func (r *Repository) CreateDigest(in models.DigestInput, next time.Time) (int64, error) {
+	query := `
+		INSERT INTO digests (name, team, tag, cron_expr, stats_window, channel_id, next_run_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+
+	result, err := r.db.Exec(query, in.Name, in.Team, in.Tag, in.CronExpr, in.Window, in.ChannelID, next.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to create digest: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get insert id: %w", err)
+	}
+
+	return id, nil
+}
+
+// GetDigests retrieves all digests, by name
+func (r *Repository) GetDigests() ([]models.Digest, error) {
+	query := `
+		SELECT ` + digestColumns + `
+		FROM digests d
+		JOIN notification_channels nc ON nc.id = d.channel_id
+		ORDER BY d.name
+	`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query digests: %w", err)
+	}
+	defer rows.Close()
+
+	var digests []models.Digest
+	for rows.Next() {
+		d, err := scanDigest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan digest: %w", err)
+		}
+		digests = append(digests, *d)
+	}
+
+	return digests, rows.Err()
+}
+
+// GetDigest retrieves a digest by ID
+func (r *Repository) GetDigest(id int64) (*models.Digest, error) {
+	query := `
+		SELECT ` + digestColumns + `
+		FROM digests d
+		JOIN notification_channels nc ON nc.id = d.channel_id
+		WHERE d.id = ?
+	`
+
+	d, err := scanDigest(r.db.QueryRow(query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrDigestNotFound
+		}
+		return nil, fmt.Errorf("failed to get digest: %w", err)
+	}
+
+	return d, nil
+}
+
+// DeleteDigest deletes a digest
+func (r *Repository) DeleteDigest(id int64) error {
+	result, err := r.db.Exec(`DELETE FROM digests WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete digest: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrDigestNotFound
+	}
+
+	return nil
+}
+
+// SetDigestNextRun records when a digest is next due
+func (r *Repository) SetDigestNextRun(id int64, next time.Time) error {
+	if _, err := r.db.Exec(`UPDATE digests SET next_run_at = ? WHERE id = ?`, next.UTC(), id); err != nil {
+		return fmt.Errorf("failed to update digest next run: %w", err)
+	}
+
+	return nil
+}
+
+// RecordDigestSent records the outcome of sending a digest; sendErr is
+// invalid if it was delivered
+func (r *Repository) RecordDigestSent(id int64, sentAt time.Time, sendErr sql.NullString) error {
+	query := `UPDATE digests SET last_sent_at = ?, last_error = ? WHERE id = ?`
+
+	if _, err := r.db.Exec(query, sentAt.UTC(), sendErr, id); err != nil {
+		return fmt.Errorf("failed to record digest delivery: %w", err)
+	}
+
+	return nil
+}
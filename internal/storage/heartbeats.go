@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/rauche/cronnor/internal/models"
+)
+
+// SetJobPingToken gives a heartbeat job a ping URL, replacing any previous
+// one. Only the token's hash is stored.
+func (r *Repository) SetJobPingToken(id int64, tokenHash string) error {
+	query := `
+		UPDATE jobs
+		SET ping_token_hash = ?
+		WHERE id = ? AND deleted_at IS NULL
+	`
+
+	result, err := r.db.Exec(query, tokenHash, id)
+	if err != nil {
+		return fmt.Errorf("failed to set job ping token: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return ErrJobNotFound
+	}
+
+	return nil
+}
+
+// GetJobByPingToken retrieves the heartbeat job whose ping token has
+// tokenHash. Jobs in the trash are not found.
+func (r *Repository) GetJobByPingToken(tokenHash string) (*models.Job, error) {
+	query := `
+		SELECT ` + jobColumns + `
+		FROM jobs
+		WHERE ping_token_hash = ? AND deleted_at IS NULL
+	`
+
+	job, err := scanJob(r.db.QueryRow(query, tokenHash))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrJobNotFound
+		}
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+
+	return job, nil
+}
+
+// MarkHeartbeat sets a heartbeat job's status without counting as a ping.
+// It only applies while the last ping is still lastPingAt, so a ping that
+// arrives meanwhile wins, and reports whether it applied.
+func (r *Repository) MarkHeartbeat(id int64, status string, lastPingAt sql.NullTime) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Stored times do not round-trip to the same text, so compare them here
+	// rather than in the UPDATE
+	var current sql.NullTime
+	if err := tx.QueryRow(`SELECT last_run_at FROM jobs WHERE id = ?`, id).Scan(&current); err != nil {
+		if err == sql.ErrNoRows {
+			return false, ErrJobNotFound
+		}
+		return false, fmt.Errorf("failed to get job: %w", err)
+	}
+	if current.Valid != lastPingAt.Valid || !current.Time.Equal(lastPingAt.Time) {
+		return false, nil
+	}
+
+	if _, err := tx.Exec(`UPDATE jobs SET last_status = ? WHERE id = ?`, status, id); err != nil {
+		return false, fmt.Errorf("failed to mark heartbeat: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit job: %w", err)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPingTokenMigration(t *testing.T) {
+	// Migrate up to just before ping tokens were hashed
+	dir := t.TempDir()
+	files, err := filepath.Glob("../../migrations/*.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	copyMigration := func(file string) {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, filepath.Base(file)), b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range files {
+		if filepath.Base(file) < "019" {
+			copyMigration(file)
+		}
+	}
+
+	repo, err := New(filepath.Join(t.TempDir(), "cronnor.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer repo.Close()
+	if err := repo.RunMigrations(dir); err != nil {
+		t.Fatalf("failed to run migrations: %v", err)
+	}
+
+	const token = "5f0c8e2a9b1d4c7e8f6a3b2c1d0e9f8a"
+	if _, err := repo.db.Exec(`
+		INSERT INTO jobs (name, cron_expr, url, method, kind, ping_token)
+		VALUES ('backup', '0 0 * * * *', '', 'GET', 'heartbeat', ?)
+	`, token); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range files {
+		copyMigration(file)
+	}
+	if err := repo.RunMigrations(dir); err != nil {
+		t.Fatalf("failed to run migrations: %v", err)
+	}
+
+	// The existing ping URL keeps working, through its hash
+	sum := sha256.Sum256([]byte(token))
+	job, err := repo.GetJobByPingToken(hex.EncodeToString(sum[:]))
+	if err != nil || job.Name != "backup" || !job.PingURL {
+		t.Fatalf("expected the job to be found by its token's hash, got %v (%v)", job, err)
+	}
+	if _, err := repo.GetJobByPingToken(token); err != ErrJobNotFound {
+		t.Errorf("expected the plaintext token to be gone, got %v", err)
+	}
+}
@@ -2,22 +2,57 @@ package storage
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/rauche/cronnor/internal/models"
 )
 
-// GetAllJobs retrieves all jobs
-func (r *Repository) GetAllJobs() ([]models.Job, error) {
+// ErrJobNotFound is returned when a job does not exist
+var ErrJobNotFound = errors.New("job not found")
+
+// jobColumns are the columns scanJob expects, in order
+const jobColumns = `id, name, cron_expr, url, method, payload, is_active,
+		       created_at, last_run_at, last_status, revision, deleted_at, next_run_at, team,
+		       COALESCE((SELECT group_concat(tag, ',') FROM job_tags WHERE job_tags.job_id = jobs.id), ''),
+		       webhook_token_hash IS NOT NULL, webhook_secret IS NOT NULL, kind, grace_seconds, ping_token_hash IS NOT NULL`
+
+// scanJob scans a row selected with jobColumns
+func scanJob(row rowScanner) (*models.Job, error) {
+	var job models.Job
+	var tags string
+	var grace int64
+	err := row.Scan(
+		&job.ID, &job.Name, &job.CronExpr, &job.URL, &job.Method,
+		&job.Payload, &job.IsActive, &job.CreatedAt, &job.LastRunAt, &job.LastStatus, &job.Revision, &job.DeletedAt,
+		&job.NextRunAt, &job.Team, &tags, &job.Webhook, &job.WebhookHMAC, &job.Kind, &grace, &job.PingURL,
+	)
+	if err != nil {
+		return nil, err
+	}
+	job.Grace = time.Duration(grace) * time.Second
+	if tags != "" {
+		job.Tags = strings.Split(tags, ",")
+		sort.Strings(job.Tags)
+	}
+	return &job, nil
+}
+
+// GetAllJobs retrieves the jobs matching filter that are not in the trash
+func (r *Repository) GetAllJobs(filter models.JobFilter) ([]models.Job, error) {
+	where, args := jobFilterClause(filter)
 	query := `
-		SELECT id, name, cron_expr, url, method, payload, is_active, 
-		       created_at, last_run_at, last_status
+		SELECT ` + jobColumns + `
 		FROM jobs
+		WHERE deleted_at IS NULL` + where + `
 		ORDER BY created_at DESC
 	`
 
-	rows, err := r.db.Query(query)
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query jobs: %w", err)
 	}
@@ -25,27 +60,115 @@ func (r *Repository) GetAllJobs() ([]models.Job, error) {
 
 	var jobs []models.Job
 	for rows.Next() {
-		var job models.Job
-		err := rows.Scan(
-			&job.ID, &job.Name, &job.CronExpr, &job.URL, &job.Method,
-			&job.Payload, &job.IsActive, &job.CreatedAt, &job.LastRunAt, &job.LastStatus,
-		)
+		job, err := scanJob(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan job: %w", err)
 		}
-		jobs = append(jobs, job)
+		jobs = append(jobs, *job)
 	}
 
 	return jobs, rows.Err()
 }
 
+// jobFilterClause returns the conditions, each prefixed with AND, that
+// restrict a jobs query to filter
+func jobFilterClause(filter models.JobFilter) (string, []interface{}) {
+	var where string
+	var args []interface{}
+	if filter.Tag != "" {
+		where += ` AND EXISTS (SELECT 1 FROM job_tags WHERE job_tags.job_id = jobs.id AND tag = ?)`
+		args = append(args, filter.Tag)
+	}
+	if filter.Team != "" {
+		where += ` AND team = ?`
+		args = append(args, filter.Team)
+	}
+	if filter.Search != "" {
+		pattern := "%" + likeEscaper.Replace(filter.Search) + "%"
+		where += ` AND (name LIKE ? ESCAPE '\' OR url LIKE ? ESCAPE '\'
+		           OR EXISTS (SELECT 1 FROM job_tags WHERE job_tags.job_id = jobs.id AND tag LIKE ? ESCAPE '\'))`
+		args = append(args, pattern, pattern, pattern)
+	}
+	return where, args
+}
+
+// likeEscaper escapes the LIKE wildcards in a search term
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// jobSortColumns maps sort keys to the columns they order by
+var jobSortColumns = map[string]string{
+	models.JobSortCreated: "created_at",
+	models.JobSortName:    "name COLLATE NOCASE",
+	models.JobSortNextRun: "next_run_at",
+	models.JobSortLastRun: "last_run_at",
+	models.JobSortStatus:  "last_status",
+}
+
+// defaultJobsPerPage is the page size used when none is requested
+const defaultJobsPerPage = 24
+
+// ListJobs retrieves one page of the jobs matching opts that are not in the
+// trash. Jobs without a value for the sort column come last.
+func (r *Repository) ListJobs(opts models.JobListOptions) (*models.JobPage, error) {
+	where, args := jobFilterClause(opts.Filter)
+
+	page := &models.JobPage{Page: opts.Page, PerPage: opts.PerPage}
+	if page.PerPage <= 0 {
+		page.PerPage = defaultJobsPerPage
+	}
+
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM jobs WHERE deleted_at IS NULL`+where, args...).Scan(&page.Total)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count jobs: %w", err)
+	}
+
+	if page.Page > page.Pages() {
+		page.Page = page.Pages()
+	}
+	if page.Page < 1 {
+		page.Page = 1
+	}
+
+	column, ok := jobSortColumns[opts.Sort]
+	if !ok {
+		column = jobSortColumns[models.JobSortCreated]
+	}
+	dir := "ASC"
+	if opts.Desc {
+		dir = "DESC"
+	}
+
+	query := `
+		SELECT ` + jobColumns + `
+		FROM jobs
+		WHERE deleted_at IS NULL` + where + `
+		ORDER BY ` + column + ` IS NULL, ` + column + ` ` + dir + `, id ` + dir + `
+		LIMIT ? OFFSET ?
+	`
+
+	rows, err := r.db.Query(query, append(args, page.PerPage, (page.Page-1)*page.PerPage)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query jobs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job: %w", err)
+		}
+		page.Jobs = append(page.Jobs, *job)
+	}
+
+	return page, rows.Err()
+}
+
 // GetActiveJobs retrieves all active jobs
 func (r *Repository) GetActiveJobs() ([]models.Job, error) {
 	query := `
-		SELECT id, name, cron_expr, url, method, payload, is_active, 
-		       created_at, last_run_at, last_status
+		SELECT ` + jobColumns + `
 		FROM jobs
-		WHERE is_active = 1
+		WHERE is_active = 1 AND deleted_at IS NULL
 		ORDER BY created_at DESC
 	`
 
@@ -57,52 +180,63 @@ func (r *Repository) GetActiveJobs() ([]models.Job, error) {
 
 	var jobs []models.Job
 	for rows.Next() {
-		var job models.Job
-		err := rows.Scan(
-			&job.ID, &job.Name, &job.CronExpr, &job.URL, &job.Method,
-			&job.Payload, &job.IsActive, &job.CreatedAt, &job.LastRunAt, &job.LastStatus,
-		)
+		job, err := scanJob(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan job: %w", err)
 		}
-		jobs = append(jobs, job)
+		jobs = append(jobs, *job)
 	}
 
 	return jobs, rows.Err()
 }
 
-// GetJob retrieves a job by ID
+// CountActiveJobs counts the jobs that are enabled and not in the trash
+func (r *Repository) CountActiveJobs() (int, error) {
+	var count int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM jobs WHERE is_active = 1 AND deleted_at IS NULL`).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count active jobs: %w", err)
+	}
+	return count, nil
+}
+
+// GetJob retrieves a job by ID; jobs in the trash are not found
 func (r *Repository) GetJob(id int64) (*models.Job, error) {
 	query := `
-		SELECT id, name, cron_expr, url, method, payload, is_active, 
-		       created_at, last_run_at, last_status
+		SELECT ` + jobColumns + `
 		FROM jobs
-		WHERE id = ?
+		WHERE id = ? AND deleted_at IS NULL
 	`
 
-	var job models.Job
-	err := r.db.QueryRow(query, id).Scan(
-		&job.ID, &job.Name, &job.CronExpr, &job.URL, &job.Method,
-		&job.Payload, &job.IsActive, &job.CreatedAt, &job.LastRunAt, &job.LastStatus,
-	)
+	job, err := scanJob(r.db.QueryRow(query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("job not found")
+			return nil, ErrJobNotFound
 		}
 		return nil, fmt.Errorf("failed to get job: %w", err)
 	}
 
-	return &job, nil
+	return job, nil
 }
 
-// CreateJob creates a new job
+// CreateJob creates a new job and records its first revision
 func (r *Repository) CreateJob(params models.CreateJobParams) (int64, error) {
 	query := `
-		INSERT INTO jobs (name, cron_expr, url, method, payload)
-		VALUES (?, ?, ?, ?, ?)
+		INSERT INTO jobs (name, cron_expr, url, method, payload, team, is_active, kind, grace_seconds, ping_token_hash)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''))
 	`
 
-	result, err := r.db.Exec(query, params.Name, params.CronExpr, params.URL, params.Method, params.Payload)
+	if params.Kind == "" {
+		params.Kind = models.JobKindHTTP
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(query, params.Name, params.CronExpr, params.URL, params.Method, params.Payload, params.Team, !params.Disabled,
+		params.Kind, int64(params.Grace/time.Second), params.PingTokenHash)
 	if err != nil {
 		return 0, fmt.Errorf("failed to create job: %w", err)
 	}
@@ -112,18 +246,76 @@ func (r *Repository) CreateJob(params models.CreateJobParams) (int64, error) {
 		return 0, fmt.Errorf("failed to get insert id: %w", err)
 	}
 
+	if err := setJobTags(tx, id, params.Tags); err != nil {
+		return 0, err
+	}
+
+	if err := insertJobRevision(tx, id); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit job: %w", err)
+	}
+
 	return id, nil
 }
 
-// UpdateJob updates an existing job
+// UpdateJob updates an existing job, recording a new revision when its
+// configuration, team, tags or grace period changed
 func (r *Repository) UpdateJob(params models.UpdateJobParams) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := updateJob(tx, params); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit job: %w", err)
+	}
+
+	return nil
+}
+
+// updateJob applies UpdateJob within a transaction
+func updateJob(tx *sql.Tx, params models.UpdateJobParams) error {
 	query := `
 		UPDATE jobs
-		SET name = ?, cron_expr = ?, url = ?, method = ?, payload = ?
-		WHERE id = ?
+		SET name = ?, cron_expr = ?, url = ?, method = ?, payload = ?, team = ?, grace_seconds = ?, revision = revision + 1
+		WHERE id = ? AND deleted_at IS NULL
+		  AND ((name, cron_expr, url, method, COALESCE(payload, ''), team, grace_seconds)
+		       IS NOT (?, ?, ?, ?, COALESCE(?, ''), ?, ?) OR ?)
 	`
 
-	result, err := r.db.Exec(query, params.Name, params.CronExpr, params.URL, params.Method, params.Payload, params.ID)
+	var exists bool
+	if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM jobs WHERE id = ? AND deleted_at IS NULL)`, params.ID).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to get job: %w", err)
+	}
+	if !exists {
+		return ErrJobNotFound
+	}
+
+	oldTags, err := jobTags(tx, params.ID)
+	if err != nil {
+		return err
+	}
+	if err := setJobTags(tx, params.ID, params.Tags); err != nil {
+		return err
+	}
+	newTags, err := jobTags(tx, params.ID)
+	if err != nil {
+		return err
+	}
+
+	grace := int64(params.Grace / time.Second)
+	result, err := tx.Exec(query,
+		params.Name, params.CronExpr, params.URL, params.Method, params.Payload, params.Team, grace, params.ID,
+		params.Name, params.CronExpr, params.URL, params.Method, params.Payload, params.Team, grace,
+		!slices.Equal(oldTags, newTags))
 	if err != nil {
 		return fmt.Errorf("failed to update job: %w", err)
 	}
@@ -133,8 +325,11 @@ func (r *Repository) UpdateJob(params models.UpdateJobParams) error {
 		return fmt.Errorf("failed to get rows affected: %w", err)
 	}
 
-	if rows == 0 {
-		return fmt.Errorf("job not found")
+	// Saving an unchanged job doesn't create a revision
+	if rows > 0 {
+		if err := insertJobRevision(tx, params.ID); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -145,7 +340,7 @@ func (r *Repository) ToggleJob(id int64) error {
 	query := `
 		UPDATE jobs
 		SET is_active = NOT is_active
-		WHERE id = ?
+		WHERE id = ? AND deleted_at IS NULL
 	`
 
 	result, err := r.db.Exec(query, id)
@@ -159,7 +354,7 @@ func (r *Repository) ToggleJob(id int64) error {
 	}
 
 	if rows == 0 {
-		return fmt.Errorf("job not found")
+		return ErrJobNotFound
 	}
 
 	return nil
@@ -181,9 +376,23 @@ func (r *Repository) UpdateJobStatus(id int64, status string) error {
 	return nil
 }
 
-// DeleteJob deletes a job
+// SetJobNextRun records when the scheduler will next run a job; an invalid
+// time means the job is not scheduled
+func (r *Repository) SetJobNextRun(id int64, next sql.NullTime) error {
+	if next.Valid {
+		next.Time = next.Time.UTC()
+	}
+
+	if _, err := r.db.Exec(`UPDATE jobs SET next_run_at = ? WHERE id = ?`, next, id); err != nil {
+		return fmt.Errorf("failed to update job next run: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteJob moves a job to the trash, keeping its execution history
 func (r *Repository) DeleteJob(id int64) error {
-	query := `DELETE FROM jobs WHERE id = ?`
+	query := `UPDATE jobs SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND deleted_at IS NULL`
 
 	result, err := r.db.Exec(query, id)
 	if err != nil {
@@ -196,8 +405,110 @@ func (r *Repository) DeleteJob(id int64) error {
 	}
 
 	if rows == 0 {
-		return fmt.Errorf("job not found")
+		return ErrJobNotFound
+	}
+
+	return nil
+}
+
+// GetTrashedJobs retrieves the jobs in the trash, most recently deleted first
+func (r *Repository) GetTrashedJobs() ([]models.Job, error) {
+	query := `
+		SELECT ` + jobColumns + `
+		FROM jobs
+		WHERE deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC
+	`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trashed jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []models.Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job: %w", err)
+		}
+		jobs = append(jobs, *job)
+	}
+
+	return jobs, rows.Err()
+}
+
+// GetTrashedJob retrieves a job in the trash by ID
+func (r *Repository) GetTrashedJob(id int64) (*models.Job, error) {
+	query := `
+		SELECT ` + jobColumns + `
+		FROM jobs
+		WHERE id = ? AND deleted_at IS NOT NULL
+	`
+
+	job, err := scanJob(r.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrJobNotFound
+		}
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+
+	return job, nil
+}
+
+// RestoreJob takes a job out of the trash
+func (r *Repository) RestoreJob(id int64) error {
+	query := `UPDATE jobs SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL`
+
+	result, err := r.db.Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("failed to restore job: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return ErrJobNotFound
+	}
+
+	return nil
+}
+
+// PurgeJob permanently deletes a job in the trash along with its history
+func (r *Repository) PurgeJob(id int64) error {
+	query := `DELETE FROM jobs WHERE id = ? AND deleted_at IS NOT NULL`
+
+	result, err := r.db.Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("failed to purge job: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return ErrJobNotFound
 	}
 
 	return nil
 }
+
+// PurgeDeletedJobs permanently deletes jobs that have been in the trash for
+// longer than retention, returning how many were purged
+func (r *Repository) PurgeDeletedJobs(retention time.Duration) (int64, error) {
+	query := `DELETE FROM jobs WHERE deleted_at IS NOT NULL AND deleted_at < ?`
+
+	cutoff := time.Now().UTC().Add(-retention).Format("2006-01-02 15:04:05")
+	result, err := r.db.Exec(query, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge deleted jobs: %w", err)
+	}
+
+	return result.RowsAffected()
+}
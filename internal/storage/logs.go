@@ -2,41 +2,121 @@ package storage
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/rauche/cronnor/internal/models"
 )
 
-// CreateJobLog creates a new job log entry
-func (r *Repository) CreateJobLog(log models.JobLog) error {
+// ErrLogNotFound is returned when a job log entry does not exist
+var ErrLogNotFound = errors.New("log not found")
+
+// logColumns are the job_logs columns read by scanJobLog
+const logColumns = `id, job_id, status, http_code, duration_ms, response_body, error_message, job_revision,
+		       trigger_type, COALESCE(actor_id, 0), actor, scheduled_at, started_at, lag_ms, trace_id, created_at`
+
+// scanJobLog scans a row selected with logColumns
+func scanJobLog(row rowScanner) (*models.JobLog, error) {
+	var log models.JobLog
+	err := row.Scan(
+		&log.ID, &log.JobID, &log.Status, &log.HTTPCode,
+		&log.DurationMs, &log.ResponseBody, &log.ErrorMessage, &log.JobRevision,
+		&log.Trigger, &log.ActorID, &log.Actor, &log.ScheduledAt, &log.StartedAt, &log.LagMs, &log.TraceID, &log.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &log, nil
+}
+
+// CreateJobLog creates a new job log entry, updates the stats rollup and
+// returns the entry's ID
+func (r *Repository) CreateJobLog(log models.JobLog) (int64, error) {
 	query := `
-		INSERT INTO job_logs (job_id, status, http_code, duration_ms, response_body, error_message)
-		VALUES (?, ?, ?, ?, ?, ?)
+		INSERT INTO job_logs (job_id, status, http_code, duration_ms, response_body, error_message, job_revision, trigger_type,
+		                      actor_id, actor, scheduled_at, started_at, lag_ms, trace_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
-	_, err := r.db.Exec(query, log.JobID, log.Status, log.HTTPCode, log.DurationMs, log.ResponseBody, log.ErrorMessage)
+	if log.Trigger == "" {
+		log.Trigger = models.TriggerSchedule
+	}
+	actorID := sql.NullInt64{Int64: log.ActorID, Valid: log.ActorID != 0}
+	if log.ScheduledAt.Valid {
+		log.ScheduledAt.Time = log.ScheduledAt.Time.UTC()
+	}
+	if log.StartedAt.Valid {
+		log.StartedAt.Time = log.StartedAt.Time.UTC()
+	}
+
+	tx, err := r.db.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to create job log: %w", err)
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	return nil
+	result, err := tx.Exec(query, log.JobID, log.Status, log.HTTPCode, log.DurationMs, log.ResponseBody, log.ErrorMessage, log.JobRevision, log.Trigger,
+		actorID, log.Actor, log.ScheduledAt, log.StartedAt, log.LagMs, log.TraceID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create job log: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get job log ID: %w", err)
+	}
+
+	// A heartbeat's start ping is not an outcome
+	if log.Status != "STARTED" {
+		if err := rollupJobLog(tx, log); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit job log: %w", err)
+	}
+	return id, nil
 }
 
 // GetJobLogs retrieves logs for a specific job
 func (r *Repository) GetJobLogs(jobID int64, limit int) ([]models.JobLog, error) {
-	if limit <= 0 {
-		limit = 50
+	return r.FindJobLogs(jobID, models.JobLogFilter{Limit: limit})
+}
+
+// FindJobLogs retrieves the logs of a job that match filter, newest first
+func (r *Repository) FindJobLogs(jobID int64, filter models.JobLogFilter) ([]models.JobLog, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = 50
+	}
+
+	where := []string{"job_id = ?"}
+	args := []interface{}{jobID}
+
+	if filter.Trigger != "" {
+		where = append(where, "trigger_type = ?")
+		args = append(args, filter.Trigger)
+	}
+	if filter.Actor != "" {
+		where = append(where, "actor LIKE ?")
+		args = append(args, "%"+filter.Actor+"%")
+	}
+	if filter.MinLag > 0 {
+		where = append(where, "lag_ms >= ?")
+		args = append(args, filter.MinLag.Milliseconds())
 	}
 
 	query := `
-		SELECT id, job_id, status, http_code, duration_ms, response_body, error_message, created_at
+		SELECT ` + logColumns + `
 		FROM job_logs
-		WHERE job_id = ?
-		ORDER BY created_at DESC
+		WHERE ` + strings.Join(where, " AND ") + `
+		ORDER BY created_at DESC, id DESC
 		LIMIT ?
 	`
+	args = append(args, filter.Limit)
 
-	rows, err := r.db.Query(query, jobID, limit)
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query job logs: %w", err)
 	}
@@ -44,35 +124,65 @@ func (r *Repository) GetJobLogs(jobID int64, limit int) ([]models.JobLog, error)
 
 	var logs []models.JobLog
 	for rows.Next() {
-		var log models.JobLog
-		err := rows.Scan(
-			&log.ID, &log.JobID, &log.Status, &log.HTTPCode,
-			&log.DurationMs, &log.ResponseBody, &log.ErrorMessage, &log.CreatedAt,
-		)
+		log, err := scanJobLog(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan job log: %w", err)
 		}
-		logs = append(logs, log)
+		logs = append(logs, *log)
 	}
 
 	return logs, rows.Err()
 }
 
+// GetJobOutcome retrieves a job's log entry logID and the outcome logged
+// before it, skipping heartbeat start pings; prev is nil if there is none
+func (r *Repository) GetJobOutcome(jobID, logID int64) (entry, prev *models.JobLog, err error) {
+	query := `
+		SELECT ` + logColumns + `
+		FROM job_logs
+		WHERE job_id = ? AND id <= ? AND status != 'STARTED'
+		ORDER BY id DESC
+		LIMIT 2
+	`
+
+	rows, err := r.db.Query(query, jobID, logID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query job logs: %w", err)
+	}
+	defer rows.Close()
+
+	var logs []*models.JobLog
+	for rows.Next() {
+		log, err := scanJobLog(rows)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to scan job log: %w", err)
+		}
+		logs = append(logs, log)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if len(logs) == 0 || logs[0].ID != logID {
+		return nil, nil, ErrLogNotFound
+	}
+	if len(logs) == 2 {
+		prev = logs[1]
+	}
+	return logs[0], prev, nil
+}
+
 // GetLatestJobLog retrieves the most recent log for a job
 func (r *Repository) GetLatestJobLog(jobID int64) (*models.JobLog, error) {
 	query := `
-		SELECT id, job_id, status, http_code, duration_ms, response_body, error_message, created_at
+		SELECT ` + logColumns + `
 		FROM job_logs
 		WHERE job_id = ?
 		ORDER BY created_at DESC
 		LIMIT 1
 	`
 
-	var log models.JobLog
-	err := r.db.QueryRow(query, jobID).Scan(
-		&log.ID, &log.JobID, &log.Status, &log.HTTPCode,
-		&log.DurationMs, &log.ResponseBody, &log.ErrorMessage, &log.CreatedAt,
-	)
+	log, err := scanJobLog(r.db.QueryRow(query, jobID))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // No logs yet
@@ -80,5 +190,5 @@ func (r *Repository) GetLatestJobLog(jobID int64) (*models.JobLog, error) {
 		return nil, fmt.Errorf("failed to get latest job log: %w", err)
 	}
 
-	return &log, nil
+	return log, nil
 }
@@ -0,0 +1,353 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/rauche/cronnor/internal/models"
+)
+
+// ErrChannelNotFound is returned when a notification channel does not exist
+var ErrChannelNotFound = errors.New("channel not found")
+
+// ErrAlertRuleNotFound is returned when an alert rule does not exist
+var ErrAlertRuleNotFound = errors.New("alert rule not found")
+
+// CreateChannel stores a new notification channel
+func (r *Repository) CreateChannel(name, channelType, target string) (int64, error) {
+	query := `INSERT INTO notification_channels (name, type, target) VALUES (?, ?, ?)`
+
+	result, err := r.db.Exec(query, name, channelType, target)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create channel: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get insert id: %w", err)
+	}
+
+	return id, nil
+}
+
+// GetChannels retrieves all notification channels, by name
+func (r *Repository) GetChannels() ([]models.NotificationChannel, error) {
+	query := `
+		SELECT id, name, type, target, created_at
+		FROM notification_channels
+		ORDER BY name
+	`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query channels: %w", err)
+	}
+	defer rows.Close()
+
+	var channels []models.NotificationChannel
+	for rows.Next() {
+		var ch models.NotificationChannel
+		if err := rows.Scan(&ch.ID, &ch.Name, &ch.Type, &ch.Target, &ch.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan channel: %w", err)
+		}
+		channels = append(channels, ch)
+	}
+
+	return channels, rows.Err()
+}
+
+// GetChannel retrieves a notification channel by ID
+func (r *Repository) GetChannel(id int64) (*models.NotificationChannel, error) {
+	query := `
+		SELECT id, name, type, target, created_at
+		FROM notification_channels
+		WHERE id = ?
+	`
+
+	var ch models.NotificationChannel
+	err := r.db.QueryRow(query, id).Scan(&ch.ID, &ch.Name, &ch.Type, &ch.Target, &ch.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrChannelNotFound
+		}
+		return nil, fmt.Errorf("failed to get channel: %w", err)
+	}
+
+	return &ch, nil
+}
+
+// DeleteChannel deletes a notification channel and the rules using it
+func (r *Repository) DeleteChannel(id int64) error {
+	result, err := r.db.Exec(`DELETE FROM notification_channels WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete channel: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrChannelNotFound
+	}
+
+	return nil
+}
+
+// ruleColumns are the alert_rules columns, joined with the channel, read by
+// scanAlertRule
+const ruleColumns = `ar.id, ar.job_id, ar.channel_id, nc.name, nc.type, ar.event, ar.threshold, ar.created_at`
+
+// scanAlertRule scans a row selected with ruleColumns
+func scanAlertRule(row rowScanner) (*models.AlertRule, error) {
+	var rule models.AlertRule
+	err := row.Scan(&rule.ID, &rule.JobID, &rule.ChannelID, &rule.ChannelName, &rule.ChannelType,
+		&rule.Event, &rule.Threshold, &rule.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// CreateAlertRule stores a new alert rule for a job
+func (r *Repository) CreateAlertRule(jobID, channelID int64, event string, threshold int64) (int64, error) {
+	query := `INSERT INTO alert_rules (job_id, channel_id, event, threshold) VALUES (?, ?, ?, ?)`
+
+	result, err := r.db.Exec(query, jobID, channelID, event, threshold)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create alert rule: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get insert id: %w", err)
+	}
+
+	return id, nil
+}
+
+// GetAlertRules retrieves a job's alert rules, oldest first
+func (r *Repository) GetAlertRules(jobID int64) ([]models.AlertRule, error) {
+	query := `
+		SELECT ` + ruleColumns + `
+		FROM alert_rules ar
+		JOIN notification_channels nc ON nc.id = ar.channel_id
+		WHERE ar.job_id = ?
+		ORDER BY ar.id
+	`
+
+	rows, err := r.db.Query(query, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query alert rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []models.AlertRule
+	for rows.Next() {
+		rule, err := scanAlertRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan alert rule: %w", err)
+		}
+		rules = append(rules, *rule)
+	}
+
+	return rules, rows.Err()
+}
+
+// GetAlertRule retrieves one of a job's alert rules
+func (r *Repository) GetAlertRule(jobID, id int64) (*models.AlertRule, error) {
+	query := `
+		SELECT ` + ruleColumns + `
+		FROM alert_rules ar
+		JOIN notification_channels nc ON nc.id = ar.channel_id
+		WHERE ar.job_id = ? AND ar.id = ?
+	`
+
+	rule, err := scanAlertRule(r.db.QueryRow(query, jobID, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrAlertRuleNotFound
+		}
+		return nil, fmt.Errorf("failed to get alert rule: %w", err)
+	}
+
+	return rule, nil
+}
+
+// DeleteAlertRule deletes one of a job's alert rules; its alerts are kept
+func (r *Repository) DeleteAlertRule(jobID, id int64) error {
+	result, err := r.db.Exec(`DELETE FROM alert_rules WHERE job_id = ? AND id = ?`, jobID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete alert rule: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrAlertRuleNotFound
+	}
+
+	return nil
+}
+
+// RecordOutcome adds an execution's outcome to the job's incidents. A
+// failure opens an incident or counts towards the open one, which is
+// returned. A success resolves the open incident and returns it with
+// ResolvedAt set, or returns nil if there was none.
+func (r *Repository) RecordOutcome(jobID int64, failed bool) (*models.Incident, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var result sql.Result
+	if failed {
+		result, err = tx.Exec(`
+			INSERT INTO job_incidents (job_id) VALUES (?)
+			ON CONFLICT (job_id) WHERE resolved_at IS NULL DO UPDATE SET failures = failures + 1
+		`, jobID)
+	} else {
+		result, err = tx.Exec(`UPDATE job_incidents SET resolved_at = CURRENT_TIMESTAMP WHERE job_id = ? AND resolved_at IS NULL`, jobID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to record incident: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return nil, nil
+	}
+
+	// The incident just changed is the job's latest
+	query := `
+		SELECT id, job_id, failures, opened_at, resolved_at
+		FROM job_incidents
+		WHERE job_id = ?
+		ORDER BY id DESC
+		LIMIT 1
+	`
+
+	var incident models.Incident
+	err = tx.QueryRow(query, jobID).Scan(&incident.ID, &incident.JobID, &incident.Failures, &incident.OpenedAt, &incident.ResolvedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get incident: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit incident: %w", err)
+	}
+
+	return &incident, nil
+}
+
+// CreateAlert stores an alert unless its rule already raised one with the
+// same dedup key, and reports whether it was stored
+func (r *Repository) CreateAlert(alert models.Alert) (int64, bool, error) {
+	query := `
+		INSERT INTO alerts (rule_id, job_id, channel_name, event, dedup_key, message)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (rule_id, dedup_key) DO NOTHING
+	`
+
+	result, err := r.db.Exec(query, alert.RuleID, alert.JobID, alert.ChannelName, alert.Event, alert.DedupKey, alert.Message)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to create alert: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return 0, false, nil
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get insert id: %w", err)
+	}
+
+	return id, true, nil
+}
+
+// CreateNotificationAttempt records an attempt to deliver an alert
+func (r *Repository) CreateNotificationAttempt(attempt models.NotificationAttempt) error {
+	query := `INSERT INTO notification_attempts (alert_id, attempt, status, error) VALUES (?, ?, ?, ?)`
+
+	if _, err := r.db.Exec(query, attempt.AlertID, attempt.Attempt, attempt.Status, attempt.Error); err != nil {
+		return fmt.Errorf("failed to record notification attempt: %w", err)
+	}
+
+	return nil
+}
+
+// GetAlerts retrieves a job's most recent alerts with their delivery
+// attempts, newest first
+func (r *Repository) GetAlerts(jobID int64, limit int) ([]models.Alert, error) {
+	query := `
+		SELECT id, COALESCE(rule_id, 0), job_id, channel_name, event, dedup_key, message, created_at
+		FROM alerts
+		WHERE job_id = ?
+		ORDER BY created_at DESC, id DESC
+		LIMIT ?
+	`
+
+	rows, err := r.db.Query(query, jobID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query alerts: %w", err)
+	}
+	defer rows.Close()
+
+	var alerts []models.Alert
+	index := make(map[int64]int)
+	for rows.Next() {
+		var a models.Alert
+		if err := rows.Scan(&a.ID, &a.RuleID, &a.JobID, &a.ChannelName, &a.Event, &a.DedupKey, &a.Message, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan alert: %w", err)
+		}
+		a.Attempts = []models.NotificationAttempt{}
+		index[a.ID] = len(alerts)
+		alerts = append(alerts, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(alerts) == 0 {
+		return alerts, nil
+	}
+
+	ids := make([]interface{}, 0, len(alerts))
+	for _, a := range alerts {
+		ids = append(ids, a.ID)
+	}
+
+	attempts, err := r.db.Query(`
+		SELECT id, alert_id, attempt, status, error, created_at
+		FROM notification_attempts
+		WHERE alert_id IN (?`+strings.Repeat(", ?", len(ids)-1)+`)
+		ORDER BY id
+	`, ids...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notification attempts: %w", err)
+	}
+	defer attempts.Close()
+
+	for attempts.Next() {
+		var a models.NotificationAttempt
+		if err := attempts.Scan(&a.ID, &a.AlertID, &a.Attempt, &a.Status, &a.Error, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan notification attempt: %w", err)
+		}
+		i := index[a.AlertID]
+		alerts[i].Attempts = append(alerts[i].Attempts, a)
+	}
+
+	return alerts, attempts.Err()
+}
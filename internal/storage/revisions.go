@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rauche/cronnor/internal/models"
+)
+
+// ErrRevisionNotFound is returned when a job revision does not exist
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// insertJobRevision snapshots a job's current configuration as its current revision
+func insertJobRevision(tx *sql.Tx, jobID int64) error {
+	query := `
+		INSERT INTO job_revisions (job_id, revision, name, cron_expr, url, method, payload, team, tags, grace_seconds)
+		SELECT id, revision, name, cron_expr, url, method, payload, team,
+		       COALESCE((SELECT group_concat(tag, ',') FROM job_tags WHERE job_tags.job_id = jobs.id), ''), grace_seconds
+		FROM jobs
+		WHERE id = ?
+	`
+
+	if _, err := tx.Exec(query, jobID); err != nil {
+		return fmt.Errorf("failed to record job revision: %w", err)
+	}
+
+	return nil
+}
+
+// revisionColumns lists the job_revisions columns read by scanJobRevision
+const revisionColumns = `job_id, revision, name, cron_expr, url, method, payload, team, tags, grace_seconds, created_at`
+
+// scanJobRevision reads a job revision selected with revisionColumns
+func scanJobRevision(row rowScanner) (*models.JobRevision, error) {
+	var rev models.JobRevision
+	var tags string
+	var grace int64
+	err := row.Scan(&rev.JobID, &rev.Revision, &rev.Name, &rev.CronExpr, &rev.URL,
+		&rev.Method, &rev.Payload, &rev.Team, &tags, &grace, &rev.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	rev.Grace = time.Duration(grace) * time.Second
+	if tags != "" {
+		rev.Tags = strings.Split(tags, ",")
+		sort.Strings(rev.Tags)
+	}
+	return &rev, nil
+}
+
+// GetJobRevisions retrieves every revision of a job, newest first
+func (r *Repository) GetJobRevisions(jobID int64) ([]models.JobRevision, error) {
+	query := `
+		SELECT ` + revisionColumns + `
+		FROM job_revisions
+		WHERE job_id = ?
+		ORDER BY revision DESC
+	`
+
+	rows, err := r.db.Query(query, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query job revisions: %w", err)
+	}
+	defer rows.Close()
+
+	var revisions []models.JobRevision
+	for rows.Next() {
+		rev, err := scanJobRevision(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job revision: %w", err)
+		}
+		revisions = append(revisions, *rev)
+	}
+
+	return revisions, rows.Err()
+}
+
+// GetJobRevision retrieves one revision of a job
+func (r *Repository) GetJobRevision(jobID, revision int64) (*models.JobRevision, error) {
+	query := `
+		SELECT ` + revisionColumns + `
+		FROM job_revisions
+		WHERE job_id = ? AND revision = ?
+	`
+
+	rev, err := scanJobRevision(r.db.QueryRow(query, jobID, revision))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrRevisionNotFound
+		}
+		return nil, fmt.Errorf("failed to get job revision: %w", err)
+	}
+
+	return rev, nil
+}
+
+// RestoreJobRevision sets a job's configuration back to an earlier revision,
+// which is recorded as a new revision
+func (r *Repository) RestoreJobRevision(jobID, revision int64) error {
+	rev, err := r.GetJobRevision(jobID, revision)
+	if err != nil {
+		return err
+	}
+
+	return r.UpdateJob(models.UpdateJobParams{
+		ID:       jobID,
+		Name:     rev.Name,
+		CronExpr: rev.CronExpr,
+		URL:      rev.URL,
+		Method:   rev.Method,
+		Payload:  rev.Payload,
+		Team:     rev.Team,
+		Tags:     rev.Tags,
+		Grace:    rev.Grace,
+	})
+}
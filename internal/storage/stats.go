@@ -0,0 +1,284 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/rauche/cronnor/internal/models"
+)
+
+// StatsWindows lists the supported statistics windows, in display order
+var StatsWindows = []string{"24h", "7d", "30d"}
+
+// statsWindowModifiers maps a window to its SQLite datetime modifier
+var statsWindowModifiers = map[string]string{
+	"24h": "-24 hours",
+	"7d":  "-7 days",
+	"30d": "-30 days",
+}
+
+// statsWindowDurations maps a window to how far back it reaches
+var statsWindowDurations = map[string]time.Duration{
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+	"30d": 30 * 24 * time.Hour,
+}
+
+// durationBuckets are the inclusive upper bounds (ms) of the duration
+// histogram. Durations above the last bound go to the overflow bucket 0.
+// Keep in sync with the backfill in migrations/002_job_stats.sql.
+var durationBuckets = []int64{
+	5, 10, 25, 50, 75, 100, 150, 250, 400, 600,
+	1000, 1500, 2500, 4000, 6000, 10000, 15000,
+}
+
+// windowStart selects the hourly rollups of a window from its datetime
+// modifier. Windows count whole hours, the current one included, so 24h
+// covers the current hour and the 23 before it and never more than 24 hours.
+const windowStart = `hour > strftime('%Y-%m-%d %H:00:00', 'now', ?)`
+
+// maxOutcomeLength caps stored error messages in the outcome rollup
+const maxOutcomeLength = 200
+
+// ValidStatsWindow reports whether window is a supported statistics window
+func ValidStatsWindow(window string) bool {
+	_, ok := statsWindowModifiers[window]
+	return ok
+}
+
+// StatsWindowDuration returns how far back a statistics window reaches
+func StatsWindowDuration(window string) time.Duration {
+	return statsWindowDurations[window]
+}
+
+// durationBucket returns the histogram bucket for a duration
+func durationBucket(ms int64) int64 {
+	for _, bound := range durationBuckets {
+		if ms <= bound {
+			return bound
+		}
+	}
+	return 0
+}
+
+// rollupJobLog adds a log entry to the hourly statistics rollup
+func rollupJobLog(tx *sql.Tx, log models.JobLog) error {
+	hour := `strftime('%Y-%m-%d %H:00:00', 'now')`
+
+	var success, failure, errored int
+	switch log.Status {
+	case "SUCCESS":
+		success = 1
+	case "FAILED":
+		failure = 1
+	case "ERROR":
+		errored = 1
+	}
+
+	_, err := tx.Exec(`
+		INSERT INTO job_stats_hourly (job_id, hour, runs, successes, failures, errors, duration_max)
+		VALUES (?, `+hour+`, 1, ?, ?, ?, ?)
+		ON CONFLICT (job_id, hour) DO UPDATE SET
+			runs = runs + 1,
+			successes = successes + excluded.successes,
+			failures = failures + excluded.failures,
+			errors = errors + excluded.errors,
+			duration_max = MAX(duration_max, excluded.duration_max)
+	`, log.JobID, success, failure, errored, log.DurationMs.Int64)
+	if err != nil {
+		return fmt.Errorf("failed to update hourly stats: %w", err)
+	}
+
+	if log.DurationMs.Valid {
+		_, err := tx.Exec(`
+			INSERT INTO job_stats_durations (job_id, hour, bucket_ms, count)
+			VALUES (?, `+hour+`, ?, 1)
+			ON CONFLICT (job_id, hour, bucket_ms) DO UPDATE SET count = count + 1
+		`, log.JobID, durationBucket(log.DurationMs.Int64))
+		if err != nil {
+			return fmt.Errorf("failed to update duration stats: %w", err)
+		}
+	}
+
+	outcomes := map[string]string{}
+	if log.HTTPCode.Valid {
+		outcomes["code"] = strconv.FormatInt(log.HTTPCode.Int64, 10)
+	}
+	if log.ErrorMessage.Valid {
+		msg := log.ErrorMessage.String
+		if len(msg) > maxOutcomeLength {
+			msg = msg[:maxOutcomeLength]
+		}
+		outcomes["error"] = msg
+	}
+	for kind, value := range outcomes {
+		_, err := tx.Exec(`
+			INSERT INTO job_stats_outcomes (job_id, hour, kind, value, count)
+			VALUES (?, `+hour+`, ?, ?, 1)
+			ON CONFLICT (job_id, hour, kind, value) DO UPDATE SET count = count + 1
+		`, log.JobID, kind, value)
+		if err != nil {
+			return fmt.Errorf("failed to update outcome stats: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// GetJobStats computes statistics for a job over the given window (24h, 7d
+// or 30d), counted in whole hours
+func (r *Repository) GetJobStats(jobID int64, window string) (*models.JobStats, error) {
+	modifier, ok := statsWindowModifiers[window]
+	if !ok {
+		return nil, fmt.Errorf("invalid stats window: %s", window)
+	}
+
+	stats := &models.JobStats{JobID: jobID, Window: window}
+	err := r.db.QueryRow(`
+		SELECT COALESCE(SUM(runs), 0), COALESCE(SUM(successes), 0),
+		       COALESCE(SUM(failures), 0), COALESCE(SUM(errors), 0),
+		       COALESCE(MAX(duration_max), 0)
+		FROM job_stats_hourly
+		WHERE job_id = ? AND `+windowStart, jobID, modifier).Scan(
+		&stats.Runs, &stats.Successes, &stats.Failures, &stats.Errors, &stats.MaxMs,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query job stats: %w", err)
+	}
+	if stats.Runs > 0 {
+		stats.SuccessRate = float64(stats.Successes) / float64(stats.Runs) * 100
+	}
+
+	histogram := make(map[int64]int64)
+	rows, err := r.db.Query(`
+		SELECT bucket_ms, SUM(count)
+		FROM job_stats_durations
+		WHERE job_id = ? AND `+windowStart+`
+		GROUP BY bucket_ms
+	`, jobID, modifier)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query duration stats: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var bucket, count int64
+		if err := rows.Scan(&bucket, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan duration stats: %w", err)
+		}
+		histogram[bucket] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	stats.P50Ms = histogramPercentile(histogram, stats.MaxMs, 0.50)
+	stats.P95Ms = histogramPercentile(histogram, stats.MaxMs, 0.95)
+	stats.P99Ms = histogramPercentile(histogram, stats.MaxMs, 0.99)
+
+	if stats.TopErrors, err = r.topOutcomes(jobID, "error", modifier, 5); err != nil {
+		return nil, err
+	}
+	if stats.TopStatusCodes, err = r.topOutcomes(jobID, "code", modifier, 5); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// topOutcomes returns the most frequent outcomes of a kind within a window
+func (r *Repository) topOutcomes(jobID int64, kind, modifier string, limit int) ([]models.OutcomeCount, error) {
+	query := `
+		SELECT value, SUM(count) AS total
+		FROM job_stats_outcomes
+		WHERE job_id = ? AND kind = ? AND ` + windowStart + `
+		GROUP BY value
+		ORDER BY total DESC, value
+		LIMIT ?
+	`
+
+	rows, err := r.db.Query(query, jobID, kind, modifier, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query outcome stats: %w", err)
+	}
+	defer rows.Close()
+
+	outcomes := make([]models.OutcomeCount, 0, limit)
+	for rows.Next() {
+		var o models.OutcomeCount
+		if err := rows.Scan(&o.Value, &o.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan outcome stats: %w", err)
+		}
+		outcomes = append(outcomes, o)
+	}
+
+	return outcomes, rows.Err()
+}
+
+// histogramPercentile estimates a percentile from bucket counts, interpolating
+// linearly within the bucket that contains it
+func histogramPercentile(histogram map[int64]int64, maxMs int64, p float64) int64 {
+	var total int64
+	for _, count := range histogram {
+		total += count
+	}
+	if total == 0 {
+		return 0
+	}
+
+	rank := p * float64(total)
+	var cumulative int64
+	var lower int64
+	bounds := append(append([]int64{}, durationBuckets...), 0)
+	for _, bound := range bounds {
+		upper := bound
+		if bound == 0 {
+			upper = maxMs
+		}
+		count := histogram[bound]
+		if count > 0 && float64(cumulative+count) >= rank {
+			fraction := (rank - float64(cumulative)) / float64(count)
+			value := lower + int64(fraction*float64(upper-lower))
+			if value > maxMs && maxMs > 0 {
+				value = maxMs
+			}
+			return value
+		}
+		cumulative += count
+		lower = upper
+	}
+
+	return maxMs
+}
+
+// GetRecentDurations returns the durations of the most recent executions,
+// oldest first, for drawing sparklines
+func (r *Repository) GetRecentDurations(jobID int64, limit int) ([]int64, error) {
+	query := `
+		SELECT duration_ms FROM (
+			SELECT duration_ms, created_at, id
+			FROM job_logs
+			WHERE job_id = ? AND duration_ms IS NOT NULL
+			ORDER BY created_at DESC, id DESC
+			LIMIT ?
+		) ORDER BY created_at, id
+	`
+
+	rows, err := r.db.Query(query, jobID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recent durations: %w", err)
+	}
+	defer rows.Close()
+
+	var durations []int64
+	for rows.Next() {
+		var d int64
+		if err := rows.Scan(&d); err != nil {
+			return nil, fmt.Errorf("failed to scan duration: %w", err)
+		}
+		durations = append(durations, d)
+	}
+
+	return durations, rows.Err()
+}
@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/rauche/cronnor/internal/models"
+)
+
+// newTestRepo creates a repository backed by a fresh, migrated database
+func newTestRepo(t *testing.T) *Repository {
+	t.Helper()
+
+	repo, err := New(filepath.Join(t.TempDir(), "cronnor.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+
+	if err := repo.RunMigrations("../../migrations"); err != nil {
+		t.Fatalf("failed to run migrations: %v", err)
+	}
+	return repo
+}
+
+func TestHistogramPercentile(t *testing.T) {
+	tests := []struct {
+		name      string
+		histogram map[int64]int64
+		maxMs     int64
+		p         float64
+		want      int64
+	}{
+		{"empty", map[int64]int64{}, 0, 0.5, 0},
+		{"single bucket p50", map[int64]int64{100: 10}, 90, 0.50, 87},
+		{"single bucket p99 capped at max", map[int64]int64{100: 10}, 90, 0.99, 90},
+		{"first bucket", map[int64]int64{5: 4}, 5, 0.50, 2},
+		{"across buckets", map[int64]int64{10: 5, 1000: 5}, 900, 0.95, 900},
+		{"p99 in overflow", map[int64]int64{5: 98, 0: 2}, 30000, 0.99, 22500},
+		{"p50 below overflow", map[int64]int64{5: 98, 0: 2}, 30000, 0.50, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := histogramPercentile(tt.histogram, tt.maxMs, tt.p); got != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestJobStatsRollup(t *testing.T) {
+	repo := newTestRepo(t)
+
+	jobID, err := repo.CreateJob(models.CreateJobParams{
+		Name: "sync", CronExpr: "0 0 * * * *", URL: "http://example.com", Method: "GET",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ms := func(n int64) sql.NullInt64 { return sql.NullInt64{Int64: n, Valid: true} }
+	for _, log := range []models.JobLog{
+		{Status: "SUCCESS", HTTPCode: ms(200), DurationMs: ms(3)},
+		{Status: "SUCCESS", HTTPCode: ms(200), DurationMs: ms(40)},
+		{Status: "SUCCESS", HTTPCode: ms(204), DurationMs: ms(120)},
+		{Status: "FAILED", HTTPCode: ms(500), DurationMs: ms(900)},
+		{Status: "ERROR", DurationMs: ms(20000), ErrorMessage: sql.NullString{String: "connection refused", Valid: true}},
+	} {
+		log.JobID = jobID
+		if _, err := repo.CreateJobLog(log); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := repo.GetJobStats(jobID, "24h")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The rollup agrees with the raw logs
+	var runs, successes, failures, errors, maxMs int64
+	err = repo.db.QueryRow(`
+		SELECT COUNT(*), SUM(status = 'SUCCESS'), SUM(status = 'FAILED'), SUM(status = 'ERROR'), MAX(duration_ms)
+		FROM job_logs WHERE job_id = ?
+	`, jobID).Scan(&runs, &successes, &failures, &errors, &maxMs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Runs != runs || stats.Successes != successes || stats.Failures != failures || stats.Errors != errors || stats.MaxMs != maxMs {
+		t.Errorf("expected %d runs (%d/%d/%d) up to %dms, got %+v", runs, successes, failures, errors, maxMs, stats)
+	}
+	if stats.P50Ms != 125 || stats.P99Ms != 19750 {
+		t.Errorf("expected p50 125ms and p99 19750ms, got %d and %d", stats.P50Ms, stats.P99Ms)
+	}
+	wantCodes := []models.OutcomeCount{{Value: "200", Count: 2}, {Value: "204", Count: 1}, {Value: "500", Count: 1}}
+	if !reflect.DeepEqual(stats.TopStatusCodes, wantCodes) {
+		t.Errorf("expected status codes %v, got %v", wantCodes, stats.TopStatusCodes)
+	}
+	wantErrors := []models.OutcomeCount{{Value: "connection refused", Count: 1}}
+	if !reflect.DeepEqual(stats.TopErrors, wantErrors) {
+		t.Errorf("expected errors %v, got %v", wantErrors, stats.TopErrors)
+	}
+
+	// Backfilling from the raw logs rebuilds the same rollup
+	for _, table := range []string{"job_stats_hourly", "job_stats_durations", "job_stats_outcomes"} {
+		if _, err := repo.db.Exec("DELETE FROM " + table); err != nil {
+			t.Fatal(err)
+		}
+	}
+	backfill, err := os.ReadFile("../../migrations/002_job_stats.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.db.Exec(string(backfill)); err != nil {
+		t.Fatalf("failed to backfill: %v", err)
+	}
+	backfilled, err := repo.GetJobStats(jobID, "24h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(backfilled, stats) {
+		t.Errorf("expected the backfill to match the rollup\nrollup:     %+v\nbackfilled: %+v", stats, backfilled)
+	}
+
+	// A heartbeat's start ping is not a run
+	if _, err := repo.CreateJobLog(models.JobLog{JobID: jobID, Status: "STARTED"}); err != nil {
+		t.Fatal(err)
+	}
+	if stats, err := repo.GetJobStats(jobID, "24h"); err != nil || stats.Runs != runs {
+		t.Errorf("expected start pings to be left out of the stats, got %+v (%v)", stats, err)
+	}
+}
+
+func TestJobStatsWindow(t *testing.T) {
+	repo := newTestRepo(t)
+
+	jobID, err := repo.CreateJob(models.CreateJobParams{
+		Name: "sync", CronExpr: "0 0 * * * *", URL: "http://example.com", Method: "GET",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One run in each of the last 25 whole hours, the current one included
+	for ago := 0; ago < 25; ago++ {
+		_, err := repo.db.Exec(`
+			INSERT INTO job_stats_hourly (job_id, hour, runs, successes)
+			VALUES (?, strftime('%Y-%m-%d %H:00:00', 'now', ?), 1, 1)
+		`, jobID, fmt.Sprintf("-%d hours", ago))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := repo.GetJobStats(jobID, "24h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Runs != 24 {
+		t.Errorf("expected the 24h window to cover 24 hours, got %d", stats.Runs)
+	}
+}
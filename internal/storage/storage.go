@@ -1,14 +1,31 @@
 package storage
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 
-	_ "modernc.org/sqlite"
+	"modernc.org/sqlite"
 )
 
+// Migrations can hash stored secrets with sha256_hex(text), which matches
+// auth.HashToken
+func init() {
+	sqlite.MustRegisterDeterministicScalarFunction("sha256_hex", 1, func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		text, ok := args[0].(string)
+		if !ok {
+			return nil, nil
+		}
+		sum := sha256.Sum256([]byte(text))
+		return hex.EncodeToString(sum[:]), nil
+	})
+}
+
 // Repository handles database operations
 type Repository struct {
 	db *sql.DB
@@ -22,16 +39,15 @@ func New(dbPath string) (*Repository, error) {
 		return nil, fmt.Errorf("failed to create db directory: %w", err)
 	}
 
-	db, err := sql.Open("sqlite", dbPath)
+	// Per-connection pragmas are set in the DSN so they apply to every pooled
+	// connection: enforce foreign keys (purging a job cascades to its logs),
+	// and wait for a concurrent writer (e.g. a job logging its execution while
+	// a request saves a change) instead of failing with SQLITE_BUSY.
+	db, err := sql.Open("sqlite", dbPath+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	// Enable foreign keys
-	if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
-		return nil, fmt.Errorf("failed to enable foreign keys: %w", err)
-	}
-
 	// Set pragmas for better performance
 	if _, err := db.Exec("PRAGMA journal_mode = WAL"); err != nil {
 		return nil, fmt.Errorf("failed to set journal mode: %w", err)
@@ -45,20 +61,74 @@ func (r *Repository) Close() error {
 	return r.db.Close()
 }
 
-// RunMigrations applies database migrations
+// RunMigrations applies database migrations.
+// migrationsPath may point to the migrations directory or to any file inside
+// it; every *.sql file in that directory is applied once, in name order.
 func (r *Repository) RunMigrations(migrationsPath string) error {
-	schemaSQL, err := os.ReadFile(migrationsPath)
+	dir := migrationsPath
+	if info, err := os.Stat(migrationsPath); err == nil && !info.IsDir() {
+		dir = filepath.Dir(migrationsPath)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.sql"))
 	if err != nil {
-		return fmt.Errorf("failed to read migration file: %w", err)
+		return fmt.Errorf("failed to list migration files: %w", err)
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no migration files found in %s", dir)
 	}
+	sort.Strings(files)
 
-	if _, err := r.db.Exec(string(schemaSQL)); err != nil {
-		return fmt.Errorf("failed to run migrations: %w", err)
+	if _, err := r.db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version TEXT PRIMARY KEY,
+			applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	for _, file := range files {
+		version := filepath.Base(file)
+
+		var applied int
+		if err := r.db.QueryRow(`SELECT COUNT(*) FROM schema_migrations WHERE version = ?`, version).Scan(&applied); err != nil {
+			return fmt.Errorf("failed to check migration %s: %w", version, err)
+		}
+		if applied > 0 {
+			continue
+		}
+
+		schemaSQL, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read migration file %s: %w", version, err)
+		}
+
+		tx, err := r.db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin migration %s: %w", version, err)
+		}
+		if _, err := tx.Exec(string(schemaSQL)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to run migration %s: %w", version, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES (?)`, version); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record migration %s: %w", version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit migration %s: %w", version, err)
+		}
 	}
 
 	return nil
 }
 
+// Ping checks that the database is reachable
+func (r *Repository) Ping() error {
+	return r.db.Ping()
+}
+
 // DB returns the underlying database connection
 func (r *Repository) DB() *sql.DB {
 	return r.db
@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/rauche/cronnor/internal/models"
+)
+
+// setJobTags replaces a job's tags
+func setJobTags(tx *sql.Tx, jobID int64, tags []string) error {
+	if _, err := tx.Exec(`DELETE FROM job_tags WHERE job_id = ?`, jobID); err != nil {
+		return fmt.Errorf("failed to clear job tags: %w", err)
+	}
+
+	for _, tag := range tags {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO job_tags (job_id, tag) VALUES (?, ?)`, jobID, tag); err != nil {
+			return fmt.Errorf("failed to tag job: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// jobTags retrieves a job's tags in order
+func jobTags(tx *sql.Tx, jobID int64) ([]string, error) {
+	rows, err := tx.Query(`SELECT tag FROM job_tags WHERE job_id = ? ORDER BY tag`, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query job tags: %w", err)
+	}
+	defer rows.Close()
+
+	var tags []string
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, fmt.Errorf("failed to scan job tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+// GetJobTags retrieves every tag in use by a job outside the trash
+func (r *Repository) GetJobTags() ([]string, error) {
+	return r.queryStrings(`
+		SELECT DISTINCT tag
+		FROM job_tags
+		JOIN jobs ON jobs.id = job_tags.job_id
+		WHERE jobs.deleted_at IS NULL
+		ORDER BY tag
+	`)
+}
+
+// GetJobTeams retrieves every team owning a job outside the trash
+func (r *Repository) GetJobTeams() ([]string, error) {
+	return r.queryStrings(`
+		SELECT DISTINCT team
+		FROM jobs
+		WHERE deleted_at IS NULL AND team != ''
+		ORDER BY team
+	`)
+}
+
+// queryStrings runs a query returning a single text column
+func (r *Repository) queryStrings(query string) ([]string, error) {
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query: %w", err)
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, fmt.Errorf("failed to scan: %w", err)
+		}
+		values = append(values, v)
+	}
+
+	return values, rows.Err()
+}
+
+// SetTagActive enables or disables every job carrying tag, returning the
+// jobs that changed as they were before the change
+func (r *Repository) SetTagActive(tag string, active bool) ([]models.Job, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	where, args := jobFilterClause(models.JobFilter{Tag: tag})
+	query := `
+		SELECT ` + jobColumns + `
+		FROM jobs
+		WHERE deleted_at IS NULL AND is_active != ?` + where + `
+		ORDER BY created_at DESC
+	`
+
+	rows, err := tx.Query(query, append([]interface{}{active}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query jobs: %w", err)
+	}
+
+	var changed []models.Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan job: %w", err)
+		}
+		changed = append(changed, *job)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query jobs: %w", err)
+	}
+
+	for _, job := range changed {
+		if _, err := tx.Exec(`UPDATE jobs SET is_active = ? WHERE id = ?`, active, job.ID); err != nil {
+			return nil, fmt.Errorf("failed to update job: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit jobs: %w", err)
+	}
+
+	return changed, nil
+}
@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/rauche/cronnor/internal/models"
+)
+
+// ErrTokenNotFound is returned when an API token does not exist
+var ErrTokenNotFound = errors.New("token not found")
+
+// CreateAPIToken stores a new API token
+func (r *Repository) CreateAPIToken(params models.CreateAPITokenParams) (int64, error) {
+	query := `
+		INSERT INTO api_tokens (user_id, name, token_hash, prefix, scopes, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+
+	result, err := r.db.Exec(query, params.UserID, params.Name, params.TokenHash, params.Prefix,
+		strings.Join(params.Scopes, " "), params.ExpiresAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create token: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get insert id: %w", err)
+	}
+
+	return id, nil
+}
+
+// GetAPITokens retrieves all API tokens
+func (r *Repository) GetAPITokens() ([]models.APIToken, error) {
+	query := `
+		SELECT id, COALESCE(user_id, 0), name, token_hash, prefix, scopes, expires_at, last_used_at, created_at
+		FROM api_tokens
+		ORDER BY created_at DESC
+	`
+
+	return r.queryAPITokens(query)
+}
+
+// GetUserAPITokens retrieves the API tokens created by a user
+func (r *Repository) GetUserAPITokens(userID int64) ([]models.APIToken, error) {
+	query := `
+		SELECT id, COALESCE(user_id, 0), name, token_hash, prefix, scopes, expires_at, last_used_at, created_at
+		FROM api_tokens
+		WHERE user_id = ?
+		ORDER BY created_at DESC
+	`
+
+	return r.queryAPITokens(query, userID)
+}
+
+func (r *Repository) queryAPITokens(query string, args ...interface{}) ([]models.APIToken, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tokens: %w", err)
+	}
+	defer rows.Close()
+
+	var tokens []models.APIToken
+	for rows.Next() {
+		token, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, *token)
+	}
+
+	return tokens, rows.Err()
+}
+
+// GetAPIToken retrieves an API token by ID
+func (r *Repository) GetAPIToken(id int64) (*models.APIToken, error) {
+	query := `
+		SELECT id, COALESCE(user_id, 0), name, token_hash, prefix, scopes, expires_at, last_used_at, created_at
+		FROM api_tokens
+		WHERE id = ?
+	`
+
+	token, err := scanAPIToken(r.db.QueryRow(query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTokenNotFound
+		}
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// GetAPITokenByHash retrieves an API token by the hash of its plaintext
+func (r *Repository) GetAPITokenByHash(hash string) (*models.APIToken, error) {
+	query := `
+		SELECT id, COALESCE(user_id, 0), name, token_hash, prefix, scopes, expires_at, last_used_at, created_at
+		FROM api_tokens
+		WHERE token_hash = ?
+	`
+
+	token, err := scanAPIToken(r.db.QueryRow(query, hash))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTokenNotFound
+		}
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// TouchAPIToken records that a token was used, at most once a minute
+func (r *Repository) TouchAPIToken(id int64) error {
+	query := `
+		UPDATE api_tokens
+		SET last_used_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND (last_used_at IS NULL OR last_used_at < datetime('now', '-1 minute'))
+	`
+
+	if _, err := r.db.Exec(query, id); err != nil {
+		return fmt.Errorf("failed to update token last used: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteAPIToken revokes an API token
+func (r *Repository) DeleteAPIToken(id int64) error {
+	result, err := r.db.Exec(`DELETE FROM api_tokens WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete token: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return ErrTokenNotFound
+	}
+
+	return nil
+}
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAPIToken(row rowScanner) (*models.APIToken, error) {
+	var token models.APIToken
+	var scopes string
+	err := row.Scan(
+		&token.ID, &token.UserID, &token.Name, &token.TokenHash, &token.Prefix, &scopes,
+		&token.ExpiresAt, &token.LastUsedAt, &token.CreatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan token: %w", err)
+	}
+	token.Scopes = strings.Fields(scopes)
+
+	return &token, nil
+}
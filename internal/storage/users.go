@@ -0,0 +1,250 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rauche/cronnor/internal/models"
+)
+
+// ErrUserNotFound is returned when a user does not exist
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrSessionNotFound is returned when a session does not exist or has expired
+var ErrSessionNotFound = errors.New("session not found")
+
+// CountUsers returns the number of user accounts
+func (r *Repository) CountUsers() (int, error) {
+	var count int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count users: %w", err)
+	}
+	return count, nil
+}
+
+// CreateUser creates a new user account
+func (r *Repository) CreateUser(username, passwordHash, role string) (int64, error) {
+	query := `INSERT INTO users (username, password_hash, role) VALUES (?, ?, ?)`
+
+	result, err := r.db.Exec(query, username, passwordHash, role)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get insert id: %w", err)
+	}
+
+	return id, nil
+}
+
+// GetUserByUsername retrieves a user by username (case-insensitive)
+func (r *Repository) GetUserByUsername(username string) (*models.User, error) {
+	query := `
+		SELECT id, username, password_hash, role, created_at
+		FROM users
+		WHERE username = ?
+	`
+
+	var user models.User
+	err := r.db.QueryRow(query, username).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &user.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return &user, nil
+}
+
+// UpsertOIDCUser creates or updates the user for an OIDC subject, setting
+// their role from the provider's groups
+func (r *Repository) UpsertOIDCUser(subject, username, role string) (*models.User, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRow(`SELECT id FROM users WHERE oidc_subject = ?`, subject).Scan(&id)
+	switch {
+	case err == sql.ErrNoRows:
+		// Avoid colliding with an existing local account of the same name
+		name := username
+		for i := 2; ; i++ {
+			var taken int
+			if err := tx.QueryRow(`SELECT COUNT(*) FROM users WHERE username = ?`, name).Scan(&taken); err != nil {
+				return nil, fmt.Errorf("failed to check username: %w", err)
+			}
+			if taken == 0 {
+				break
+			}
+			name = fmt.Sprintf("%s-%d", username, i)
+		}
+
+		result, err := tx.Exec(`
+			INSERT INTO users (username, password_hash, role, oidc_subject)
+			VALUES (?, '', ?, ?)
+		`, name, role, subject)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create user: %w", err)
+		}
+		if id, err = result.LastInsertId(); err != nil {
+			return nil, fmt.Errorf("failed to get insert id: %w", err)
+		}
+	case err != nil:
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	default:
+		if _, err := tx.Exec(`UPDATE users SET role = ? WHERE id = ?`, role, id); err != nil {
+			return nil, fmt.Errorf("failed to update user role: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit user: %w", err)
+	}
+
+	return r.GetUser(id)
+}
+
+// GetUser retrieves a user by ID
+func (r *Repository) GetUser(id int64) (*models.User, error) {
+	query := `
+		SELECT id, username, password_hash, role, created_at
+		FROM users
+		WHERE id = ?
+	`
+
+	var user models.User
+	err := r.db.QueryRow(query, id).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &user.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return &user, nil
+}
+
+// GetUsers retrieves all users
+func (r *Repository) GetUsers() ([]models.User, error) {
+	query := `
+		SELECT id, username, password_hash, role, created_at
+		FROM users
+		ORDER BY username
+	`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &user.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+// UpdateUserRole changes a user's role
+func (r *Repository) UpdateUserRole(id int64, role string) error {
+	result, err := r.db.Exec(`UPDATE users SET role = ? WHERE id = ?`, role, id)
+	if err != nil {
+		return fmt.Errorf("failed to update user role: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// DeleteUser deletes a user along with their sessions and API tokens
+func (r *Repository) DeleteUser(id int64) error {
+	result, err := r.db.Exec(`DELETE FROM users WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// CreateSession stores a new session for a user
+func (r *Repository) CreateSession(idHash string, userID int64, expiresAt time.Time) error {
+	query := `INSERT INTO sessions (id_hash, user_id, expires_at) VALUES (?, ?, ?)`
+
+	if _, err := r.db.Exec(query, idHash, userID, expiresAt.UTC()); err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return nil
+}
+
+// GetSessionUser retrieves the user owning an unexpired session
+func (r *Repository) GetSessionUser(idHash string) (*models.User, error) {
+	query := `
+		SELECT u.id, u.username, u.password_hash, u.role, u.created_at, s.expires_at
+		FROM sessions s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.id_hash = ?
+	`
+
+	var user models.User
+	var expiresAt time.Time
+	err := r.db.QueryRow(query, idHash).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &user.CreatedAt, &expiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	if time.Now().After(expiresAt) {
+		return nil, ErrSessionNotFound
+	}
+
+	return &user, nil
+}
+
+// DeleteSession removes a session
+func (r *Repository) DeleteSession(idHash string) error {
+	if _, err := r.db.Exec(`DELETE FROM sessions WHERE id_hash = ?`, idHash); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpiredSessions removes sessions past their expiry time
+func (r *Repository) DeleteExpiredSessions() error {
+	if _, err := r.db.Exec(`DELETE FROM sessions WHERE expires_at < ?`, time.Now().UTC()); err != nil {
+		return fmt.Errorf("failed to delete expired sessions: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/rauche/cronnor/internal/models"
+)
+
+// SetJobWebhook gives a job a trigger URL, replacing any previous one. Only
+// the token's hash is stored; calls must be signed with secret if it is valid.
+func (r *Repository) SetJobWebhook(id int64, tokenHash string, secret sql.NullString) error {
+	query := `
+		UPDATE jobs
+		SET webhook_token_hash = ?, webhook_secret = ?
+		WHERE id = ? AND deleted_at IS NULL
+	`
+
+	result, err := r.db.Exec(query, tokenHash, secret, id)
+	if err != nil {
+		return fmt.Errorf("failed to set job webhook: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return ErrJobNotFound
+	}
+
+	return nil
+}
+
+// DeleteJobWebhook removes a job's trigger URL
+func (r *Repository) DeleteJobWebhook(id int64) error {
+	query := `
+		UPDATE jobs
+		SET webhook_token_hash = NULL, webhook_secret = NULL
+		WHERE id = ? AND deleted_at IS NULL
+	`
+
+	result, err := r.db.Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete job webhook: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return ErrJobNotFound
+	}
+
+	return nil
+}
+
+// GetJobByWebhook retrieves the job whose trigger token has tokenHash, along
+// with its HMAC secret. Jobs in the trash are not found.
+func (r *Repository) GetJobByWebhook(tokenHash string) (*models.Job, sql.NullString, error) {
+	query := `
+		SELECT ` + jobColumns + `, webhook_secret
+		FROM jobs
+		WHERE webhook_token_hash = ? AND deleted_at IS NULL
+	`
+
+	var secret sql.NullString
+	job, err := scanJob(webhookRow{r.db.QueryRow(query, tokenHash), &secret})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, secret, ErrJobNotFound
+		}
+		return nil, secret, fmt.Errorf("failed to get job: %w", err)
+	}
+
+	return job, secret, nil
+}
+
+// webhookRow scans a job row followed by its webhook secret
+type webhookRow struct {
+	row    rowScanner
+	secret *sql.NullString
+}
+
+func (w webhookRow) Scan(dest ...interface{}) error {
+	return w.row.Scan(append(dest, w.secret)...)
+}
@@ -0,0 +1,60 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// Trace exporters, chosen with OTEL_TRACES_EXPORTER
+const (
+	ExporterOTLP = "otlp"
+	ExporterNone = "none"
+)
+
+// Setup creates the tracer provider for an exporter and OTLP protocol
+// (http/protobuf or grpc), and returns it with a function that flushes and
+// stops it. The none exporter records nothing.
+func Setup(ctx context.Context, exporter, protocol string) (trace.TracerProvider, func(context.Context) error, error) {
+	switch exporter {
+	case ExporterNone:
+		return noop.NewTracerProvider(), func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+	default:
+		return nil, nil, fmt.Errorf("unsupported trace exporter %q", exporter)
+	}
+
+	var spans sdktrace.SpanExporter
+	var err error
+	switch protocol {
+	case "http/protobuf":
+		spans, err = otlptracehttp.New(ctx)
+	case "grpc":
+		spans, err = otlptracegrpc.New(ctx)
+	default:
+		return nil, nil, fmt.Errorf("unsupported OTLP protocol %q", protocol)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the defaults
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName("cronnor")),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to describe the service: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(spans), sdktrace.WithResource(res))
+	return tp, tp.Shutdown, nil
+}
@@ -0,0 +1,88 @@
+-- Hourly rollup of job executions, kept in sync by CreateJobLog
+CREATE TABLE IF NOT EXISTS job_stats_hourly (
+  job_id INTEGER NOT NULL,
+  hour DATETIME NOT NULL,
+  runs INTEGER NOT NULL DEFAULT 0,
+  successes INTEGER NOT NULL DEFAULT 0,
+  failures INTEGER NOT NULL DEFAULT 0,
+  errors INTEGER NOT NULL DEFAULT 0,
+  duration_max INTEGER NOT NULL DEFAULT 0,
+  PRIMARY KEY (job_id, hour),
+  FOREIGN KEY (job_id) REFERENCES jobs(id) ON DELETE CASCADE
+);
+
+-- Duration histogram per hour; bucket_ms is the inclusive upper bound of the
+-- bucket (0 = overflow). Bounds must match durationBuckets in storage/stats.go.
+CREATE TABLE IF NOT EXISTS job_stats_durations (
+  job_id INTEGER NOT NULL,
+  hour DATETIME NOT NULL,
+  bucket_ms INTEGER NOT NULL,
+  count INTEGER NOT NULL DEFAULT 0,
+  PRIMARY KEY (job_id, hour, bucket_ms),
+  FOREIGN KEY (job_id) REFERENCES jobs(id) ON DELETE CASCADE
+);
+
+-- Error messages ('error') and HTTP status codes ('code') seen per hour
+CREATE TABLE IF NOT EXISTS job_stats_outcomes (
+  job_id INTEGER NOT NULL,
+  hour DATETIME NOT NULL,
+  kind TEXT NOT NULL,
+  value TEXT NOT NULL,
+  count INTEGER NOT NULL DEFAULT 0,
+  PRIMARY KEY (job_id, hour, kind, value),
+  FOREIGN KEY (job_id) REFERENCES jobs(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_job_stats_hourly_hour ON job_stats_hourly(hour);
+
+-- Backfill rollups from existing history
+INSERT OR REPLACE INTO job_stats_hourly (job_id, hour, runs, successes, failures, errors, duration_max)
+SELECT job_id,
+       strftime('%Y-%m-%d %H:00:00', created_at),
+       COUNT(*),
+       SUM(status = 'SUCCESS'),
+       SUM(status = 'FAILED'),
+       SUM(status = 'ERROR'),
+       COALESCE(MAX(duration_ms), 0)
+FROM job_logs
+GROUP BY 1, 2;
+
+INSERT OR REPLACE INTO job_stats_durations (job_id, hour, bucket_ms, count)
+SELECT job_id,
+       strftime('%Y-%m-%d %H:00:00', created_at),
+       CASE
+         WHEN duration_ms <= 5 THEN 5
+         WHEN duration_ms <= 10 THEN 10
+         WHEN duration_ms <= 25 THEN 25
+         WHEN duration_ms <= 50 THEN 50
+         WHEN duration_ms <= 75 THEN 75
+         WHEN duration_ms <= 100 THEN 100
+         WHEN duration_ms <= 150 THEN 150
+         WHEN duration_ms <= 250 THEN 250
+         WHEN duration_ms <= 400 THEN 400
+         WHEN duration_ms <= 600 THEN 600
+         WHEN duration_ms <= 1000 THEN 1000
+         WHEN duration_ms <= 1500 THEN 1500
+         WHEN duration_ms <= 2500 THEN 2500
+         WHEN duration_ms <= 4000 THEN 4000
+         WHEN duration_ms <= 6000 THEN 6000
+         WHEN duration_ms <= 10000 THEN 10000
+         WHEN duration_ms <= 15000 THEN 15000
+         ELSE 0
+       END,
+       COUNT(*)
+FROM job_logs
+WHERE duration_ms IS NOT NULL
+GROUP BY 1, 2, 3;
+
+INSERT OR REPLACE INTO job_stats_outcomes (job_id, hour, kind, value, count)
+SELECT job_id, strftime('%Y-%m-%d %H:00:00', created_at), 'code', CAST(http_code AS TEXT), COUNT(*)
+FROM job_logs
+WHERE http_code IS NOT NULL
+GROUP BY 1, 2, 4;
+
+INSERT OR REPLACE INTO job_stats_outcomes (job_id, hour, kind, value, count)
+SELECT job_id, strftime('%Y-%m-%d %H:00:00', created_at), 'error', substr(error_message, 1, 200), COUNT(*)
+FROM job_logs
+WHERE error_message IS NOT NULL
+GROUP BY 1, 2, 4;
@@ -0,0 +1,14 @@
+-- Roles for web UI users: viewer, operator, editor, admin
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'viewer';
+
+-- Accounts created before roles existed were all administrators
+UPDATE users SET role = 'admin';
+
+-- API tokens act on behalf of the user who created them
+ALTER TABLE api_tokens ADD COLUMN user_id INTEGER REFERENCES users(id) ON DELETE CASCADE;
+
+UPDATE api_tokens
+SET user_id = (SELECT id FROM users WHERE role = 'admin' ORDER BY id LIMIT 1)
+WHERE user_id IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_api_tokens_user_id ON api_tokens(user_id);
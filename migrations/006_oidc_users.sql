@@ -0,0 +1,4 @@
+-- Users provisioned through OpenID Connect are identified by issuer subject
+ALTER TABLE users ADD COLUMN oidc_subject TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_oidc_subject ON users(oidc_subject) WHERE oidc_subject IS NOT NULL;
@@ -0,0 +1,23 @@
+-- Every saved version of a job's configuration
+ALTER TABLE jobs ADD COLUMN revision INTEGER NOT NULL DEFAULT 1;
+
+CREATE TABLE IF NOT EXISTS job_revisions (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  job_id INTEGER NOT NULL,
+  revision INTEGER NOT NULL,
+  name TEXT NOT NULL,
+  cron_expr TEXT NOT NULL,
+  url TEXT NOT NULL,
+  method TEXT NOT NULL,
+  payload TEXT,
+  created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (job_id, revision),
+  FOREIGN KEY (job_id) REFERENCES jobs(id) ON DELETE CASCADE
+);
+
+-- Existing jobs start their history at revision 1
+INSERT INTO job_revisions (job_id, revision, name, cron_expr, url, method, payload, created_at)
+SELECT id, 1, name, cron_expr, url, method, payload, created_at FROM jobs;
+
+-- The revision each execution ran; NULL for executions before revisions existed
+ALTER TABLE job_logs ADD COLUMN job_revision INTEGER;
@@ -0,0 +1,4 @@
+-- Deleted jobs stay in the trash, with their logs, until they are purged
+ALTER TABLE jobs ADD COLUMN deleted_at DATETIME;
+
+CREATE INDEX IF NOT EXISTS idx_jobs_deleted_at ON jobs(deleted_at);
@@ -0,0 +1,13 @@
+-- Jobs can belong to a team and carry free-form tags for filtering and bulk actions
+ALTER TABLE jobs ADD COLUMN team TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_jobs_team ON jobs(team);
+
+CREATE TABLE IF NOT EXISTS job_tags (
+  job_id INTEGER NOT NULL,
+  tag TEXT NOT NULL,
+  PRIMARY KEY (job_id, tag),
+  FOREIGN KEY (job_id) REFERENCES jobs(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_job_tags_tag ON job_tags(tag);
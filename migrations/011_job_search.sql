@@ -0,0 +1,9 @@
+-- The scheduler records each active job's next run so listings can sort by it
+ALTER TABLE jobs ADD COLUMN next_run_at DATETIME;
+
+-- Indexes for sorting and paginating the dashboard
+CREATE INDEX IF NOT EXISTS idx_jobs_created_at ON jobs(created_at);
+CREATE INDEX IF NOT EXISTS idx_jobs_name ON jobs(name COLLATE NOCASE);
+CREATE INDEX IF NOT EXISTS idx_jobs_next_run_at ON jobs(next_run_at);
+CREATE INDEX IF NOT EXISTS idx_jobs_last_run_at ON jobs(last_run_at);
+CREATE INDEX IF NOT EXISTS idx_jobs_last_status ON jobs(last_status);
@@ -0,0 +1,11 @@
+-- Jobs can be run through a secret trigger URL. Only the token's hash is
+-- kept; the optional HMAC secret is kept as is since signatures must be
+-- recomputed.
+ALTER TABLE jobs ADD COLUMN webhook_token_hash TEXT;
+ALTER TABLE jobs ADD COLUMN webhook_secret TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_webhook_token_hash ON jobs(webhook_token_hash);
+
+-- What started each execution: the schedule, a user or a webhook. Earlier
+-- executions are recorded as scheduled.
+ALTER TABLE job_logs ADD COLUMN trigger_type TEXT NOT NULL DEFAULT 'schedule';
@@ -0,0 +1,10 @@
+-- Who started each execution and when. scheduled_at is the cron fire time of
+-- scheduled runs and lag_ms how much later the run actually started.
+-- Earlier executions have none of these.
+ALTER TABLE job_logs ADD COLUMN actor_id INTEGER;
+ALTER TABLE job_logs ADD COLUMN actor TEXT NOT NULL DEFAULT '';
+ALTER TABLE job_logs ADD COLUMN scheduled_at DATETIME;
+ALTER TABLE job_logs ADD COLUMN started_at DATETIME;
+ALTER TABLE job_logs ADD COLUMN lag_ms INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_job_logs_trigger ON job_logs(job_id, trigger_type);
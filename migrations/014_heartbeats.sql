@@ -0,0 +1,9 @@
+-- Heartbeat jobs send no requests. Other systems ping them on their cron
+-- schedule instead, and they turn LATE, then DOWN once the grace period is
+-- over, when a ping is missed.
+ALTER TABLE jobs ADD COLUMN kind TEXT NOT NULL DEFAULT 'http';
+ALTER TABLE jobs ADD COLUMN grace_seconds INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE jobs ADD COLUMN ping_token TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_ping_token ON jobs(ping_token);
+CREATE INDEX IF NOT EXISTS idx_jobs_kind ON jobs(kind);
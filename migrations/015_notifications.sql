@@ -0,0 +1,68 @@
+-- Where alerts are sent: an SMTP email address list, a Slack-compatible
+-- incoming webhook or a generic JSON webhook
+CREATE TABLE IF NOT EXISTS notification_channels (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name TEXT NOT NULL UNIQUE,
+  type TEXT NOT NULL,
+  target TEXT NOT NULL,
+  created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- When a job alerts a channel: on failure, after threshold consecutive
+-- failures, on recovery, or when a run takes longer than threshold ms
+CREATE TABLE IF NOT EXISTS alert_rules (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  job_id INTEGER NOT NULL,
+  channel_id INTEGER NOT NULL,
+  event TEXT NOT NULL,
+  threshold INTEGER NOT NULL DEFAULT 0,
+  created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (job_id) REFERENCES jobs(id) ON DELETE CASCADE,
+  FOREIGN KEY (channel_id) REFERENCES notification_channels(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_rules_job_id ON alert_rules(job_id);
+
+-- A run of failures of a job, from its first failure until it next succeeds.
+-- A job has at most one open incident.
+CREATE TABLE IF NOT EXISTS job_incidents (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  job_id INTEGER NOT NULL,
+  failures INTEGER NOT NULL DEFAULT 1,
+  opened_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+  resolved_at DATETIME,
+  FOREIGN KEY (job_id) REFERENCES jobs(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_job_incidents_open ON job_incidents(job_id) WHERE resolved_at IS NULL;
+
+-- Alerts raised by rules. dedup_key names what the alert is about, such as
+-- an incident, so each rule alerts about it once. Alerts outlive their rule.
+CREATE TABLE IF NOT EXISTS alerts (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  rule_id INTEGER,
+  job_id INTEGER NOT NULL,
+  channel_name TEXT NOT NULL,
+  event TEXT NOT NULL,
+  dedup_key TEXT NOT NULL,
+  message TEXT NOT NULL,
+  created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (rule_id, dedup_key),
+  FOREIGN KEY (rule_id) REFERENCES alert_rules(id) ON DELETE SET NULL,
+  FOREIGN KEY (job_id) REFERENCES jobs(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_alerts_job_id ON alerts(job_id, created_at DESC);
+
+-- Every attempt to deliver an alert; status is 'sent' or 'failed'
+CREATE TABLE IF NOT EXISTS notification_attempts (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  alert_id INTEGER NOT NULL,
+  attempt INTEGER NOT NULL,
+  status TEXT NOT NULL,
+  error TEXT,
+  created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (alert_id) REFERENCES alerts(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_attempts_alert_id ON notification_attempts(alert_id);
@@ -0,0 +1,16 @@
+-- Scheduled summaries of job health, for the jobs of a team and/or tag (or
+-- every job when both are empty), sent to a notification channel
+CREATE TABLE IF NOT EXISTS digests (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name TEXT NOT NULL UNIQUE,
+  team TEXT NOT NULL DEFAULT '',
+  tag TEXT NOT NULL DEFAULT '',
+  cron_expr TEXT NOT NULL,
+  stats_window TEXT NOT NULL DEFAULT '7d',
+  channel_id INTEGER NOT NULL,
+  next_run_at DATETIME,
+  last_sent_at DATETIME,
+  last_error TEXT,
+  created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (channel_id) REFERENCES notification_channels(id) ON DELETE CASCADE
+);
@@ -0,0 +1,3 @@
+-- The OpenTelemetry trace each execution was recorded in, when tracing is
+-- enabled
+ALTER TABLE job_logs ADD COLUMN trace_id TEXT;
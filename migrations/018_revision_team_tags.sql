@@ -0,0 +1,11 @@
+-- Revisions also record a job's team, tags and heartbeat grace period, so
+-- restoring one brings them back too. A job's kind never changes.
+ALTER TABLE job_revisions ADD COLUMN team TEXT NOT NULL DEFAULT '';
+ALTER TABLE job_revisions ADD COLUMN tags TEXT NOT NULL DEFAULT '';
+ALTER TABLE job_revisions ADD COLUMN grace_seconds INTEGER NOT NULL DEFAULT 0;
+
+-- Earlier revisions didn't record them; assume the job's current values
+UPDATE job_revisions SET
+  team = COALESCE((SELECT team FROM jobs WHERE jobs.id = job_revisions.job_id), ''),
+  grace_seconds = COALESCE((SELECT grace_seconds FROM jobs WHERE jobs.id = job_revisions.job_id), 0),
+  tags = COALESCE((SELECT group_concat(tag, ',') FROM job_tags WHERE job_tags.job_id = job_revisions.job_id), '');
@@ -0,0 +1,9 @@
+-- Heartbeat ping tokens are kept as hashes, like trigger tokens. Existing
+-- tokens are hashed in place, so their ping URLs keep working.
+ALTER TABLE jobs ADD COLUMN ping_token_hash TEXT;
+UPDATE jobs SET ping_token_hash = sha256_hex(ping_token) WHERE ping_token IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_jobs_ping_token;
+ALTER TABLE jobs DROP COLUMN ping_token;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_ping_token_hash ON jobs(ping_token_hash);
@@ -64,6 +64,21 @@ function updateCronExpression() {
   description.textContent = desc;
 }
 
+/**
+ * Shows the fields that apply to the selected job type. Fields of the other
+ * type are disabled so they are neither required nor submitted.
+ */
+function updateJobKind() {
+  var kind = document.getElementById("kind").value;
+  document.querySelectorAll("[data-kind]").forEach(function (section) {
+    var shown = section.getAttribute("data-kind") === kind;
+    section.style.display = shown ? "" : "none";
+    section.querySelectorAll("input, select, textarea").forEach(function (field) {
+      field.disabled = !shown;
+    });
+  });
+}
+
 /**
  * Initialize the form on page load
  * If editing an existing job, switch to custom mode to show the current cron expression
@@ -79,4 +94,5 @@ document.addEventListener("DOMContentLoaded", function () {
     // New job - show default preset
     updateCronExpression();
   }
+  updateJobKind();
 });